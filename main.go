@@ -3,6 +3,10 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
 
 	"github.com/olebedev/emitter"
 
@@ -17,7 +21,33 @@ const ActListenUDPPort uint16 = 31593
 // HTTPListenTCPPort - Port http server will listen on
 const HTTPListenTCPPort uint16 = 8082
 
+// subcommands - command line sub commands, the server is started when none is given
+var subcommands = map[string]func(args []string) error{
+	"export":  exportCommand,
+	"check":   checkCommand,
+	"migrate": migrateCommand,
+}
+
 func main() {
+	// set storage for encounter files
+	storage, err := session.NewBlobStorageFromConfig()
+	if err != nil {
+		panic(err)
+	}
+	session.SetBlobStorage(storage)
+
+	// run sub command
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			err := command(os.Args[2:])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	appLog := app.Logging{ModuleName: "MAIN"}
 	// define+parse flags
 	devModePtr := flag.Bool("dev", false, "Start server in development mode.")
@@ -46,9 +76,6 @@ func main() {
 	}
 	go dbHandler.CleanUpRoutine()
 
-	// log line clean up
-	go session.LogLineCleanUpRoutine()
-
 	// create session manager
 	sessionManager, err := session.NewSessionManager(&dbHandler, &events)
 	if err != nil {
@@ -69,3 +96,160 @@ func main() {
 	session.Listen(uint16(*actPort), &sessionManager)
 
 }
+
+// migrateCommand - apply or revert database migrations
+func migrateCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	version := flags.Int("to", session.LatestSchemaVersion(), "Schema version to migrate to, lower than current reverts migrations.")
+	status := flags.Bool("status", false, "List migrations and whether they have been applied, without migrating.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s migrate [options]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	dbHandler, err := session.OpenDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	defer dbHandler.Close()
+	if !*status {
+		err = dbHandler.Migrate(*version)
+		if err != nil {
+			return err
+		}
+	}
+	applied, err := dbHandler.FetchAppliedMigrations()
+	if err != nil {
+		return err
+	}
+	appliedAt := make(map[int]time.Time)
+	for _, migration := range applied {
+		appliedAt[migration.Version] = migration.AppliedAt
+	}
+	for _, migration := range session.Migrations {
+		state := "pending"
+		if t, ok := appliedAt[migration.Version]; ok {
+			state = "applied " + t.Format(time.RFC3339)
+		}
+		fmt.Printf("%4d %-48s %s\n", migration.Version, migration.Name, state)
+	}
+	return nil
+}
+
+// checkCommand - check database and encounter files are consistent, optionally fix problems
+func checkCommand(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	savePath := flags.String("data", "", "Path log files are stored in, defaults to the configured storage.")
+	repair := flags.Bool("repair", false, "Rebuild missing log indexes and delete orphaned files and rows.")
+	deleteMissing := flags.Bool("delete-missing", false, "Delete encounters whose log file is missing.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s check [options]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	dbHandler, err := session.NewDatabaseHandler()
+	if err != nil {
+		return err
+	}
+	storage := session.GetBlobStorage()
+	if *savePath != "" {
+		storage = session.NewLocalBlobStorage(*savePath)
+	}
+	report, err := dbHandler.CheckStorage(
+		storage,
+		session.StorageCheckOptions{Repair: *repair, DeleteMissing: *deleteMissing},
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Checked %d encounters and %d files.\n", report.Encounters, report.Files)
+	for _, key := range report.OrphanedFiles {
+		fmt.Printf("orphaned file: %s\n", key)
+	}
+	for _, encounterUID := range report.MissingLogFiles {
+		fmt.Printf("missing log file: %s\n", encounterUID)
+	}
+	for _, key := range report.MissingIndexFiles {
+		fmt.Printf("missing index file: %s\n", key)
+	}
+	for _, encounterUID := range report.Unaccounted {
+		fmt.Printf("missing storage usage: %s\n", encounterUID)
+	}
+	tables := make([]string, 0, len(report.OrphanedRows))
+	for table := range report.OrphanedRows {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		if report.OrphanedRows[table] > 0 {
+			fmt.Printf("orphaned rows: %s (%d)\n", table, report.OrphanedRows[table])
+		}
+	}
+	if report.IsClean() {
+		fmt.Println("No problems found.")
+		return nil
+	}
+	if *repair {
+		fmt.Println("Deleted orphaned files and rows, rebuilt missing indexes and storage usage.")
+	}
+	if *deleteMissing && len(report.MissingLogFiles) > 0 {
+		fmt.Println("Deleted encounters with missing log files.")
+	}
+	if !*repair && !*deleteMissing {
+		fmt.Println("Run with -repair and/or -delete-missing to fix problems.")
+	}
+	return nil
+}
+
+// exportCommand - write stored encounter log in act log file format
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	savePath := flags.String("data", "", "Path log files are stored in, defaults to the configured storage.")
+	output := flags.String("o", "", "File to write log to, defaults to stdout.")
+	start := flags.Float64("start", 0, "Only include log lines from this many seconds after encounter start.")
+	end := flags.Float64("end", 0, "Only include log lines up to this many seconds after encounter start.")
+	tzOffset := flags.Int("tz", 0, "Time zone offset in minutes west of UTC for log line times.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s export [options] <encounter uid>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("encounter uid must be provided")
+	}
+	encounterUID := flags.Arg(0)
+	// time window is relative to encounter start
+	rng := session.LogLineRange{}
+	if *start > 0 || *end > 0 {
+		dbHandler, err := session.NewDatabaseHandler()
+		if err != nil {
+			return err
+		}
+		encounter, err := dbHandler.FetchEncounter(encounterUID)
+		if err != nil {
+			return err
+		}
+		if *start > 0 {
+			rng.Start = encounter.StartTime.Add(time.Duration(*start * float64(time.Second)))
+		}
+		if *end > 0 {
+			rng.End = encounter.StartTime.Add(time.Duration(*end * float64(time.Second)))
+		}
+	}
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	storage := session.GetBlobStorage()
+	if *savePath != "" {
+		storage = session.NewLocalBlobStorage(*savePath)
+	}
+	return session.ExportLog(w, storage, encounterUID, rng, time.FixedZone("", -*tzOffset*60))
+}
@@ -20,6 +20,7 @@ package app
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -35,7 +36,7 @@ const ActPluginMaxVersionNumber int32 = 7
 // Name - app name
 const Name string = "FFLiveParse"
 
-// DatabasePath - path to database file
+// DatabasePath - path to database file, used when no database dsn is set for sqlite
 const DatabasePath = "./data/db.sqlite"
 
 // FileStorePath - path to file system storage
@@ -59,8 +60,11 @@ const MaxEncounterSaveLength = 1500000 // 25 minutes
 // PastEncounterFetchLimit - Max number of past encounters to fetch in one request
 const PastEncounterFetchLimit = 30
 
-// EncounterLogDeleteDays - Number of days that should pass before deleting encounter logs
-const EncounterLogDeleteDays = 14
+// LogLineRangeLimit - Max number of log lines to return for a single log line range request
+const LogLineRangeLimit = 5000
+
+// LogSearchLimit - Max number of matching log lines to return for a single log search request
+const LogSearchLimit = 100
 
 // EncounterDeleteDays - Number of days that should pass before deleting entire encounter
 const EncounterDeleteDays = 14
@@ -71,6 +75,12 @@ const EncounterCleanUpRate = 1800000 // 30 minutes
 // CleanUpRoutineRate - Rate at which clean up routines are ran
 const CleanUpRoutineRate = 1800000 // 30 minutes
 
+// ParseErrorSampleMax - Number of failing log lines of each type always written to the sample file
+const ParseErrorSampleMax = 100
+
+// ParseErrorSampleRate - After ParseErrorSampleMax, one in this many failing log lines are written to the sample file
+const ParseErrorSampleRate = 1000
+
 // GetFFToolsURL - url to access fftools api
 func GetFFToolsURL() string {
 	out, _ := os.LookupEnv("FFTOOLS_URL")
@@ -86,6 +96,12 @@ func GetFFTriggersURL() string {
 	return strings.TrimRight(out, "/") + "/"
 }
 
+// GetParseErrorSamplePath - directory to write samples of log lines that failed to parse to, empty disables sampling
+func GetParseErrorSamplePath() string {
+	out, _ := os.LookupEnv("PARSE_ERROR_SAMPLE_PATH")
+	return out
+}
+
 // GetVersionString - get version as string in format X.XX
 func GetVersionString() string {
 	return fmt.Sprintf("%.2f", float32(VersionNumber)/100.0)
@@ -95,3 +111,75 @@ func GetVersionString() string {
 func GetActVersionString() string {
 	return fmt.Sprintf("%.2f", float32(ActPluginMaxVersionNumber)/100.0)
 }
+
+// GetDatabaseDriver - database driver to use, 'sqlite3' or 'postgres'
+func GetDatabaseDriver() string {
+	out, _ := os.LookupEnv("DATABASE_DRIVER")
+	if out == "" {
+		return "sqlite3"
+	}
+	return strings.ToLower(out)
+}
+
+// GetDatabaseDSN - data source name to connect to database with
+func GetDatabaseDSN() string {
+	out, _ := os.LookupEnv("DATABASE_DSN")
+	if out == "" && GetDatabaseDriver() == "sqlite3" {
+		return DatabasePath
+	}
+	return out
+}
+
+// GetAdminKey - key required by admin endpoints, admin endpoints are disabled when empty
+func GetAdminKey() string {
+	out, _ := os.LookupEnv("ADMIN_KEY")
+	return out
+}
+
+// GetQuotaEncounters - default max number of encounters an user can store, zero is unlimited
+func GetQuotaEncounters() int64 {
+	out, _ := strconv.ParseInt(os.Getenv("QUOTA_ENCOUNTERS"), 10, 64)
+	return out
+}
+
+// GetQuotaBytes - default max log file bytes an user can store, zero is unlimited
+func GetQuotaBytes() int64 {
+	out, _ := strconv.ParseInt(os.Getenv("QUOTA_BYTES"), 10, 64)
+	return out
+}
+
+// GetStorageBackend - backend encounter files are stored with, 'local' or 's3'
+func GetStorageBackend() string {
+	out, _ := os.LookupEnv("STORAGE_BACKEND")
+	return strings.ToLower(out)
+}
+
+// GetS3Endpoint - url of S3 compatible object storage
+func GetS3Endpoint() string {
+	out, _ := os.LookupEnv("S3_ENDPOINT")
+	return out
+}
+
+// GetS3Bucket - bucket to store encounter files in
+func GetS3Bucket() string {
+	out, _ := os.LookupEnv("S3_BUCKET")
+	return out
+}
+
+// GetS3Region - region of S3 bucket
+func GetS3Region() string {
+	out, _ := os.LookupEnv("S3_REGION")
+	return out
+}
+
+// GetS3AccessKey - access key for S3 requests
+func GetS3AccessKey() string {
+	out, _ := os.LookupEnv("S3_ACCESS_KEY")
+	return out
+}
+
+// GetS3SecretKey - secret key for S3 requests
+func GetS3SecretKey() string {
+	out, _ := os.LookupEnv("S3_SECRET_KEY")
+	return out
+}
@@ -31,10 +31,12 @@ const StatSnapshotRate = 1000 * 60
 
 // StatSnapshot - snapshot of stats at a point in time
 type StatSnapshot struct {
-	Time        time.Time `json:"time"`
-	PageLoads   int       `json:"page_loads"`
-	LogLines    int64     `json:"log_lines"`
-	Connections struct {
+	Time            time.Time        `json:"time"`
+	PageLoads       int              `json:"page_loads"`
+	LogLines        int64            `json:"log_lines"`
+	ParseErrors     map[string]int64 `json:"parse_errors"`
+	UnknownLogTypes map[string]int64 `json:"unknown_log_types"`
+	Connections     struct {
 		Web map[int64]int `json:"web"`
 		ACT map[int64]int `json:"act"`
 	} `json:"connections"`
@@ -0,0 +1,110 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package data
+
+import (
+	"errors"
+	"time"
+)
+
+// DataTypeAbilityStat - Data type, per ability stat data
+const DataTypeAbilityStat byte = 8
+
+// AbilityStatTypeDamage - Ability stat type, damage done
+const AbilityStatTypeDamage uint8 = 1
+
+// AbilityStatTypeHeal - Ability stat type, healing done
+const AbilityStatTypeHeal uint8 = 2
+
+// AbilityStat - Totals for a single ability used by a combatant in an encounter
+type AbilityStat struct {
+	ByteEncodable
+	ID            int64     `json:"-" gorm:"primary key;unique;AUTO_INCREMENT"`
+	UserID        int64     `json:"user_id"`
+	EncounterUID  string    `json:"encounter_uid" gorm:"type:varchar(32);index"`
+	CombatantID   int32     `json:"combatant_id"`
+	CombatantName string    `json:"combatant_name" gorm:"type:varchar(128)"`
+	OwnerID       int32     `json:"owner_id"` // set when combatant is a pet/summon
+	AbilityID     int32     `json:"ability_id"`
+	AbilityName   string    `json:"ability_name" gorm:"type:varchar(128)"`
+	Type          uint8     `json:"type"`
+	Total         int32     `json:"total"`
+	Hits          int32     `json:"hits"`
+	Crits         int32     `json:"crits"`
+	DirectHits    int32     `json:"direct_hits"`
+	MaxHit        int32     `json:"max_hit"`
+	Time          time.Time `json:"time"` // time of last hit
+}
+
+// CritRate - get percentage of hits that were critical hits
+func (a *AbilityStat) CritRate() float64 {
+	if a.Hits == 0 {
+		return 0
+	}
+	return float64(a.Crits) / float64(a.Hits) * 100
+}
+
+// DirectHitRate - get percentage of hits that were direct hits
+func (a *AbilityStat) DirectHitRate() float64 {
+	if a.Hits == 0 {
+		return 0
+	}
+	return float64(a.DirectHits) / float64(a.Hits) * 100
+}
+
+// ToBytes - Convert to bytes
+func (a *AbilityStat) ToBytes() []byte {
+	data := make([]byte, 1)
+	data[0] = DataTypeAbilityStat
+	writeString(&data, a.EncounterUID)
+	writeInt32(&data, a.CombatantID)
+	writeString(&data, a.CombatantName)
+	writeInt32(&data, a.OwnerID)
+	writeInt32(&data, a.AbilityID)
+	writeString(&data, a.AbilityName)
+	writeByte(&data, a.Type)
+	writeInt32(&data, a.Total)
+	writeInt32(&data, a.Hits)
+	writeInt32(&data, a.Crits)
+	writeInt32(&data, a.DirectHits)
+	writeInt32(&data, a.MaxHit)
+	writeTime(&data, a.Time)
+	return data
+}
+
+// FromBytes - Convert bytes to ability stat
+func (a *AbilityStat) FromBytes(data []byte) error {
+	if data[0] != DataTypeAbilityStat {
+		return errors.New("invalid data type for AbilityStat")
+	}
+	pos := 1
+	a.EncounterUID = readString(data, &pos)
+	a.CombatantID = readInt32(data, &pos)
+	a.CombatantName = readString(data, &pos)
+	a.OwnerID = readInt32(data, &pos)
+	a.AbilityID = readInt32(data, &pos)
+	a.AbilityName = readString(data, &pos)
+	a.Type = readByte(data, &pos)
+	a.Total = readInt32(data, &pos)
+	a.Hits = readInt32(data, &pos)
+	a.Crits = readInt32(data, &pos)
+	a.DirectHits = readInt32(data, &pos)
+	a.MaxHit = readInt32(data, &pos)
+	a.Time = readTime(data, &pos)
+	return nil
+}
@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/binary"
+	"math"
 	"time"
 )
 
@@ -46,6 +47,10 @@ func readInt32(data []byte, pos *int) int32 {
 	return int32(readUint32(data, pos))
 }
 
+func readFloat32(data []byte, pos *int) float32 {
+	return math.Float32frombits(readUint32(data, pos))
+}
+
 func readByte(data []byte, pos *int) byte {
 	if len(data)-*pos < 1 {
 		return 0
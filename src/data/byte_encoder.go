@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/binary"
+	"math"
 	"time"
 )
 
@@ -36,6 +37,12 @@ func writeInt32(data *[]byte, value int32) {
 	*data = append(*data, buf...)
 }
 
+func writeFloat32(data *[]byte, value float32) {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, math.Float32bits(value))
+	*data = append(*data, buf...)
+}
+
 func writeByte(data *[]byte, value byte) {
 	*data = append(*data, value)
 }
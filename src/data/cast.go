@@ -0,0 +1,94 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package data
+
+import (
+	"errors"
+	"time"
+)
+
+// DataTypeCast - Data type, cast data
+const DataTypeCast byte = 6
+
+// CastStatusCasting - Cast status, cast is in progress
+const CastStatusCasting uint8 = 0
+
+// CastStatusComplete - Cast status, cast completed
+const CastStatusComplete uint8 = 1
+
+// CastStatusInterrupted - Cast status, cast was interrupted
+const CastStatusInterrupted uint8 = 2
+
+// CastStatusCancelled - Cast status, cast was cancelled (moved, target died, etc)
+const CastStatusCancelled uint8 = 3
+
+// Cast - Data about a combatant's cast
+type Cast struct {
+	ByteEncodable
+	ID            int64     `json:"-" gorm:"primary key;unique;AUTO_INCREMENT"`
+	UserID        int64     `json:"user_id"`
+	EncounterUID  string    `json:"encounter_uid" gorm:"type:varchar(32);index"`
+	CombatantID   int32     `json:"combatant_id"`
+	CombatantName string    `json:"combatant_name" gorm:"type:varchar(128)"`
+	AbilityID     int32     `json:"ability_id"`
+	AbilityName   string    `json:"ability_name" gorm:"type:varchar(128)"`
+	TargetID      int32     `json:"target_id"`
+	TargetName    string    `json:"target_name" gorm:"type:varchar(128)"`
+	StartTime     time.Time `json:"start_time"`
+	Duration      int32     `json:"duration"` // expected cast time in ms
+	EndTime       time.Time `json:"end_time"`
+	Status        uint8     `json:"status"`
+}
+
+// ToBytes - Convert to bytes
+func (c *Cast) ToBytes() []byte {
+	data := make([]byte, 1)
+	data[0] = DataTypeCast
+	writeString(&data, c.EncounterUID)
+	writeInt32(&data, c.CombatantID)
+	writeString(&data, c.CombatantName)
+	writeInt32(&data, c.AbilityID)
+	writeString(&data, c.AbilityName)
+	writeInt32(&data, c.TargetID)
+	writeString(&data, c.TargetName)
+	writeTime(&data, c.StartTime)
+	writeInt32(&data, c.Duration)
+	writeTime(&data, c.EndTime)
+	writeByte(&data, c.Status)
+	return data
+}
+
+// FromBytes - Convert bytes to cast
+func (c *Cast) FromBytes(data []byte) error {
+	if data[0] != DataTypeCast {
+		return errors.New("invalid data type for Cast")
+	}
+	pos := 1
+	c.EncounterUID = readString(data, &pos)
+	c.CombatantID = readInt32(data, &pos)
+	c.CombatantName = readString(data, &pos)
+	c.AbilityID = readInt32(data, &pos)
+	c.AbilityName = readString(data, &pos)
+	c.TargetID = readInt32(data, &pos)
+	c.TargetName = readString(data, &pos)
+	c.StartTime = readTime(data, &pos)
+	c.Duration = readInt32(data, &pos)
+	c.EndTime = readTime(data, &pos)
+	c.Status = readByte(data, &pos)
+	return nil
+}
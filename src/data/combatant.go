@@ -43,6 +43,8 @@ type Combatant struct {
 	Hits           int32     `json:"hits"`
 	Heals          int32     `json:"heals"`
 	Kills          int32     `json:"kills"`
+	DotDamage      int32     `json:"dot_damage"`
+	HotHealed      int32     `json:"hot_healed"`
 }
 
 // ToBytes - Convert to bytes
@@ -61,6 +63,8 @@ func (c *Combatant) ToBytes() []byte {
 	writeInt32(&data, c.Hits)
 	writeInt32(&data, c.Heals)
 	writeInt32(&data, c.Kills)
+	writeInt32(&data, c.DotDamage)
+	writeInt32(&data, c.HotHealed)
 	writeTime(&data, c.Time)
 	return data
 }
@@ -115,6 +119,8 @@ func (c *Combatant) FromBytes(data []byte) error {
 	c.Hits = readInt32(data, &pos)
 	c.Heals = readInt32(data, &pos)
 	c.Kills = readInt32(data, &pos)
+	c.DotDamage = readInt32(data, &pos)
+	c.HotHealed = readInt32(data, &pos)
 	c.Time = readTime(data, &pos)
 	return nil
 }
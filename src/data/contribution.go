@@ -0,0 +1,46 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package data
+
+import "time"
+
+// Contribution - Damage a player gained from, and gave to, other players' raid buffs
+type Contribution struct {
+	ID             int64     `json:"-" gorm:"primary key;unique;AUTO_INCREMENT"`
+	UserID         int64     `json:"user_id"`
+	EncounterUID   string    `json:"encounter_uid" gorm:"type:varchar(32);index"`
+	CombatantID    int32     `json:"combatant_id"`
+	CombatantName  string    `json:"combatant_name" gorm:"type:varchar(128)"`
+	OwnerID        int32     `json:"owner_id"`                            // set when combatant is a pet/summon
+	OwnerName      string    `json:"owner_name" gorm:"type:varchar(128)"` // owner name, kept for owners with no contribution of their own
+	Damage         int64     `json:"damage"`                              // raw damage parsed from log lines
+	DamageReceived int64     `json:"damage_received"`                     // portion of damage that came from other players' buffs
+	DamageGiven    int64     `json:"damage_given"`                        // damage this player's buffs added to other players
+	Approximate    bool      `json:"approximate"`                         // includes crit/direct hit rate buffs, which are estimated as flat increases
+	Time           time.Time `json:"time"`                                // time of last update
+}
+
+// AdjustDamage - apply buff contribution to given damage value (rdps style)
+func (c *Contribution) AdjustDamage(damage int64) int64 {
+	return damage - c.DamageReceived + c.DamageGiven
+}
+
+// AdjustedDamage - raw parsed damage with buff contribution applied
+func (c *Contribution) AdjustedDamage() int64 {
+	return c.AdjustDamage(c.Damage)
+}
@@ -0,0 +1,121 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package data
+
+import (
+	"errors"
+	"time"
+)
+
+// DataTypeDeathRecap - Data type, death recap data
+const DataTypeDeathRecap byte = 7
+
+// DeathRecapEventDamage - Death recap event type, damage taken
+const DeathRecapEventDamage uint8 = 1
+
+// DeathRecapEventHeal - Death recap event type, healing received
+const DeathRecapEventHeal uint8 = 2
+
+// DeathRecap - Data about the events leading up to a player's death
+type DeathRecap struct {
+	ByteEncodable
+	ID           int64             `json:"-" gorm:"primary key;unique;AUTO_INCREMENT"`
+	UserID       int64             `json:"user_id"`
+	EncounterUID string            `json:"encounter_uid" gorm:"type:varchar(32);index"`
+	TargetID     int32             `json:"target_id"`
+	TargetName   string            `json:"target_name" gorm:"type:varchar(128)"`
+	Time         time.Time         `json:"time"`
+	Events       []DeathRecapEvent `json:"events" gorm:"foreignkey:DeathRecapID"`
+}
+
+// DeathRecapEvent - Single damage/heal event in a death recap
+type DeathRecapEvent struct {
+	ID           int64     `json:"-" gorm:"primary key;unique;AUTO_INCREMENT"`
+	DeathRecapID int64     `json:"-" gorm:"index"`
+	Time         time.Time `json:"time"`
+	Type         uint8     `json:"type"`
+	SourceID     int32     `json:"source_id"`
+	SourceName   string    `json:"source_name" gorm:"type:varchar(128)"`
+	AbilityID    int32     `json:"ability_id"`
+	AbilityName  string    `json:"ability_name" gorm:"type:varchar(128)"`
+	Amount       int32     `json:"amount"`
+	HPBefore     int32     `json:"hp_before"`
+	MaxHP        int32     `json:"max_hp"`
+	KillingBlow  bool      `json:"killing_blow"`
+}
+
+// GetKillingBlow - get the event that killed the player
+func (d *DeathRecap) GetKillingBlow() *DeathRecapEvent {
+	for index := range d.Events {
+		if d.Events[index].KillingBlow {
+			return &d.Events[index]
+		}
+	}
+	return nil
+}
+
+// ToBytes - Convert to bytes
+func (d *DeathRecap) ToBytes() []byte {
+	data := make([]byte, 1)
+	data[0] = DataTypeDeathRecap
+	writeString(&data, d.EncounterUID)
+	writeInt32(&data, d.TargetID)
+	writeString(&data, d.TargetName)
+	writeTime(&data, d.Time)
+	writeUint16(&data, uint16(len(d.Events)))
+	for _, event := range d.Events {
+		writeTime(&data, event.Time)
+		writeByte(&data, event.Type)
+		writeInt32(&data, event.SourceID)
+		writeString(&data, event.SourceName)
+		writeInt32(&data, event.AbilityID)
+		writeString(&data, event.AbilityName)
+		writeInt32(&data, event.Amount)
+		writeInt32(&data, event.HPBefore)
+		writeInt32(&data, event.MaxHP)
+		writeBool(&data, event.KillingBlow)
+	}
+	return data
+}
+
+// FromBytes - Convert bytes to death recap
+func (d *DeathRecap) FromBytes(data []byte) error {
+	if data[0] != DataTypeDeathRecap {
+		return errors.New("invalid data type for DeathRecap")
+	}
+	pos := 1
+	d.EncounterUID = readString(data, &pos)
+	d.TargetID = readInt32(data, &pos)
+	d.TargetName = readString(data, &pos)
+	d.Time = readTime(data, &pos)
+	eventCount := int(readUint16(data, &pos))
+	d.Events = make([]DeathRecapEvent, eventCount)
+	for index := range d.Events {
+		d.Events[index].Time = readTime(data, &pos)
+		d.Events[index].Type = readByte(data, &pos)
+		d.Events[index].SourceID = readInt32(data, &pos)
+		d.Events[index].SourceName = readString(data, &pos)
+		d.Events[index].AbilityID = readInt32(data, &pos)
+		d.Events[index].AbilityName = readString(data, &pos)
+		d.Events[index].Amount = readInt32(data, &pos)
+		d.Events[index].HPBefore = readInt32(data, &pos)
+		d.Events[index].MaxHP = readInt32(data, &pos)
+		d.Events[index].KillingBlow = readByte(data, &pos) != 0
+	}
+	return nil
+}
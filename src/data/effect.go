@@ -0,0 +1,42 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package data
+
+import "time"
+
+// Effect - Data about a status effect (buff/debuff) applied during an encounter
+type Effect struct {
+	EncounterUID string    `json:"encounter_uid"`
+	EffectID     int32     `json:"effect_id"`
+	Name         string    `json:"name"`
+	SourceID     int32     `json:"source_id"`
+	SourceName   string    `json:"source_name"`
+	TargetID     int32     `json:"target_id"`
+	TargetName   string    `json:"target_name"`
+	Stacks       int32     `json:"stacks"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"` // zero if effect has no known end
+}
+
+// IsActive - Check if effect was active at given time
+func (e *Effect) IsActive(t time.Time) bool {
+	if t.Before(e.StartTime) {
+		return false
+	}
+	return e.EndTime.IsZero() || t.Before(e.EndTime)
+}
@@ -39,6 +39,9 @@ type Encounter struct {
 	Active       bool      `json:"active"`
 	EndWait      bool      `json:"end_wait"`
 	SuccessLevel uint8     `json:"success_level"`
+	Pinned       bool      `json:"pinned" gorm:"not null;default:false"` // pinned encounters are never cleaned up
+	StorageRows  int64     `json:"storage_rows"`                         // database rows stored for encounter
+	StorageBytes int64     `json:"storage_bytes"`                        // file bytes stored for encounter
 }
 
 // ToBytes - Convert to bytes
@@ -0,0 +1,102 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package data
+
+import (
+	"errors"
+	"time"
+)
+
+// DataTypeEnemy - Data type, enemy data
+const DataTypeEnemy byte = 11
+
+// Enemy - Data about an enemy combatant in an encounter
+type Enemy struct {
+	ByteEncodable
+	ID            int64               `json:"-" gorm:"primary key;unique;AUTO_INCREMENT"`
+	UserID        int64               `json:"user_id"`
+	EncounterUID  string              `json:"encounter_uid" gorm:"type:varchar(32);index"`
+	CombatantID   int32               `json:"combatant_id"`
+	Name          string              `json:"name" gorm:"type:varchar(128)"`
+	MaxHP         int32               `json:"max_hp"`
+	FirstSeen     time.Time           `json:"first_seen"`
+	DefeatedTime  time.Time           `json:"defeated_time"` // zero if enemy was not defeated
+	DamageTaken   int64               `json:"damage_taken"`
+	DamageSources []EnemyDamageSource `json:"damage_sources" gorm:"foreignkey:EnemyID"`
+}
+
+// EnemyDamageSource - Damage dealt to an enemy by a single combatant
+type EnemyDamageSource struct {
+	ID         int64  `json:"-" gorm:"primary key;unique;AUTO_INCREMENT"`
+	EnemyID    int64  `json:"-" gorm:"index"`
+	SourceID   int32  `json:"source_id"`
+	SourceName string `json:"source_name" gorm:"type:varchar(128)"`
+	Damage     int64  `json:"damage"`
+}
+
+// IsDefeated - Check if enemy was defeated
+func (e *Enemy) IsDefeated() bool {
+	return !e.DefeatedTime.IsZero()
+}
+
+// ToBytes - Convert to bytes
+func (e *Enemy) ToBytes() []byte {
+	data := make([]byte, 1)
+	data[0] = DataTypeEnemy
+	writeString(&data, e.EncounterUID)
+	writeInt32(&data, e.CombatantID)
+	writeString(&data, e.Name)
+	writeInt32(&data, e.MaxHP)
+	writeTime(&data, e.FirstSeen)
+	writeBool(&data, e.IsDefeated())
+	writeTime(&data, e.DefeatedTime)
+	writeInt32(&data, int32(e.DamageTaken))
+	writeUint16(&data, uint16(len(e.DamageSources)))
+	for _, source := range e.DamageSources {
+		writeInt32(&data, source.SourceID)
+		writeString(&data, source.SourceName)
+		writeInt32(&data, int32(source.Damage))
+	}
+	return data
+}
+
+// FromBytes - Convert bytes to enemy
+func (e *Enemy) FromBytes(data []byte) error {
+	if data[0] != DataTypeEnemy {
+		return errors.New("invalid data type for Enemy")
+	}
+	pos := 1
+	e.EncounterUID = readString(data, &pos)
+	e.CombatantID = readInt32(data, &pos)
+	e.Name = readString(data, &pos)
+	e.MaxHP = readInt32(data, &pos)
+	e.FirstSeen = readTime(data, &pos)
+	defeated := readByte(data, &pos) != 0
+	e.DefeatedTime = readTime(data, &pos)
+	if !defeated {
+		e.DefeatedTime = time.Time{}
+	}
+	e.DamageTaken = int64(readInt32(data, &pos))
+	e.DamageSources = make([]EnemyDamageSource, int(readUint16(data, &pos)))
+	for index := range e.DamageSources {
+		e.DamageSources[index].SourceID = readInt32(data, &pos)
+		e.DamageSources[index].SourceName = readString(data, &pos)
+		e.DamageSources[index].Damage = int64(readInt32(data, &pos))
+	}
+	return nil
+}
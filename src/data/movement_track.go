@@ -0,0 +1,91 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package data
+
+import (
+	"errors"
+	"time"
+)
+
+// DataTypeMovementTrack - Data type, combatant movement track
+const DataTypeMovementTrack byte = 10
+
+// Position - Position and facing of a combatant
+type Position struct {
+	X       float32 `json:"x"`
+	Y       float32 `json:"y"`
+	Z       float32 `json:"z"`
+	Heading float32 `json:"heading"`
+}
+
+// PositionSample - Position of a combatant at a point in time
+type PositionSample struct {
+	Position
+	Time time.Time `json:"time"`
+}
+
+// MovementTrack - Sampled positions of a single combatant over an encounter
+type MovementTrack struct {
+	ByteEncodable
+	EncounterUID  string           `json:"encounter_uid"`
+	CombatantID   int32            `json:"combatant_id"`
+	CombatantName string           `json:"combatant_name"`
+	StartTime     time.Time        `json:"start_time"`
+	Samples       []PositionSample `json:"samples"`
+}
+
+// ToBytes - Convert to bytes, sample times are stored as ms offsets from start time
+func (m *MovementTrack) ToBytes() []byte {
+	data := make([]byte, 1)
+	data[0] = DataTypeMovementTrack
+	writeString(&data, m.EncounterUID)
+	writeInt32(&data, m.CombatantID)
+	writeString(&data, m.CombatantName)
+	writeTime(&data, m.StartTime)
+	writeUint16(&data, uint16(len(m.Samples)))
+	for _, sample := range m.Samples {
+		writeInt32(&data, int32(sample.Time.Sub(m.StartTime)/time.Millisecond))
+		writeFloat32(&data, sample.X)
+		writeFloat32(&data, sample.Y)
+		writeFloat32(&data, sample.Z)
+		writeFloat32(&data, sample.Heading)
+	}
+	return data
+}
+
+// FromBytes - Convert bytes to movement track
+func (m *MovementTrack) FromBytes(data []byte) error {
+	if data[0] != DataTypeMovementTrack {
+		return errors.New("invalid data type for MovementTrack")
+	}
+	pos := 1
+	m.EncounterUID = readString(data, &pos)
+	m.CombatantID = readInt32(data, &pos)
+	m.CombatantName = readString(data, &pos)
+	m.StartTime = readTime(data, &pos)
+	sampleCount := int(readUint16(data, &pos))
+	m.Samples = make([]PositionSample, sampleCount)
+	for index := range m.Samples {
+		m.Samples[index].Time = m.StartTime.Add(time.Duration(readInt32(data, &pos)) * time.Millisecond)
+		m.Samples[index].X = readFloat32(data, &pos)
+		m.Samples[index].Y = readFloat32(data, &pos)
+		m.Samples[index].Z = readFloat32(data, &pos)
+		m.Samples[index].Heading = readFloat32(data, &pos)
+	}
+	return nil
+}
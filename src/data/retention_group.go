@@ -0,0 +1,35 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package data
+
+// RetentionGroup - named encounter retention policy that users can be assigned to
+type RetentionGroup struct {
+	Name string `json:"name" gorm:"primary_key;type:varchar(64)"`
+	Days int    `json:"days"` // days to keep encounters for, negative keeps forever
+}
+
+// GetRetentionDays - get number of days to keep user's encounters for, negative keeps forever
+func (u *User) GetRetentionDays(groups map[string]int, defaultDays int) int {
+	if u.RetentionDays != 0 {
+		return u.RetentionDays
+	}
+	if days, ok := groups[u.RetentionGroup]; ok && u.RetentionGroup != "" && days != 0 {
+		return days
+	}
+	return defaultDays
+}
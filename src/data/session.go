@@ -32,6 +32,7 @@ const DataTypeSession byte = 1
 type Session struct {
 	ByteEncodable
 	UploadKey string
+	Language  string
 	IP        net.IP
 	Port      int
 	Created   time.Time
@@ -55,6 +56,10 @@ func (s *Session) FromBytes(data []byte) error {
 		return errors.New("version number mismatch")
 	}
 	s.UploadKey = readString(data, &pos)
+	// game client language, only sent by newer plugin versions
+	if pos < len(data) {
+		s.Language = readString(data, &pos)
+	}
 	return nil
 }
 
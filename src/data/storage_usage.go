@@ -0,0 +1,84 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package data
+
+import "fmt"
+
+// StorageUsage - storage used by an user's encounters and the user's quota
+type StorageUsage struct {
+	Encounters      int64 `json:"encounters"`
+	Rows            int64 `json:"rows"`
+	Bytes           int64 `json:"bytes"`
+	QuotaEncounters int64 `json:"quota_encounters"` // zero or less is unlimited
+	QuotaBytes      int64 `json:"quota_bytes"`      // zero or less is unlimited
+}
+
+// IsOverQuota - true if no more encounters can be stored
+func (s StorageUsage) IsOverQuota() bool {
+	return (s.QuotaEncounters > 0 && s.Encounters >= s.QuotaEncounters) ||
+		(s.QuotaBytes > 0 && s.Bytes >= s.QuotaBytes)
+}
+
+// ExceedsQuota - true if storing pending usage on top of current usage would go over quota
+func (s StorageUsage) ExceedsQuota(pending StorageUsage) bool {
+	return (s.QuotaEncounters > 0 && s.Encounters+pending.Encounters > s.QuotaEncounters) ||
+		(s.QuotaBytes > 0 && s.Bytes+pending.Bytes > s.QuotaBytes)
+}
+
+// BytesString - get human readable bytes used
+func (s StorageUsage) BytesString() string {
+	return formatBytes(s.Bytes)
+}
+
+// QuotaBytesString - get human readable bytes quota
+func (s StorageUsage) QuotaBytesString() string {
+	if s.QuotaBytes <= 0 {
+		return "unlimited"
+	}
+	return formatBytes(s.QuotaBytes)
+}
+
+// GetQuotaEncounters - get max number of encounters user can store, zero or less is unlimited
+func (u *User) GetQuotaEncounters(defaultEncounters int64) int64 {
+	if u.QuotaEncounters != 0 {
+		return u.QuotaEncounters
+	}
+	return defaultEncounters
+}
+
+// GetQuotaBytes - get max file bytes user can store, zero or less is unlimited
+func (u *User) GetQuotaBytes(defaultBytes int64) int64 {
+	if u.QuotaBytes != 0 {
+		return u.QuotaBytes
+	}
+	return defaultBytes
+}
+
+// formatBytes - format byte count with binary unit
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,162 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package data
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// DataTypeTimeSeries - Data type, time series data
+const DataTypeTimeSeries byte = 9
+
+// TimeSeries - Damage and healing done by a combatant, bucketed at a fixed resolution
+type TimeSeries struct {
+	ByteEncodable
+	ID            int64     `json:"-" gorm:"primary key;unique;AUTO_INCREMENT"`
+	UserID        int64     `json:"user_id"`
+	EncounterUID  string    `json:"encounter_uid" gorm:"type:varchar(32);index"`
+	CombatantID   int32     `json:"combatant_id"`
+	CombatantName string    `json:"combatant_name" gorm:"type:varchar(128)"`
+	OwnerID       int32     `json:"owner_id"` // set when combatant is a pet/summon
+	StartTime     time.Time `json:"start_time"`
+	Resolution    int32     `json:"resolution"`      // bucket size in ms
+	Offset        int32     `json:"offset" gorm:"-"` // index of first bucket, non zero when only sending recent buckets
+	Damage        []int32   `json:"damage" gorm:"-"`
+	Healing       []int32   `json:"healing" gorm:"-"`
+	Buckets       []byte    `json:"-"` // damage+healing buckets packed for storage
+}
+
+// Add - add damage/healing to the bucket containing given time
+func (s *TimeSeries) Add(t time.Time, damage int32, healing int32) int {
+	index := 0
+	if t.After(s.StartTime) && s.Resolution > 0 {
+		index = int(t.Sub(s.StartTime) / (time.Duration(s.Resolution) * time.Millisecond))
+	}
+	for len(s.Damage) <= index {
+		s.Damage = append(s.Damage, 0)
+		s.Healing = append(s.Healing, 0)
+	}
+	s.Damage[index] += damage
+	s.Healing[index] += healing
+	return index
+}
+
+// Slice - get copy of time series containing only buckets from given index onwards
+func (s *TimeSeries) Slice(index int) TimeSeries {
+	output := *s
+	if index > len(s.Damage) {
+		index = len(s.Damage)
+	}
+	output.Offset = int32(index)
+	output.Damage = append([]int32{}, s.Damage[index:]...)
+	output.Healing = append([]int32{}, s.Healing[index:]...)
+	output.Buckets = nil
+	return output
+}
+
+// BeforeSave - pack buckets before storing to database
+func (s *TimeSeries) BeforeSave() error {
+	s.Buckets = make([]byte, 0)
+	buf := make([]byte, binary.MaxVarintLen32)
+	for _, values := range [][]int32{s.Damage, s.Healing} {
+		n := binary.PutUvarint(buf, uint64(len(values)))
+		s.Buckets = append(s.Buckets, buf[:n]...)
+		for _, value := range values {
+			n = binary.PutVarint(buf, int64(value))
+			s.Buckets = append(s.Buckets, buf[:n]...)
+		}
+	}
+	return nil
+}
+
+// AfterFind - unpack buckets after fetching from database
+func (s *TimeSeries) AfterFind() error {
+	if len(s.Buckets) == 0 {
+		s.Damage = make([]int32, 0)
+		s.Healing = make([]int32, 0)
+		return nil
+	}
+	pos := 0
+	unpack := func() ([]int32, error) {
+		count, n := binary.Uvarint(s.Buckets[pos:])
+		if n <= 0 {
+			return nil, errors.New("invalid time series bucket data")
+		}
+		pos += n
+		values := make([]int32, 0, count)
+		for index := uint64(0); index < count; index++ {
+			value, n := binary.Varint(s.Buckets[pos:])
+			if n <= 0 {
+				return nil, errors.New("invalid time series bucket data")
+			}
+			pos += n
+			values = append(values, int32(value))
+		}
+		return values, nil
+	}
+	var err error
+	if s.Damage, err = unpack(); err != nil {
+		return err
+	}
+	s.Healing, err = unpack()
+	return err
+}
+
+// ToBytes - Convert to bytes
+func (s *TimeSeries) ToBytes() []byte {
+	data := make([]byte, 1)
+	data[0] = DataTypeTimeSeries
+	writeString(&data, s.EncounterUID)
+	writeInt32(&data, s.CombatantID)
+	writeString(&data, s.CombatantName)
+	writeInt32(&data, s.OwnerID)
+	writeTime(&data, s.StartTime)
+	writeInt32(&data, s.Resolution)
+	writeInt32(&data, s.Offset)
+	writeUint16(&data, uint16(len(s.Damage)))
+	for index := range s.Damage {
+		writeInt32(&data, s.Damage[index])
+		writeInt32(&data, s.Healing[index])
+	}
+	return data
+}
+
+// FromBytes - Convert bytes to time series
+func (s *TimeSeries) FromBytes(data []byte) error {
+	if data[0] != DataTypeTimeSeries {
+		return errors.New("invalid data type for TimeSeries")
+	}
+	pos := 1
+	s.EncounterUID = readString(data, &pos)
+	s.CombatantID = readInt32(data, &pos)
+	s.CombatantName = readString(data, &pos)
+	s.OwnerID = readInt32(data, &pos)
+	s.StartTime = readTime(data, &pos)
+	s.Resolution = readInt32(data, &pos)
+	s.Offset = readInt32(data, &pos)
+	count := int(readUint16(data, &pos))
+	s.Damage = make([]int32, count)
+	s.Healing = make([]int32, count)
+	for index := 0; index < count; index++ {
+		s.Damage[index] = readInt32(data, &pos)
+		s.Healing[index] = readInt32(data, &pos)
+	}
+	return nil
+}
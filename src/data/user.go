@@ -35,6 +35,10 @@ type User struct {
 	UploadKey       string `gorm:"unique;not null;type:varchar(32)"` // key used to push data from ACT
 	WebKey          string `gorm:"unique;not null;type:varchar(32)"` // key used to access creds via homepage (stored in cookie)
 	FFToolsUID      string `gorm:"index;type:varchar(32)"`
+	RetentionDays   int    // days to keep encounters for, zero uses retention group, negative keeps forever
+	RetentionGroup  string `gorm:"type:varchar(64)"`
+	QuotaEncounters int64  // max encounters stored, zero uses default quota, negative is unlimited
+	QuotaBytes      int64  // max log file bytes stored, zero uses default quota, negative is unlimited
 	FFToolsUsername string `gorm:"-"`
 	webIDHash       string `gorm:"-"`
 }
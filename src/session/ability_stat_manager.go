@@ -0,0 +1,201 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"fmt"
+	"sync"
+
+	"../app"
+	"../data"
+)
+
+// AbilityStatManager - accumulates per player, per ability damage and healing totals
+type AbilityStatManager struct {
+	stats        []*data.AbilityStat
+	statMap      map[string]*data.AbilityStat
+	updated      map[string]*data.AbilityStat
+	lock         *sync.Mutex
+	log          app.Logging
+	encounterUID string
+}
+
+// NewAbilityStatManager - create new ability stat manager
+func NewAbilityStatManager() AbilityStatManager {
+	a := AbilityStatManager{
+		log:  app.Logging{ModuleName: "ABILITY"},
+		lock: &sync.Mutex{},
+	}
+	a.Reset()
+	return a
+}
+
+// Reset - reset ability stat manager
+func (a *AbilityStatManager) Reset() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.stats = make([]*data.AbilityStat, 0)
+	a.statMap = make(map[string]*data.AbilityStat)
+	a.updated = make(map[string]*data.AbilityStat)
+	a.encounterUID = ""
+}
+
+// ResetEncounter - reset with new encounter data
+func (a *AbilityStatManager) ResetEncounter(encounter data.Encounter) {
+	a.Reset()
+	a.encounterUID = encounter.UID
+}
+
+// abilityStatKey - get map key for given combatant, ability and stat type
+func abilityStatKey(combatantID int32, abilityID int32, statType uint8) string {
+	return fmt.Sprintf("%d-%d-%d", combatantID, abilityID, statType)
+}
+
+// ReadLogLine - parse log line and update ability stats
+func (a *AbilityStatManager) ReadLogLine(l *ParsedLogLine) {
+	if l.Type != LogTypeSingleTarget && l.Type != LogTypeAoe {
+		return
+	}
+	// only track players and their pets
+	combatantID, combatantName, ownerID, ok := getActor(l)
+	if !ok {
+		return
+	}
+	statType := data.AbilityStatTypeDamage
+	if l.HasFlag(LogFlagHeal) {
+		statType = data.AbilityStatTypeHeal
+	} else if !l.HasFlag(LogFlagDamage) {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	key := abilityStatKey(combatantID, int32(l.AbilityID), statType)
+	stat := a.statMap[key]
+	if stat == nil {
+		stat = &data.AbilityStat{
+			EncounterUID:  a.encounterUID,
+			CombatantID:   combatantID,
+			CombatantName: combatantName,
+			OwnerID:       ownerID,
+			AbilityID:     int32(l.AbilityID),
+			AbilityName:   l.AbilityName,
+			Type:          statType,
+		}
+		a.stats = append(a.stats, stat)
+		a.statMap[key] = stat
+	}
+	amount := int32(l.Damage)
+	stat.Total += amount
+	stat.Hits++
+	if l.HasFlag(LogFlagCrit) {
+		stat.Crits++
+	}
+	if l.HasFlag(LogFlagDirectHit) {
+		stat.DirectHits++
+	}
+	if amount > stat.MaxHit {
+		stat.MaxHit = amount
+	}
+	stat.Time = l.Time
+	a.updated[key] = stat
+}
+
+// GetAbilityStats - get all ability stats in encounter
+func (a *AbilityStatManager) GetAbilityStats() []data.AbilityStat {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	output := make([]data.AbilityStat, 0)
+	for index := range a.stats {
+		output = append(output, *a.stats[index])
+	}
+	return output
+}
+
+// GetAbilityStatsForCombatant - get ability stats for a single combatant, including their pets
+func (a *AbilityStatManager) GetAbilityStatsForCombatant(combatantID int32) []data.AbilityStat {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	output := make([]data.AbilityStat, 0)
+	for index := range a.stats {
+		if a.stats[index].CombatantID == combatantID || a.stats[index].OwnerID == combatantID {
+			output = append(output, *a.stats[index])
+		}
+	}
+	return output
+}
+
+// MergePetAbilityStats - credit pet ability stats to their owners
+func MergePetAbilityStats(stats []data.AbilityStat) []data.AbilityStat {
+	output := make([]data.AbilityStat, 0)
+	ownerNames := make(map[int32]string)
+	for _, stat := range stats {
+		if stat.OwnerID == 0 {
+			ownerNames[stat.CombatantID] = stat.CombatantName
+		}
+	}
+	outputMap := make(map[string]int)
+	for _, stat := range stats {
+		if stat.OwnerID != 0 {
+			stat.CombatantID = stat.OwnerID
+			stat.CombatantName = ownerNames[stat.OwnerID]
+			stat.OwnerID = 0
+		}
+		key := abilityStatKey(stat.CombatantID, stat.AbilityID, stat.Type)
+		index, exists := outputMap[key]
+		if !exists {
+			outputMap[key] = len(output)
+			output = append(output, stat)
+			continue
+		}
+		output[index].Total += stat.Total
+		output[index].Hits += stat.Hits
+		output[index].Crits += stat.Crits
+		output[index].DirectHits += stat.DirectHits
+		if stat.MaxHit > output[index].MaxHit {
+			output[index].MaxHit = stat.MaxHit
+		}
+		if stat.Time.After(output[index].Time) {
+			output[index].Time = stat.Time
+		}
+	}
+	return output
+}
+
+// SetAbilityStats - set ability stats, used when loading previous encounter
+func (a *AbilityStatManager) SetAbilityStats(stats []data.AbilityStat) {
+	a.Reset()
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for index := range stats {
+		a.encounterUID = stats[index].EncounterUID
+		a.stats = append(a.stats, &stats[index])
+		a.statMap[abilityStatKey(stats[index].CombatantID, stats[index].AbilityID, stats[index].Type)] = &stats[index]
+	}
+}
+
+// Dump - get ability stats that have changed since last dump
+func (a *AbilityStatManager) Dump() []data.AbilityStat {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	output := make([]data.AbilityStat, 0)
+	for key := range a.updated {
+		output = append(output, *a.updated[key])
+	}
+	a.updated = make(map[string]*data.AbilityStat)
+	return output
+}
@@ -0,0 +1,242 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"../app"
+	times "gopkg.in/djherbis/times.v1"
+)
+
+// ErrBlobNotExist - error returned when a blob does not exist in storage
+var ErrBlobNotExist = errors.New("blob does not exist")
+
+// BlobInfo - information about a stored blob
+type BlobInfo struct {
+	Key  string
+	Size int64
+	Time time.Time // time blob was created, or last modified when creation time is unknown
+}
+
+// BlobWriter - writes a blob, blob is stored when closed
+type BlobWriter interface {
+	io.WriteCloser
+	// Discard - stop writing without storing blob
+	Discard() error
+}
+
+// BlobStorage - stores encounter files by key
+type BlobStorage interface {
+	// Create - create or replace blob, blob is only stored once writer is closed
+	Create(key string) (BlobWriter, error)
+	// Open - read blob starting at given byte offset
+	Open(key string, offset int64) (io.ReadCloser, error)
+	// Stat - get information about blob
+	Stat(key string) (BlobInfo, error)
+	// Delete - delete blob, deleting a blob that does not exist is not an error
+	Delete(key string) error
+	// List - list blobs with keys starting with prefix, sorted by key
+	List(prefix string) ([]BlobInfo, error)
+}
+
+// blobStorage - storage used for encounter files
+var blobStorage BlobStorage = NewLocalBlobStorage(app.FileStorePath)
+
+// GetBlobStorage - get storage used for encounter files
+func GetBlobStorage() BlobStorage {
+	return blobStorage
+}
+
+// SetBlobStorage - set storage used for encounter files, must be set before any sessions are created
+func SetBlobStorage(storage BlobStorage) {
+	blobStorage = storage
+}
+
+// GetBlobEncounterUID - get uid of encounter blob key belongs to, empty if not an encounter file
+func GetBlobEncounterUID(key string) string {
+	if !strings.HasPrefix(key, "fflp_") {
+		return ""
+	}
+	name := strings.TrimPrefix(key, "fflp_")
+	index := strings.LastIndex(name, "_")
+	if index <= 0 {
+		return ""
+	}
+	return name[:index]
+}
+
+// NewBlobStorageFromConfig - create storage for backend set in environment
+func NewBlobStorageFromConfig() (BlobStorage, error) {
+	switch app.GetStorageBackend() {
+	case "", "local":
+		{
+			return NewLocalBlobStorage(app.FileStorePath), nil
+		}
+	case "s3":
+		{
+			return NewS3BlobStorage(
+				app.GetS3Endpoint(),
+				app.GetS3Bucket(),
+				app.GetS3Region(),
+				app.GetS3AccessKey(),
+				app.GetS3SecretKey(),
+			)
+		}
+	}
+	return nil, fmt.Errorf("unknown storage backend '%s'", app.GetStorageBackend())
+}
+
+// LocalBlobStorage - stores blobs as files in a local directory
+type LocalBlobStorage struct {
+	Path string
+}
+
+// NewLocalBlobStorage - create new local storage in given directory
+func NewLocalBlobStorage(storePath string) *LocalBlobStorage {
+	return &LocalBlobStorage{Path: storePath}
+}
+
+// localBlobWriter - writes blob to temp file that replaces the blob when closed
+type localBlobWriter struct {
+	*os.File
+	blobPath string
+}
+
+// Close - close temp file and move it in to place
+func (w *localBlobWriter) Close() error {
+	err := w.File.Close()
+	if err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	return os.Rename(w.File.Name(), w.blobPath)
+}
+
+// Discard - close and remove temp file
+func (w *localBlobWriter) Discard() error {
+	w.File.Close()
+	return os.Remove(w.File.Name())
+}
+
+// blobPath - get path to file for blob key
+func (s *LocalBlobStorage) blobPath(key string) string {
+	return path.Join(s.Path, path.Clean("/"+key))
+}
+
+// Create - create or replace blob
+func (s *LocalBlobStorage) Create(key string) (BlobWriter, error) {
+	blobPath := s.blobPath(key)
+	err := os.MkdirAll(path.Dir(blobPath), 0775)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ioutil.TempFile(path.Dir(blobPath), ".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &localBlobWriter{File: f, blobPath: blobPath}, nil
+}
+
+// Open - read blob starting at given byte offset
+func (s *LocalBlobStorage) Open(key string, offset int64) (io.ReadCloser, error) {
+	f, err := os.Open(s.blobPath(key))
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotExist
+	} else if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		_, err = f.Seek(offset, 0)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Stat - get information about blob
+func (s *LocalBlobStorage) Stat(key string) (BlobInfo, error) {
+	info, err := os.Stat(s.blobPath(key))
+	if os.IsNotExist(err) {
+		return BlobInfo{}, ErrBlobNotExist
+	} else if err != nil {
+		return BlobInfo{}, err
+	}
+	return s.blobInfo(key, s.blobPath(key), info), nil
+}
+
+// blobInfo - build blob info from file info, prefers file creation time
+func (s *LocalBlobStorage) blobInfo(key string, blobPath string, info os.FileInfo) BlobInfo {
+	blobInfo := BlobInfo{
+		Key:  key,
+		Size: info.Size(),
+		Time: info.ModTime(),
+	}
+	t, err := times.Stat(blobPath)
+	if err == nil && t.HasBirthTime() && t.BirthTime().Before(blobInfo.Time) {
+		blobInfo.Time = t.BirthTime()
+	}
+	return blobInfo
+}
+
+// Delete - delete blob
+func (s *LocalBlobStorage) Delete(key string) error {
+	err := os.Remove(s.blobPath(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// List - list blobs with keys starting with prefix
+func (s *LocalBlobStorage) List(prefix string) ([]BlobInfo, error) {
+	output := make([]BlobInfo, 0)
+	err := filepath.Walk(s.Path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && filePath == s.Path {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-") {
+			return nil
+		}
+		key, err := filepath.Rel(s.Path, filePath)
+		if err != nil {
+			return err
+		}
+		key = filepath.ToSlash(key)
+		if strings.HasPrefix(key, prefix) {
+			output = append(output, s.blobInfo(key, filePath, info))
+		}
+		return nil
+	})
+	sort.Slice(output, func(i, j int) bool { return output[i].Key < output[j].Key })
+	return output, err
+}
@@ -0,0 +1,325 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3EmptyPayloadHash - sha256 of an empty request body
+const s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3BlobStorage - stores blobs in a bucket of an S3 compatible object storage, requests use path style urls
+type S3BlobStorage struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3BlobStorage - create new S3 storage
+func NewS3BlobStorage(endpoint string, bucket string, region string, accessKey string, secretKey string) (*S3BlobStorage, error) {
+	if endpoint == "" || bucket == "" {
+		return nil, fmt.Errorf("s3 storage needs an endpoint and bucket")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3BlobStorage{
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Bucket:    bucket,
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		client:    &http.Client{Timeout: 5 * time.Minute},
+		now:       time.Now,
+	}, nil
+}
+
+// s3Error - error response from S3
+type s3Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	return fmt.Sprintf("s3 request failed with status %d: %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+// s3ListBucketResult - response of a ListObjectsV2 request
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// s3BlobWriter - buffers blob in memory and uploads it when closed
+type s3BlobWriter struct {
+	bytes.Buffer
+	storage *S3BlobStorage
+	key     string
+}
+
+// Close - upload blob
+func (w *s3BlobWriter) Close() error {
+	res, err := w.storage.do("PUT", w.key, nil, nil, w.Bytes())
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// Discard - drop buffered blob
+func (w *s3BlobWriter) Discard() error {
+	w.Reset()
+	return nil
+}
+
+// Create - create or replace blob
+func (s *S3BlobStorage) Create(key string) (BlobWriter, error) {
+	return &s3BlobWriter{storage: s, key: key}, nil
+}
+
+// Open - read blob starting at given byte offset
+func (s *S3BlobStorage) Open(key string, offset int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	res, err := s.do("GET", key, nil, header, nil)
+	if err, ok := err.(*s3Error); ok && err.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// offset is at or past the end of the blob
+		return ioutil.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// Stat - get information about blob
+func (s *S3BlobStorage) Stat(key string) (BlobInfo, error) {
+	res, err := s.do("HEAD", key, nil, nil, nil)
+	if err != nil {
+		return BlobInfo{}, err
+	}
+	res.Body.Close()
+	info := BlobInfo{
+		Key:  key,
+		Size: res.ContentLength,
+	}
+	info.Time, _ = http.ParseTime(res.Header.Get("Last-Modified"))
+	return info, nil
+}
+
+// Delete - delete blob
+func (s *S3BlobStorage) Delete(key string) error {
+	res, err := s.do("DELETE", key, nil, nil, nil)
+	if err == ErrBlobNotExist {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// List - list blobs with keys starting with prefix
+func (s *S3BlobStorage) List(prefix string) ([]BlobInfo, error) {
+	output := make([]BlobInfo, 0)
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+	for {
+		res, err := s.do("GET", "", query, nil, nil)
+		if err != nil {
+			return output, err
+		}
+		result := s3ListBucketResult{}
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return output, err
+		}
+		for _, object := range result.Contents {
+			output = append(output, BlobInfo{
+				Key:  object.Key,
+				Size: object.Size,
+				Time: object.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+	sort.Slice(output, func(i, j int) bool { return output[i].Key < output[j].Key })
+	return output, nil
+}
+
+// do - perform signed request against bucket, key is empty for bucket requests
+func (s *S3BlobStorage) do(method string, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	requestURL := s.Endpoint + "/" + s3URIEncode(s.Bucket, false)
+	if key != "" {
+		requestURL += "/" + s3URIEncode(key, false)
+	}
+	if len(query) > 0 {
+		requestURL += "?" + s3CanonicalQuery(query)
+	}
+	req, err := http.NewRequest(method, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name := range header {
+		req.Header.Set(name, header.Get(name))
+	}
+	s.sign(req, body)
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound && key != "" {
+		return nil, ErrBlobNotExist
+	}
+	s3Err := &s3Error{StatusCode: res.StatusCode}
+	xml.NewDecoder(res.Body).Decode(s3Err)
+	return nil, s3Err
+}
+
+// sign - add AWS signature version 4 headers to request
+func (s *S3BlobStorage) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", now.Format("20060102"), s.Region)
+	payloadHash := s3EmptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	// canonical headers, host plus all amz and range headers
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lowerName := strings.ToLower(name)
+		if strings.HasPrefix(lowerName, "x-amz-") || lowerName == "range" {
+			headers[lowerName] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	canonicalHeaders := ""
+	for _, name := range headerNames {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+	signingKey := s3HMAC([]byte("AWS4"+s.SecretKey), now.Format("20060102"))
+	signingKey = s3HMAC(signingKey, s.Region)
+	signingKey = s3HMAC(signingKey, "s3")
+	signingKey = s3HMAC(signingKey, "aws4_request")
+	signature := hex.EncodeToString(s3HMAC(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// s3HMAC - hmac sha256 of data with key
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3URIEncode - uri encode string as required by AWS signatures, slashes are kept unless encodeSlash is set
+func s3URIEncode(value string, encodeSlash bool) string {
+	var output strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9'),
+			b == '-', b == '_', b == '.', b == '~', b == '/' && !encodeSlash:
+			{
+				output.WriteByte(b)
+			}
+		default:
+			{
+				fmt.Fprintf(&output, "%%%02X", b)
+			}
+		}
+	}
+	return output.String()
+}
+
+// s3CanonicalQuery - encode query string sorted by name as required by AWS signatures
+func s3CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, s3URIEncode(name, true)+"="+s3URIEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,179 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"sync"
+	"time"
+
+	"../app"
+	"../data"
+)
+
+// CastManager - tracks cast timeline of all combatants in an encounter
+type CastManager struct {
+	casts        []*data.Cast
+	updated      []*data.Cast
+	lock         *sync.Mutex
+	log          app.Logging
+	encounterUID string
+}
+
+// NewCastManager - create new cast manager
+func NewCastManager() CastManager {
+	c := CastManager{
+		log:  app.Logging{ModuleName: "CAST"},
+		lock: &sync.Mutex{},
+	}
+	c.Reset()
+	return c
+}
+
+// Reset - reset cast manager
+func (c *CastManager) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.casts = make([]*data.Cast, 0)
+	c.updated = make([]*data.Cast, 0)
+	c.encounterUID = ""
+}
+
+// ResetEncounter - reset with new encounter data
+func (c *CastManager) ResetEncounter(encounter data.Encounter) {
+	c.Reset()
+	c.encounterUID = encounter.UID
+}
+
+// getActiveCast - get in progress cast for given combatant
+func (c *CastManager) getActiveCast(combatantID int32) *data.Cast {
+	for index := len(c.casts) - 1; index >= 0; index-- {
+		if c.casts[index].CombatantID == combatantID {
+			if c.casts[index].Status == data.CastStatusCasting {
+				return c.casts[index]
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// endCast - flag cast as ended with given status
+func (c *CastManager) endCast(cast *data.Cast, status uint8, t time.Time) {
+	cast.Status = status
+	cast.EndTime = t
+	c.updated = append(c.updated, cast)
+}
+
+// ReadLogLine - parse log line and update cast timeline
+func (c *CastManager) ReadLogLine(l *ParsedLogLine) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch l.Type {
+	case LogTypeCastStart:
+		{
+			// previous cast by the same combatant ended without a log line
+			if cast := c.getActiveCast(int32(l.AttackerID)); cast != nil {
+				status := data.CastStatusCancelled
+				if !l.Time.Before(cast.StartTime.Add(time.Duration(cast.Duration) * time.Millisecond)) {
+					status = data.CastStatusComplete
+				}
+				c.endCast(cast, status, l.Time)
+			}
+			cast := &data.Cast{
+				EncounterUID:  c.encounterUID,
+				CombatantID:   int32(l.AttackerID),
+				CombatantName: l.AttackerName,
+				AbilityID:     int32(l.AbilityID),
+				AbilityName:   l.AbilityName,
+				TargetID:      int32(l.TargetID),
+				TargetName:    l.TargetName,
+				StartTime:     l.Time,
+				Duration:      int32(l.Duration / time.Millisecond),
+				Status:        data.CastStatusCasting,
+			}
+			c.casts = append(c.casts, cast)
+			c.updated = append(c.updated, cast)
+			break
+		}
+	case LogTypeSingleTarget, LogTypeAoe:
+		{
+			cast := c.getActiveCast(int32(l.AttackerID))
+			if cast == nil || cast.AbilityID != int32(l.AbilityID) {
+				break
+			}
+			c.endCast(cast, data.CastStatusComplete, l.Time)
+			break
+		}
+	case LogTypeCastCancel:
+		{
+			cast := c.getActiveCast(int32(l.AttackerID))
+			if cast == nil || cast.AbilityID != int32(l.AbilityID) {
+				break
+			}
+			status := data.CastStatusCancelled
+			if l.HasFlag(LogFlagInterrupted) {
+				status = data.CastStatusInterrupted
+			}
+			c.endCast(cast, status, l.Time)
+			break
+		}
+	case LogTypeDefeat, LogTypeRemoveCombatant:
+		{
+			for index := range c.casts {
+				if c.casts[index].Status == data.CastStatusCasting && c.casts[index].CombatantName == l.TargetName {
+					c.endCast(c.casts[index], data.CastStatusCancelled, l.Time)
+				}
+			}
+			break
+		}
+	}
+}
+
+// GetCasts - get all casts in encounter
+func (c *CastManager) GetCasts() []data.Cast {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	output := make([]data.Cast, 0)
+	for index := range c.casts {
+		output = append(output, *c.casts[index])
+	}
+	return output
+}
+
+// SetCasts - set casts, used when loading previous encounter
+func (c *CastManager) SetCasts(casts []data.Cast) {
+	c.Reset()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for index := range casts {
+		c.encounterUID = casts[index].EncounterUID
+		c.casts = append(c.casts, &casts[index])
+	}
+}
+
+// Dump - get casts that have started or ended since last dump
+func (c *CastManager) Dump() []data.Cast {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	output := make([]data.Cast, 0)
+	for index := range c.updated {
+		output = append(output, *c.updated[index])
+	}
+	c.updated = make([]*data.Cast, 0)
+	return output
+}
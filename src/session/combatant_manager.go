@@ -27,10 +27,15 @@ import (
 // combatantManagerUpdateInterval - rate at which combatant manager will accept new combatants
 const combatantManagerUpdateInterval = 2500
 
+// combatantMaxPlayerID - combatant ids above this value are non player combatants (enemies, pets, etc)
+const combatantMaxPlayerID = 1000000000
+
 // CombatantManager - handles combatant data for an encounter
 type CombatantManager struct {
 	combatants         []*data.Combatant
 	lastEncounter      map[int32]*data.Combatant
+	dotDamage          map[int32]int32
+	hotHealed          map[int32]int32
 	log                app.Logging
 	encounterUID       string
 	encounterStartTime time.Time
@@ -51,6 +56,8 @@ func NewCombatantManager() CombatantManager {
 func (c *CombatantManager) Reset() {
 	c.combatants = make([]*data.Combatant, 0)
 	c.lastEncounter = make(map[int32]*data.Combatant, 0)
+	c.dotDamage = make(map[int32]int32)
+	c.hotHealed = make(map[int32]int32)
 	c.lastUpdate = time.Time{}
 	c.encounterUID = ""
 }
@@ -77,7 +84,7 @@ func (c *CombatantManager) ResetEncounter(encounter data.Encounter) {
 // Update - add a combatant update
 func (c *CombatantManager) Update(combatant data.Combatant) {
 	// ignore non player combatants
-	if combatant.Player.ID > 1000000000 {
+	if combatant.Player.ID > combatantMaxPlayerID {
 		return
 	}
 	// combatant update before encounter start time
@@ -120,6 +127,9 @@ func (c *CombatantManager) Update(combatant data.Combatant) {
 			combatant.Player.World = lastCombatant.Player.World
 		}
 	}
+	// carry over tick totals, these are tracked by the server rather than act
+	combatant.DotDamage = c.dotDamage[combatant.Player.ID]
+	combatant.HotHealed = c.hotHealed[combatant.Player.ID]
 	// not enough time passed, update the last combatant with this new data
 	if lastCombatant != nil && combatant.Time.Sub(lastCombatant.Time) < time.Millisecond*combatantManagerUpdateInterval {
 		lastCombatant = &combatant
@@ -148,6 +158,31 @@ func (c *CombatantManager) ReadLogLine(l *ParsedLogLine) {
 			}
 			break
 		}
+	case LogTypeDot:
+		{
+			// tick must have been attributed to a combatant
+			if l.AttackerID == 0 {
+				break
+			}
+			playerID := int32(l.AttackerID)
+			if l.HasFlag(LogFlagHeal) {
+				c.hotHealed[playerID] += int32(l.Damage)
+			} else {
+				c.dotDamage[playerID] += int32(l.Damage)
+			}
+			// update latest snapshot for player
+			var lastCombatant *data.Combatant
+			for index := range c.combatants {
+				if c.combatants[index].Player.ID == playerID && (lastCombatant == nil || c.combatants[index].Time.After(lastCombatant.Time)) {
+					lastCombatant = c.combatants[index]
+				}
+			}
+			if lastCombatant != nil {
+				lastCombatant.DotDamage = c.dotDamage[playerID]
+				lastCombatant.HotHealed = c.hotHealed[playerID]
+			}
+			break
+		}
 	case LogTypeGameLog:
 		{
 			switch l.GameLogType {
@@ -0,0 +1,233 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"math"
+	"sync"
+
+	"../app"
+	"../data"
+)
+
+// raidBuff - damage increase provided by a raid buff
+type raidBuff struct {
+	Multiplier float64 // damage multiplier
+	Rate       bool    // crit/direct hit rate buff, multiplier is an estimate of the average increase
+	Debuff     bool    // applied to the enemy rather than the party
+}
+
+// raidBuffs - known raid buffs, keyed by status name, potencies are
+// from Endwalker (6.x), older expansions' buffs are not tracked
+var raidBuffs = map[string]raidBuff{
+	"Arcane Circle":    {Multiplier: 1.03},
+	"Battle Litany":    {Multiplier: 1.05, Rate: true}, // 10% crit rate
+	"Battle Voice":     {Multiplier: 1.05, Rate: true}, // 20% direct hit rate
+	"Brotherhood":      {Multiplier: 1.05},
+	"Chain Stratagem":  {Multiplier: 1.05, Rate: true, Debuff: true}, // 10% crit rate
+	"Divination":       {Multiplier: 1.06},
+	"Embolden":         {Multiplier: 1.05},
+	"Left Eye":         {Multiplier: 1.05},
+	"Radiant Finale":   {Multiplier: 1.06},
+	"Searing Light":    {Multiplier: 1.03},
+	"Technical Finish": {Multiplier: 1.05},
+	"Vulnerability Up": {Multiplier: 1.05, Debuff: true},
+}
+
+// ContributionManager - attributes the share of each player's damage that came from other players' raid buffs
+type ContributionManager struct {
+	contributions []*data.Contribution
+	lock          *sync.Mutex
+	log           app.Logging
+	encounterUID  string
+}
+
+// NewContributionManager - create new contribution manager
+func NewContributionManager() ContributionManager {
+	c := ContributionManager{
+		log:  app.Logging{ModuleName: "CONTRIBUTION"},
+		lock: &sync.Mutex{},
+	}
+	c.Reset()
+	return c
+}
+
+// Reset - reset contribution manager
+func (c *ContributionManager) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.contributions = make([]*data.Contribution, 0)
+	c.encounterUID = ""
+}
+
+// ResetEncounter - reset with new encounter data
+func (c *ContributionManager) ResetEncounter(encounter data.Encounter) {
+	c.Reset()
+	c.encounterUID = encounter.UID
+}
+
+// getContribution - get contribution for given combatant, create if it doesn't exist
+func (c *ContributionManager) getContribution(combatantID int32, combatantName string) *data.Contribution {
+	for index := range c.contributions {
+		if c.contributions[index].CombatantID == combatantID {
+			return c.contributions[index]
+		}
+	}
+	contribution := &data.Contribution{
+		EncounterUID:  c.encounterUID,
+		CombatantID:   combatantID,
+		CombatantName: combatantName,
+	}
+	c.contributions = append(c.contributions, contribution)
+	return contribution
+}
+
+// getBuffs - get raid buffs from other players affecting given damage log line
+func (c *ContributionManager) getBuffs(l *ParsedLogLine, effects *EffectManager) ([]data.Effect, []raidBuff) {
+	outEffects := make([]data.Effect, 0)
+	outBuffs := make([]raidBuff, 0)
+	if effects == nil {
+		return outEffects, outBuffs
+	}
+	activeEffects := append(
+		effects.GetActiveEffectsOnTarget(int32(l.AttackerID), l.Time),
+		effects.GetActiveEffectsOnTarget(int32(l.TargetID), l.Time)...,
+	)
+	for _, effect := range activeEffects {
+		buff, exists := raidBuffs[effect.Name]
+		if !exists {
+			continue
+		}
+		// buffs must be on the attacker, debuffs on the target
+		if buff.Debuff != (effect.TargetID == int32(l.TargetID)) {
+			continue
+		}
+		// must come from another player, pets don't benefit from their owner's buffs
+		if effect.SourceID == int32(l.AttackerID) || effect.SourceID == int32(l.AttackerOwnerID) || effect.SourceID <= 0 || effect.SourceID > combatantMaxPlayerID {
+			continue
+		}
+		outEffects = append(outEffects, effect)
+		outBuffs = append(outBuffs, buff)
+	}
+	return outEffects, outBuffs
+}
+
+// ReadLogLine - parse log line and attribute buffed damage, effects are
+// passed in so the manager always reads the encounter's current effect timeline
+func (c *ContributionManager) ReadLogLine(l *ParsedLogLine, effects *EffectManager) {
+	if l.Type != LogTypeSingleTarget && l.Type != LogTypeAoe && l.Type != LogTypeDot {
+		return
+	}
+	if !l.HasFlag(LogFlagDamage) || l.Damage <= 0 {
+		return
+	}
+	// only track players and their pets, dot ticks must have been attributed
+	combatantID, combatantName, ownerID, ok := getActor(l)
+	if !ok {
+		return
+	}
+	buffEffects, buffs := c.getBuffs(l, effects)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	attacker := c.getContribution(combatantID, combatantName)
+	attacker.OwnerID = ownerID
+	if ownerID != 0 && l.AttackerOwnerName != "" {
+		attacker.OwnerName = l.AttackerOwnerName
+	}
+	attacker.Damage += int64(l.Damage)
+	attacker.Time = l.Time
+	if len(buffs) == 0 {
+		return
+	}
+	// portion of damage that came from buffs
+	multiplier := 1.0
+	for _, buff := range buffs {
+		multiplier *= buff.Multiplier
+	}
+	buffedDamage := float64(l.Damage) - (float64(l.Damage) / multiplier)
+	attacker.DamageReceived += int64(math.Round(buffedDamage))
+	// split between buffers by each buff's share of the total multiplier
+	for index, buff := range buffs {
+		share := buffedDamage * (math.Log(buff.Multiplier) / math.Log(multiplier))
+		source := c.getContribution(buffEffects[index].SourceID, buffEffects[index].SourceName)
+		source.DamageGiven += int64(math.Round(share))
+		source.Time = l.Time
+		if buff.Rate {
+			attacker.Approximate = true
+			source.Approximate = true
+		}
+	}
+}
+
+// GetContributions - get all contributions in encounter
+func (c *ContributionManager) GetContributions() []data.Contribution {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	output := make([]data.Contribution, 0)
+	for index := range c.contributions {
+		output = append(output, *c.contributions[index])
+	}
+	return output
+}
+
+// MergePetContributions - credit pet contributions to their owners
+func MergePetContributions(contributions []data.Contribution) []data.Contribution {
+	output := make([]data.Contribution, 0)
+	outputMap := make(map[int32]int)
+	// owners first so pets merge in to the owner's row
+	for _, contribution := range contributions {
+		if contribution.OwnerID == 0 {
+			outputMap[contribution.CombatantID] = len(output)
+			output = append(output, contribution)
+		}
+	}
+	for _, contribution := range contributions {
+		if contribution.OwnerID == 0 {
+			continue
+		}
+		index, exists := outputMap[contribution.OwnerID]
+		if !exists {
+			outputMap[contribution.OwnerID] = len(output)
+			contribution.CombatantID = contribution.OwnerID
+			contribution.CombatantName = contribution.OwnerName
+			contribution.OwnerID = 0
+			contribution.OwnerName = ""
+			output = append(output, contribution)
+			continue
+		}
+		output[index].Damage += contribution.Damage
+		output[index].DamageReceived += contribution.DamageReceived
+		output[index].DamageGiven += contribution.DamageGiven
+		output[index].Approximate = output[index].Approximate || contribution.Approximate
+		if contribution.Time.After(output[index].Time) {
+			output[index].Time = contribution.Time
+		}
+	}
+	return output
+}
+
+// SetContributions - set contributions, used when loading previous encounter
+func (c *ContributionManager) SetContributions(contributions []data.Contribution) {
+	c.Reset()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for index := range contributions {
+		c.encounterUID = contributions[index].EncounterUID
+		c.contributions = append(c.contributions, &contributions[index])
+	}
+}
@@ -25,9 +25,13 @@ import (
 	"../app"
 	"../data"
 	"github.com/jinzhu/gorm"
-	_ "github.com/jinzhu/gorm/dialects/sqlite" // import sqlite3 driver
+	_ "github.com/jinzhu/gorm/dialects/postgres" // import postgres driver
+	_ "github.com/jinzhu/gorm/dialects/sqlite"   // import sqlite3 driver
 )
 
+// databaseDeleteBatchSize - max number of encounters to delete in one query
+const databaseDeleteBatchSize = 500
+
 // DatabaseHandler - handles database access
 type DatabaseHandler struct {
 	conn *gorm.DB
@@ -35,29 +39,32 @@ type DatabaseHandler struct {
 	lock *sync.Mutex
 }
 
-// NewDatabaseHandler - create new database handler + open database connection
+// NewDatabaseHandler - create new database handler + open database connection, applies pending migrations
 func NewDatabaseHandler() (DatabaseHandler, error) {
-	// connect
-	db, err := gorm.Open("sqlite3", app.DatabasePath)
+	d, err := OpenDatabaseHandler()
 	if err != nil {
-		return DatabaseHandler{}, err
-	}
-	// init
-	res := db.AutoMigrate(&data.User{})
-	if res.Error != nil {
-		return DatabaseHandler{}, res.Error
+		return d, err
 	}
-	res = db.AutoMigrate(&data.Encounter{})
-	if res.Error != nil {
-		return DatabaseHandler{}, res.Error
+	return d, d.Migrate(LatestSchemaVersion())
+}
+
+// OpenDatabaseHandler - create new database handler + open database connection without migrating
+func OpenDatabaseHandler() (DatabaseHandler, error) {
+	return openDatabaseHandler(app.GetDatabaseDriver(), app.GetDatabaseDSN())
+}
+
+// openDatabaseHandler - create new database handler for given database
+func openDatabaseHandler(dialect string, dsn string) (DatabaseHandler, error) {
+	if dialect != "sqlite3" && dialect != "postgres" {
+		return DatabaseHandler{}, fmt.Errorf("database driver '%s' is not supported", dialect)
 	}
-	res = db.AutoMigrate(&data.Combatant{})
-	if res.Error != nil {
-		return DatabaseHandler{}, res.Error
+	if dsn == "" {
+		return DatabaseHandler{}, fmt.Errorf("database dsn must be set for driver '%s'", dialect)
 	}
-	res = db.AutoMigrate(&data.Player{})
-	if res.Error != nil {
-		return DatabaseHandler{}, res.Error
+	// connect
+	db, err := gorm.Open(dialect, dsn)
+	if err != nil {
+		return DatabaseHandler{}, err
 	}
 	// return
 	return DatabaseHandler{
@@ -67,11 +74,256 @@ func NewDatabaseHandler() (DatabaseHandler, error) {
 	}, nil
 }
 
+// Transaction - run function with a database handler whose queries all run in one
+// transaction, changes are committed if it returns nil and rolled back otherwise
+func (d *DatabaseHandler) Transaction(f func(tx *DatabaseHandler) error) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	conn := d.conn.Begin()
+	if conn.Error != nil {
+		return conn.Error
+	}
+	// this handler's lock is held for the whole transaction
+	tx := DatabaseHandler{
+		conn: conn,
+		log:  d.log,
+		lock: &sync.Mutex{},
+	}
+	err := f(&tx)
+	if err != nil {
+		conn.Rollback()
+		return err
+	}
+	return conn.Commit().Error
+}
+
+// lockUser - lock user row until the transaction ends, other servers sharing a
+// postgres database wait for it while sqlite only ever has one writer
+func (d *DatabaseHandler) lockUser(userID int64) error {
+	if d.conn.Dialect().GetName() != "postgres" {
+		return nil
+	}
+	return d.conn.Exec("SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Error
+}
+
+// Close - close database connection
+func (d *DatabaseHandler) Close() error {
+	return d.conn.Close()
+}
+
+// FetchAppliedMigrations - fetch migrations that have been applied to database
+func (d *DatabaseHandler) FetchAppliedMigrations() ([]data.SchemaMigration, error) {
+	output := make([]data.SchemaMigration, 0)
+	if !d.conn.HasTable(&data.SchemaMigration{}) {
+		return output, nil
+	}
+	res := d.conn.Order("version").Find(&output)
+	return output, res.Error
+}
+
+// SchemaVersion - get version of last applied migration, zero if none have been applied
+func (d *DatabaseHandler) SchemaVersion() (int, error) {
+	applied, err := d.FetchAppliedMigrations()
+	if err != nil || len(applied) == 0 {
+		return 0, err
+	}
+	return applied[len(applied)-1].Version, nil
+}
+
+// Migrate - apply or revert migrations until database schema is at given version
+func (d *DatabaseHandler) Migrate(version int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if version < 0 || version > LatestSchemaVersion() {
+		return fmt.Errorf("schema version %d does not exist", version)
+	}
+	err := execDialectSQL(d.conn, schemaMigrationsSQL)
+	if err != nil {
+		return err
+	}
+	current, err := d.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	// apply
+	for _, migration := range Migrations {
+		if migration.Version <= current || migration.Version > version {
+			continue
+		}
+		d.log.Log(fmt.Sprintf("Apply migration %d '%s.'", migration.Version, migration.Name))
+		err = d.runMigration(migration.Up, func(tx *gorm.DB) *gorm.DB {
+			return tx.Create(&data.SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now(),
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d '%s' failed, %s", migration.Version, migration.Name, err)
+		}
+	}
+	// revert
+	for index := len(Migrations) - 1; index >= 0; index-- {
+		migration := Migrations[index]
+		if migration.Version > current || migration.Version <= version {
+			continue
+		}
+		d.log.Log(fmt.Sprintf("Revert migration %d '%s.'", migration.Version, migration.Name))
+		err = d.runMigration(migration.Down, func(tx *gorm.DB) *gorm.DB {
+			return tx.Where("version = ?", migration.Version).Delete(&data.SchemaMigration{})
+		})
+		if err != nil {
+			return fmt.Errorf("revert of migration %d '%s' failed, %s", migration.Version, migration.Name, err)
+		}
+	}
+	return nil
+}
+
+// runMigration - run migration step and record it in a single transaction
+func (d *DatabaseHandler) runMigration(step func(tx *gorm.DB) error, record func(tx *gorm.DB) *gorm.DB) error {
+	tx := d.conn.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	err := step(tx)
+	if err == nil {
+		err = record(tx).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
 // StoreUser - store user to database
 func (d *DatabaseHandler) StoreUser(user *data.User) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
-	res := d.conn.Save(user)
+	// retention and quotas are only set by admins
+	res := d.conn.Omit("retention_days", "retention_group", "quota_encounters", "quota_bytes").Save(user)
+	return res.Error
+}
+
+// SetUserRetentionDays - set number of days to keep user's encounters for
+func (d *DatabaseHandler) SetUserRetentionDays(userID int64, days int) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	res := d.conn.Model(&data.User{}).Where("id = ?", userID).Update("retention_days", days)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return res.Error
+}
+
+// SetUserRetentionGroup - set retention group of user
+func (d *DatabaseHandler) SetUserRetentionGroup(userID int64, group string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	res := d.conn.Model(&data.User{}).Where("id = ?", userID).Update("retention_group", group)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return res.Error
+}
+
+// SetUserQuotaEncounters - set max number of encounters user can store
+func (d *DatabaseHandler) SetUserQuotaEncounters(userID int64, encounters int64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	res := d.conn.Model(&data.User{}).Where("id = ?", userID).Update("quota_encounters", encounters)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return res.Error
+}
+
+// SetUserQuotaBytes - set max file bytes user can store
+func (d *DatabaseHandler) SetUserQuotaBytes(userID int64, bytes int64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	res := d.conn.Model(&data.User{}).Where("id = ?", userID).Update("quota_bytes", bytes)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return res.Error
+}
+
+// FetchUserStorageUsage - fetch storage used by user's encounters along with user's quota
+func (d *DatabaseHandler) FetchUserStorageUsage(userID int64) (data.StorageUsage, error) {
+	usage := data.StorageUsage{}
+	u := data.User{}
+	res := d.conn.Where("id = ?", userID).First(&u)
+	if res.Error != nil {
+		return usage, res.Error
+	}
+	usage.QuotaEncounters = u.GetQuotaEncounters(app.GetQuotaEncounters())
+	usage.QuotaBytes = u.GetQuotaBytes(app.GetQuotaBytes())
+	err := d.conn.Model(&data.Encounter{}).Select(
+		"COUNT(*), COALESCE(SUM(storage_rows), 0), COALESCE(SUM(storage_bytes), 0)",
+	).Where("user_id = ?", userID).Row().Scan(&usage.Encounters, &usage.Rows, &usage.Bytes)
+	return usage, err
+}
+
+// UpdateEncounterStorage - count database rows and file bytes stored for encounter
+func (d *DatabaseHandler) UpdateEncounterStorage(storage BlobStorage, encounterUID string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.updateEncounterStorage(storage, encounterUID)
+}
+
+// updateEncounterStorage - count database rows and file bytes stored for encounter, expects lock to be held
+func (d *DatabaseHandler) updateEncounterStorage(storage BlobStorage, encounterUID string) error {
+	// rows, includes encounter itself
+	rows := int64(1)
+	queries := []*gorm.DB{
+		d.conn.Model(&data.Combatant{}).Where("encounter_uid = ?", encounterUID),
+		d.conn.Model(&data.Cast{}).Where("encounter_uid = ?", encounterUID),
+		d.conn.Model(&data.DeathRecap{}).Where("encounter_uid = ?", encounterUID),
+		d.conn.Model(&data.DeathRecapEvent{}).Where(
+			"death_recap_id IN (?)",
+			d.conn.Table("death_recaps").Select("id").Where("encounter_uid = ?", encounterUID).QueryExpr(),
+		),
+		d.conn.Model(&data.AbilityStat{}).Where("encounter_uid = ?", encounterUID),
+		d.conn.Model(&data.TimeSeries{}).Where("encounter_uid = ?", encounterUID),
+		d.conn.Model(&data.Contribution{}).Where("encounter_uid = ?", encounterUID),
+		d.conn.Model(&data.Enemy{}).Where("encounter_uid = ?", encounterUID),
+		d.conn.Model(&data.EnemyDamageSource{}).Where(
+			"enemy_id IN (?)",
+			d.conn.Table("enemies").Select("id").Where("encounter_uid = ?", encounterUID).QueryExpr(),
+		),
+	}
+	for _, query := range queries {
+		count := int64(0)
+		res := query.Count(&count)
+		if res.Error != nil {
+			return res.Error
+		}
+		rows += count
+	}
+	// files
+	bytes := int64(0)
+	for _, key := range []string{GetLogFileKey(encounterUID), GetLogIndexFileKey(encounterUID), GetPositionFileKey(encounterUID)} {
+		info, err := storage.Stat(key)
+		if err == ErrBlobNotExist {
+			continue
+		} else if err != nil {
+			return err
+		}
+		bytes += info.Size
+	}
+	res := d.conn.Model(&data.Encounter{}).Where("uid = ?", encounterUID).Updates(map[string]interface{}{
+		"storage_rows":  rows,
+		"storage_bytes": bytes,
+	})
+	return res.Error
+}
+
+// StoreRetentionGroup - store retention group to database
+func (d *DatabaseHandler) StoreRetentionGroup(group *data.RetentionGroup) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	res := d.conn.Save(group)
 	return res.Error
 }
 
@@ -107,7 +359,19 @@ func (d *DatabaseHandler) FetchUserFromFFToolsUID(uid string) (data.User, error)
 func (d *DatabaseHandler) StoreEncounter(encounter *data.Encounter) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
-	res := d.conn.Save(encounter)
+	// pinned is only set by the user
+	res := d.conn.Omit("pinned").Save(encounter)
+	return res.Error
+}
+
+// SetEncounterPinned - pin or unpin encounter, pinned encounters are never cleaned up
+func (d *DatabaseHandler) SetEncounterPinned(encounterUID string, pinned bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	res := d.conn.Model(&data.Encounter{}).Where("uid = ?", encounterUID).Update("pinned", pinned)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
 	return res.Error
 }
 
@@ -148,6 +412,14 @@ func (d *DatabaseHandler) CountUserEncounters(userID int64, start *time.Time, en
 	return count, res.Error
 }
 
+// FetchUserEncounterUIDs - fetch uids of all user encounters, newest first
+func (d *DatabaseHandler) FetchUserEncounterUIDs(userID int64, start *time.Time, end *time.Time) ([]string, error) {
+	uids := make([]string, 0)
+	res := d.buildUserEncountersQuery(userID, start, end).Limit(-1)
+	res = res.Pluck("uid", &uids)
+	return uids, res.Error
+}
+
 // StoreCombatants - store combatants to database
 func (d *DatabaseHandler) StoreCombatants(combatants []*data.Combatant) error {
 	d.lock.Lock()
@@ -214,35 +486,139 @@ func (d *DatabaseHandler) FetchCombatantsForEncounter(encounterUID string) ([]da
 	return c, nil
 }
 
-// CleanUpRoutine - perform clean up operations at regular interval
-func (d *DatabaseHandler) CleanUpRoutine() {
-	cleanUp := func() {
-		d.lock.Lock()
-		defer d.lock.Unlock()
-		count := int64(0)
-		// delete encounters older than EncounterDeleteDays days
-		cleanUpDate := time.Now().Add((-app.EncounterDeleteDays * 24) * time.Hour)
-		d.log.Start(fmt.Sprintf("Begin clean up. (Clean up encounters older than %s.)", cleanUpDate))
-		res := d.conn.Where(
-			"start_time < ? or zone = ''",
-			cleanUpDate,
-		).Delete(&data.Encounter{})
+// StoreCasts - store casts to database
+func (d *DatabaseHandler) StoreCasts(casts []*data.Cast) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for index := range casts {
+		res := d.conn.Save(casts[index])
 		if res.Error != nil {
-			d.log.Error(res.Error)
-			return
+			return res.Error
 		}
-		count += res.RowsAffected
-		// delete all combatants older than EncounterDeleteDays days
-		res = d.conn.Where(
-			"time < ?",
-			cleanUpDate,
-		).Delete(&data.Combatant{})
+	}
+	return nil
+}
+
+// FetchCastsForEncounter - fetch all casts for an encounter
+func (d *DatabaseHandler) FetchCastsForEncounter(encounterUID string) ([]data.Cast, error) {
+	c := make([]data.Cast, 0)
+	res := d.conn.Where("encounter_uid = ?", encounterUID).Order("start_time ASC").Find(&c)
+	return c, res.Error
+}
+
+// StoreDeathRecaps - store death recaps, and their events, to database
+func (d *DatabaseHandler) StoreDeathRecaps(deathRecaps []*data.DeathRecap) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for index := range deathRecaps {
+		res := d.conn.Save(deathRecaps[index])
 		if res.Error != nil {
-			d.log.Error(res.Error)
+			return res.Error
+		}
+	}
+	return nil
+}
+
+// FetchDeathRecapsForEncounter - fetch all death recaps for an encounter
+func (d *DatabaseHandler) FetchDeathRecapsForEncounter(encounterUID string) ([]data.DeathRecap, error) {
+	r := make([]data.DeathRecap, 0)
+	res := d.conn.Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("time ASC")
+	}).Where("encounter_uid = ?", encounterUID).Order("time ASC").Find(&r)
+	return r, res.Error
+}
+
+// StoreAbilityStats - store ability stats to database
+func (d *DatabaseHandler) StoreAbilityStats(abilityStats []*data.AbilityStat) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for index := range abilityStats {
+		res := d.conn.Save(abilityStats[index])
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
+}
+
+// FetchAbilityStatsForEncounter - fetch all ability stats for an encounter
+func (d *DatabaseHandler) FetchAbilityStatsForEncounter(encounterUID string) ([]data.AbilityStat, error) {
+	r := make([]data.AbilityStat, 0)
+	res := d.conn.Where("encounter_uid = ?", encounterUID).Order("total DESC").Find(&r)
+	return r, res.Error
+}
+
+// StoreTimeSeries - store time series to database
+func (d *DatabaseHandler) StoreTimeSeries(timeSeries []*data.TimeSeries) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for index := range timeSeries {
+		res := d.conn.Save(timeSeries[index])
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
+}
+
+// FetchTimeSeriesForEncounter - fetch all time series for an encounter
+func (d *DatabaseHandler) FetchTimeSeriesForEncounter(encounterUID string) ([]data.TimeSeries, error) {
+	r := make([]data.TimeSeries, 0)
+	res := d.conn.Where("encounter_uid = ?", encounterUID).Find(&r)
+	return r, res.Error
+}
+
+// StoreContributions - store raid buff contributions to database
+func (d *DatabaseHandler) StoreContributions(contributions []*data.Contribution) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for index := range contributions {
+		res := d.conn.Save(contributions[index])
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
+}
+
+// FetchContributionsForEncounter - fetch all raid buff contributions for an encounter
+func (d *DatabaseHandler) FetchContributionsForEncounter(encounterUID string) ([]data.Contribution, error) {
+	r := make([]data.Contribution, 0)
+	res := d.conn.Where("encounter_uid = ?", encounterUID).Find(&r)
+	return r, res.Error
+}
+
+// StoreEnemies - store enemies, and their damage sources, to database
+func (d *DatabaseHandler) StoreEnemies(enemies []*data.Enemy) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for index := range enemies {
+		res := d.conn.Save(enemies[index])
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
+}
+
+// FetchEnemiesForEncounter - fetch all enemies for an encounter
+func (d *DatabaseHandler) FetchEnemiesForEncounter(encounterUID string) ([]data.Enemy, error) {
+	r := make([]data.Enemy, 0)
+	res := d.conn.Preload("DamageSources", func(db *gorm.DB) *gorm.DB {
+		return db.Order("damage DESC")
+	}).Where("encounter_uid = ?", encounterUID).Order("first_seen ASC").Find(&r)
+	return r, res.Error
+}
+
+// CleanUpRoutine - perform database and encounter file clean up at regular interval
+func (d *DatabaseHandler) CleanUpRoutine() {
+	cleanUp := func() {
+		d.log.Start("Begin clean up.")
+		count, err := d.CleanUp(time.Now())
+		if err != nil {
+			d.log.Error(err)
 			return
 		}
-		count += res.RowsAffected
-		// TODO clean up users that have never uploaded
 		d.log.Finish(fmt.Sprintf("Finish clean up. (%d records removed.)", count))
 	}
 	cleanUp()
@@ -250,3 +626,194 @@ func (d *DatabaseHandler) CleanUpRoutine() {
 		cleanUp()
 	}
 }
+
+// CleanUp - delete encounters past their user's retention along with their
+// data and stored files, pinned encounters are kept, returns number of records removed
+func (d *DatabaseHandler) CleanUp(now time.Time) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	// wait for log files being written
+	globalLogLock.Lock()
+	defer globalLogLock.Unlock()
+	encounterUIDs, err := d.fetchExpiredEncounterUIDs(now)
+	if err != nil {
+		return 0, err
+	}
+	count, err := d.deleteEncounters(encounterUIDs)
+	if err != nil {
+		return count, err
+	}
+	// data and files not belonging to any encounter use the default retention
+	cleanUpDate := now.Add((-app.EncounterDeleteDays * 24) * time.Hour)
+	orphanCount, err := d.deleteOrphans(cleanUpDate)
+	return count + orphanCount, err
+}
+
+// fetchExpiredEncounterUIDs - get uids of unpinned encounters older than their user's retention
+func (d *DatabaseHandler) fetchExpiredEncounterUIDs(now time.Time) ([]string, error) {
+	groups := make([]data.RetentionGroup, 0)
+	res := d.conn.Find(&groups)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	groupDays := make(map[string]int)
+	for _, group := range groups {
+		groupDays[group.Name] = group.Days
+	}
+	// users with their own retention
+	users := make([]data.User, 0)
+	res = d.conn.Where("retention_days <> 0 OR retention_group <> ''").Find(&users)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	output := make([]string, 0)
+	userIDs := make([]int64, 0)
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+		uids := make([]string, 0)
+		res = d.conn.Model(&data.Encounter{}).Where("user_id = ? AND pinned = ?", user.ID, false)
+		days := user.GetRetentionDays(groupDays, app.EncounterDeleteDays)
+		if days < 0 {
+			res = res.Where("zone = ''")
+		} else {
+			res = res.Where("start_time < ? OR zone = ''", now.Add(time.Duration(-days*24)*time.Hour))
+		}
+		res = res.Pluck("uid", &uids)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		output = append(output, uids...)
+	}
+	// everyone else
+	uids := make([]string, 0)
+	res = d.conn.Model(&data.Encounter{}).Where(
+		"pinned = ? AND (start_time < ? OR zone = '')",
+		false,
+		now.Add((-app.EncounterDeleteDays*24)*time.Hour),
+	)
+	if len(userIDs) > 0 {
+		res = res.Where("user_id NOT IN (?)", userIDs)
+	}
+	res = res.Pluck("uid", &uids)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return append(output, uids...), nil
+}
+
+// deleteEncounters - delete encounters with all their data and stored files
+func (d *DatabaseHandler) deleteEncounters(encounterUIDs []string) (int64, error) {
+	count := int64(0)
+	// delete in batches to stay under the query variable limit
+	for len(encounterUIDs) > 0 {
+		batch := encounterUIDs
+		if len(batch) > databaseDeleteBatchSize {
+			batch = batch[:databaseDeleteBatchSize]
+		}
+		encounterUIDs = encounterUIDs[len(batch):]
+		deletes := []*gorm.DB{
+			d.conn.Where("uid IN (?)", batch).Delete(&data.Encounter{}),
+			d.conn.Where("encounter_uid IN (?)", batch).Delete(&data.Combatant{}),
+			d.conn.Where("encounter_uid IN (?)", batch).Delete(&data.Cast{}),
+			d.conn.Where(
+				"death_recap_id IN (?)",
+				d.conn.Table("death_recaps").Select("id").Where("encounter_uid IN (?)", batch).QueryExpr(),
+			).Delete(&data.DeathRecapEvent{}),
+			d.conn.Where("encounter_uid IN (?)", batch).Delete(&data.DeathRecap{}),
+			d.conn.Where("encounter_uid IN (?)", batch).Delete(&data.AbilityStat{}),
+			d.conn.Where("encounter_uid IN (?)", batch).Delete(&data.TimeSeries{}),
+			d.conn.Where("encounter_uid IN (?)", batch).Delete(&data.Contribution{}),
+			d.conn.Where(
+				"enemy_id IN (?)",
+				d.conn.Table("enemies").Select("id").Where("encounter_uid IN (?)", batch).QueryExpr(),
+			).Delete(&data.EnemyDamageSource{}),
+			d.conn.Where("encounter_uid IN (?)", batch).Delete(&data.Enemy{}),
+		}
+		for _, res := range deletes {
+			if res.Error != nil {
+				return count, res.Error
+			}
+			count += res.RowsAffected
+		}
+		// delete stored files
+		for _, encounterUID := range batch {
+			err := deleteEncounterFiles(encounterUID)
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, nil
+}
+
+// deleteEncounterFiles - delete all files stored for encounter
+func deleteEncounterFiles(encounterUID string) error {
+	storage := GetBlobStorage()
+	for _, key := range []string{GetLogFileKey(encounterUID), GetLogIndexFileKey(encounterUID), GetPositionFileKey(encounterUID)} {
+		err := storage.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteOrphans - delete data and files older than given date that don't belong to an encounter
+func (d *DatabaseHandler) deleteOrphans(cleanUpDate time.Time) (int64, error) {
+	count := int64(0)
+	encounterUIDs := d.conn.Table("encounters").Select("uid").QueryExpr()
+	deletes := []*gorm.DB{
+		d.conn.Where("time < ? AND encounter_uid NOT IN (?)", cleanUpDate, encounterUIDs).Delete(&data.Combatant{}),
+		d.conn.Where("start_time < ? AND encounter_uid NOT IN (?)", cleanUpDate, encounterUIDs).Delete(&data.Cast{}),
+		d.conn.Where(
+			"death_recap_id IN (?)",
+			d.conn.Table("death_recaps").Select("id").Where("time < ? AND encounter_uid NOT IN (?)", cleanUpDate, encounterUIDs).QueryExpr(),
+		).Delete(&data.DeathRecapEvent{}),
+		d.conn.Where("time < ? AND encounter_uid NOT IN (?)", cleanUpDate, encounterUIDs).Delete(&data.DeathRecap{}),
+		d.conn.Where("time < ? AND encounter_uid NOT IN (?)", cleanUpDate, encounterUIDs).Delete(&data.AbilityStat{}),
+		d.conn.Where("start_time < ? AND encounter_uid NOT IN (?)", cleanUpDate, encounterUIDs).Delete(&data.TimeSeries{}),
+		d.conn.Where("time < ? AND encounter_uid NOT IN (?)", cleanUpDate, encounterUIDs).Delete(&data.Contribution{}),
+		d.conn.Where(
+			"enemy_id IN (?)",
+			d.conn.Table("enemies").Select("id").Where("first_seen < ? AND encounter_uid NOT IN (?)", cleanUpDate, encounterUIDs).QueryExpr(),
+		).Delete(&data.EnemyDamageSource{}),
+		d.conn.Where("first_seen < ? AND encounter_uid NOT IN (?)", cleanUpDate, encounterUIDs).Delete(&data.Enemy{}),
+	}
+	for _, res := range deletes {
+		if res.Error != nil {
+			return count, res.Error
+		}
+		count += res.RowsAffected
+	}
+	// stored files
+	storage := GetBlobStorage()
+	blobs, err := storage.List("fflp_")
+	if err != nil {
+		return count, err
+	}
+	blobUIDs := make(map[string][]string)
+	for _, blob := range blobs {
+		encounterUID := GetBlobEncounterUID(blob.Key)
+		if encounterUID == "" || !blob.Time.Before(cleanUpDate) {
+			continue
+		}
+		blobUIDs[encounterUID] = append(blobUIDs[encounterUID], blob.Key)
+	}
+	for encounterUID, keys := range blobUIDs {
+		encounterCount := 0
+		res := d.conn.Model(&data.Encounter{}).Where("uid = ?", encounterUID).Count(&encounterCount)
+		if res.Error != nil {
+			return count, res.Error
+		}
+		if encounterCount > 0 {
+			continue
+		}
+		for _, key := range keys {
+			err = storage.Delete(key)
+			if err != nil {
+				return count, err
+			}
+		}
+	}
+	return count, nil
+}
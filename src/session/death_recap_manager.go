@@ -0,0 +1,197 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"../app"
+	"../data"
+)
+
+// deathRecapEventCount - max number of events kept per player for a death recap
+const deathRecapEventCount = 20
+
+// deathRecapWindow - max age of events included in a death recap
+const deathRecapWindow = 20000
+
+// DeathRecapManager - keeps rolling buffer of incoming damage/heals per player
+// and builds a death recap when they are defeated
+type DeathRecapManager struct {
+	events       map[string][]data.DeathRecapEvent
+	targetIDs    map[string]int32
+	recaps       []*data.DeathRecap
+	updated      []*data.DeathRecap
+	lock         *sync.Mutex
+	log          app.Logging
+	encounterUID string
+}
+
+// NewDeathRecapManager - create new death recap manager
+func NewDeathRecapManager() DeathRecapManager {
+	d := DeathRecapManager{
+		log:  app.Logging{ModuleName: "DEATHRECAP"},
+		lock: &sync.Mutex{},
+	}
+	d.Reset()
+	return d
+}
+
+// Reset - reset death recap manager
+func (d *DeathRecapManager) Reset() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.events = make(map[string][]data.DeathRecapEvent)
+	d.targetIDs = make(map[string]int32)
+	d.recaps = make([]*data.DeathRecap, 0)
+	d.updated = make([]*data.DeathRecap, 0)
+	d.encounterUID = ""
+}
+
+// ResetEncounter - reset with new encounter data
+func (d *DeathRecapManager) ResetEncounter(encounter data.Encounter) {
+	d.Reset()
+	d.encounterUID = encounter.UID
+}
+
+// deathRecapKey - get buffer key for given combatant name, defeat
+// messages don't always match the casing of other log lines
+func deathRecapKey(name string) string {
+	return strings.TrimSpace(strings.ToUpper(name))
+}
+
+// addEvent - add event to target's rolling buffer
+func (d *DeathRecapManager) addEvent(targetID int32, targetName string, event data.DeathRecapEvent) {
+	key := deathRecapKey(targetName)
+	events := append(d.events[key], event)
+	if len(events) > deathRecapEventCount {
+		events = events[len(events)-deathRecapEventCount:]
+	}
+	d.events[key] = events
+	d.targetIDs[key] = targetID
+}
+
+// ReadLogLine - parse log line and update rolling buffers
+func (d *DeathRecapManager) ReadLogLine(l *ParsedLogLine) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	switch l.Type {
+	case LogTypeSingleTarget, LogTypeAoe, LogTypeDot:
+		{
+			// only players get a death recap
+			if l.TargetID <= 0 || l.TargetID > combatantMaxPlayerID {
+				break
+			}
+			eventType := data.DeathRecapEventDamage
+			if l.HasFlag(LogFlagHeal) {
+				eventType = data.DeathRecapEventHeal
+			} else if !l.HasFlag(LogFlagDamage) && !l.HasFlag(LogFlagInstantDeath) {
+				break
+			}
+			abilityName := l.AbilityName
+			if l.Type == LogTypeDot && abilityName == "" {
+				abilityName = "DoT"
+				if eventType == data.DeathRecapEventHeal {
+					abilityName = "HoT"
+				}
+			}
+			d.addEvent(int32(l.TargetID), l.TargetName, data.DeathRecapEvent{
+				Time:        l.Time,
+				Type:        eventType,
+				SourceID:    int32(l.AttackerID),
+				SourceName:  l.AttackerName,
+				AbilityID:   int32(l.AbilityID),
+				AbilityName: abilityName,
+				Amount:      int32(l.Damage),
+				HPBefore:    int32(l.TargetCurrentHP),
+				MaxHP:       int32(l.TargetMaxHP),
+			})
+			break
+		}
+	case LogTypeDefeat:
+		{
+			key := deathRecapKey(l.TargetName)
+			events := d.events[key]
+			if len(events) == 0 {
+				break
+			}
+			// only include recent events
+			recapEvents := make([]data.DeathRecapEvent, 0)
+			for index := range events {
+				if events[index].Time.Add(time.Millisecond * deathRecapWindow).Before(l.Time) {
+					continue
+				}
+				recapEvents = append(recapEvents, events[index])
+			}
+			// last damage event is the killing blow
+			for index := len(recapEvents) - 1; index >= 0; index-- {
+				if recapEvents[index].Type == data.DeathRecapEventDamage {
+					recapEvents[index].KillingBlow = true
+					break
+				}
+			}
+			recap := &data.DeathRecap{
+				EncounterUID: d.encounterUID,
+				TargetID:     d.targetIDs[key],
+				TargetName:   l.TargetName,
+				Time:         l.Time,
+				Events:       recapEvents,
+			}
+			d.recaps = append(d.recaps, recap)
+			d.updated = append(d.updated, recap)
+			delete(d.events, key)
+			break
+		}
+	}
+}
+
+// GetDeathRecaps - get all death recaps in encounter
+func (d *DeathRecapManager) GetDeathRecaps() []data.DeathRecap {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	output := make([]data.DeathRecap, 0)
+	for index := range d.recaps {
+		output = append(output, *d.recaps[index])
+	}
+	return output
+}
+
+// SetDeathRecaps - set death recaps, used when loading previous encounter
+func (d *DeathRecapManager) SetDeathRecaps(recaps []data.DeathRecap) {
+	d.Reset()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for index := range recaps {
+		d.encounterUID = recaps[index].EncounterUID
+		d.recaps = append(d.recaps, &recaps[index])
+	}
+}
+
+// Dump - get death recaps created since last dump
+func (d *DeathRecapManager) Dump() []data.DeathRecap {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	output := make([]data.DeathRecap, 0)
+	for index := range d.updated {
+		output = append(output, *d.updated[index])
+	}
+	d.updated = make([]*data.DeathRecap, 0)
+	return output
+}
@@ -0,0 +1,212 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"time"
+
+	"../app"
+	"../data"
+)
+
+// EffectManager - tracks status effects (buffs/debuffs) over the course of an encounter
+type EffectManager struct {
+	effects      []*data.Effect
+	log          app.Logging
+	encounterUID string
+	lastTime     time.Time
+}
+
+// NewEffectManager - create new effect manager
+func NewEffectManager() EffectManager {
+	e := EffectManager{
+		log: app.Logging{ModuleName: "EFFECT"},
+	}
+	e.Reset()
+	return e
+}
+
+// Reset - reset effect manager
+func (e *EffectManager) Reset() {
+	e.effects = make([]*data.Effect, 0)
+	e.encounterUID = ""
+}
+
+// ResetEncounter - reset with new encounter data, effects that are
+// still active are carried over (pre-pull buffs, etc)
+func (e *EffectManager) ResetEncounter(encounter data.Encounter) {
+	oldEffects := e.effects
+	e.Reset()
+	e.encounterUID = encounter.UID
+	for index := range oldEffects {
+		if oldEffects[index].IsActive(e.lastTime) {
+			oldEffects[index].EncounterUID = encounter.UID
+			e.effects = append(e.effects, oldEffects[index])
+		}
+	}
+}
+
+// findActive - find effect currently active with given effect, source and target ids
+func (e *EffectManager) findActive(effectID int32, sourceID int32, targetID int32, t time.Time) *data.Effect {
+	for index := len(e.effects) - 1; index >= 0; index-- {
+		effect := e.effects[index]
+		if effect.EffectID != effectID || effect.TargetID != targetID {
+			continue
+		}
+		if sourceID != 0 && effect.SourceID != sourceID {
+			continue
+		}
+		if effect.IsActive(t) {
+			return effect
+		}
+	}
+	return nil
+}
+
+// findActiveByName - find active effect by names, used when log line has no ids
+func (e *EffectManager) findActiveByName(name string, sourceName string, targetName string, t time.Time) *data.Effect {
+	for index := len(e.effects) - 1; index >= 0; index-- {
+		effect := e.effects[index]
+		if effect.Name != name || effect.TargetName != targetName {
+			continue
+		}
+		if sourceName != "" && effect.SourceName != sourceName {
+			continue
+		}
+		if effect.IsActive(t) {
+			return effect
+		}
+	}
+	return nil
+}
+
+// ReadLogLine - parse log line and update effect timeline
+func (e *EffectManager) ReadLogLine(l *ParsedLogLine) {
+	if l.Time.After(e.lastTime) {
+		e.lastTime = l.Time
+	}
+	switch l.Type {
+	case LogTypeGainEffect:
+		{
+			var effect *data.Effect
+			if l.AbilityID != 0 {
+				effect = e.findActive(int32(l.AbilityID), int32(l.AttackerID), int32(l.TargetID), l.Time)
+			} else {
+				effect = e.findActiveByName(l.AbilityName, l.AttackerName, l.TargetName, l.Time)
+			}
+			// new effect
+			if effect == nil {
+				effect = &data.Effect{
+					EncounterUID: e.encounterUID,
+					EffectID:     int32(l.AbilityID),
+					Name:         l.AbilityName,
+					SourceID:     int32(l.AttackerID),
+					SourceName:   l.AttackerName,
+					TargetID:     int32(l.TargetID),
+					TargetName:   l.TargetName,
+					StartTime:    l.Time,
+				}
+				e.effects = append(e.effects, effect)
+			}
+			// refresh duration+stacks
+			effect.Stacks = int32(l.Stacks)
+			effect.EndTime = time.Time{}
+			if l.Duration > 0 {
+				effect.EndTime = l.Time.Add(l.Duration)
+			}
+			break
+		}
+	case LogTypeLoseEffect:
+		{
+			var effect *data.Effect
+			if l.AbilityID != 0 {
+				effect = e.findActive(int32(l.AbilityID), int32(l.AttackerID), int32(l.TargetID), l.Time)
+			} else {
+				effect = e.findActiveByName(l.AbilityName, l.AttackerName, l.TargetName, l.Time)
+			}
+			if effect == nil {
+				break
+			}
+			effect.EndTime = l.Time
+			break
+		}
+	case LogTypeDefeat, LogTypeRemoveCombatant:
+		{
+			// effects fall off when target is defeated
+			for index := range e.effects {
+				if e.effects[index].TargetName == l.TargetName && e.effects[index].IsActive(l.Time) {
+					e.effects[index].EndTime = l.Time
+				}
+			}
+			break
+		}
+	}
+}
+
+// GetTickSource - find the effect responsible for a dot/hot tick
+func (e *EffectManager) GetTickSource(l *ParsedLogLine) *data.Effect {
+	// ticks without an effect id can't be matched reliably
+	if l.AbilityID == 0 {
+		return nil
+	}
+	// the tick doesn't name its source, if the same effect is active
+	// on the target from more than one source leave it unattributed
+	var source *data.Effect
+	for index := range e.effects {
+		effect := e.effects[index]
+		if effect.EffectID != int32(l.AbilityID) || effect.TargetID != int32(l.TargetID) || !effect.IsActive(l.Time) {
+			continue
+		}
+		if source != nil && source.SourceID != effect.SourceID {
+			return nil
+		}
+		source = effect
+	}
+	return source
+}
+
+// GetEffects - get all effects in the encounter timeline
+func (e *EffectManager) GetEffects() []data.Effect {
+	output := make([]data.Effect, 0)
+	for index := range e.effects {
+		output = append(output, *e.effects[index])
+	}
+	return output
+}
+
+// GetActiveEffects - get all effects that were active at given time
+func (e *EffectManager) GetActiveEffects(t time.Time) []data.Effect {
+	output := make([]data.Effect, 0)
+	for index := range e.effects {
+		if e.effects[index].IsActive(t) {
+			output = append(output, *e.effects[index])
+		}
+	}
+	return output
+}
+
+// GetActiveEffectsOnTarget - get all effects that were active on given target at given time
+func (e *EffectManager) GetActiveEffectsOnTarget(targetID int32, t time.Time) []data.Effect {
+	output := make([]data.Effect, 0)
+	for index := range e.effects {
+		if e.effects[index].TargetID == targetID && e.effects[index].IsActive(t) {
+			output = append(output, *e.effects[index])
+		}
+	}
+	return output
+}
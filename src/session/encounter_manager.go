@@ -18,8 +18,8 @@ along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
 package session
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
@@ -50,30 +50,54 @@ type combatantTracker struct {
 	WasAttacked bool
 }
 
+// ErrQuotaExceeded - error returned when encounter isn't saved because user is over their storage quota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
 // EncounterManager - handles encounter and related objects
 type EncounterManager struct {
-	encounter        data.Encounter
-	combatantTracker []*combatantTracker
-	playerTeam       uint8
-	teamWipeTime     time.Time
-	lastActionTime   time.Time
-	log              app.Logging
-	database         *DatabaseHandler
-	User             data.User
-	CombatantManager CombatantManager
-	LogLineManager   LogLineManager
-	NoSave           bool
+	encounter           data.Encounter
+	combatantTracker    []*combatantTracker
+	playerTeam          uint8
+	teamWipeTime        time.Time
+	lastActionTime      time.Time
+	clock               func() time.Time
+	log                 app.Logging
+	database            *DatabaseHandler
+	User                data.User
+	CombatantManager    CombatantManager
+	LogLineManager      LogLineManager
+	EffectManager       EffectManager
+	CastManager         CastManager
+	DeathRecapManager   DeathRecapManager
+	AbilityStatManager  AbilityStatManager
+	TimeSeriesManager   TimeSeriesManager
+	ContributionManager ContributionManager
+	PositionManager     PositionManager
+	OwnerManager        OwnerManager
+	EnemyManager        EnemyManager
+	NoSave              bool
+	quotaExceeded       bool
 }
 
 // NewEncounterManager - create new encounter manager
 func NewEncounterManager(database *DatabaseHandler, user data.User) EncounterManager {
 	e := EncounterManager{
-		log:              app.Logging{ModuleName: "ENCOUNTER"},
-		CombatantManager: NewCombatantManager(),
-		LogLineManager:   NewLogLineManager(),
-		database:         database,
-		User:             user,
-		NoSave:           false,
+		log:                 app.Logging{ModuleName: "ENCOUNTER"},
+		clock:               time.Now,
+		CombatantManager:    NewCombatantManager(),
+		LogLineManager:      NewLogLineManager(),
+		EffectManager:       NewEffectManager(),
+		CastManager:         NewCastManager(),
+		DeathRecapManager:   NewDeathRecapManager(),
+		AbilityStatManager:  NewAbilityStatManager(),
+		TimeSeriesManager:   NewTimeSeriesManager(),
+		ContributionManager: NewContributionManager(),
+		PositionManager:     NewPositionManager(),
+		OwnerManager:        NewOwnerManager(),
+		EnemyManager:        NewEnemyManager(),
+		database:            database,
+		User:                user,
+		NoSave:              false,
 	}
 	e.Reset()
 	return e
@@ -84,8 +108,8 @@ func (e *EncounterManager) Reset() {
 	encounterUIDGenerator := xid.New()
 	e.encounter = data.Encounter{
 		Active:       false,
-		StartTime:    time.Now(),
-		EndTime:      time.Now(),
+		StartTime:    e.clock(),
+		EndTime:      e.clock(),
 		UID:          encounterUIDGenerator.String(),
 		Zone:         "",
 		SuccessLevel: 2,
@@ -93,10 +117,19 @@ func (e *EncounterManager) Reset() {
 		UserID:       e.User.ID,
 	}
 	e.playerTeam = 0
-	e.lastActionTime = time.Now()
+	e.lastActionTime = e.clock()
 	e.teamWipeTime = time.Time{}
+	e.quotaExceeded = false
 	e.combatantTracker = make([]*combatantTracker, 0)
 	e.CombatantManager.ResetEncounter(e.encounter)
+	e.EffectManager.ResetEncounter(e.encounter)
+	e.CastManager.ResetEncounter(e.encounter)
+	e.DeathRecapManager.ResetEncounter(e.encounter)
+	e.AbilityStatManager.ResetEncounter(e.encounter)
+	e.TimeSeriesManager.ResetEncounter(e.encounter)
+	e.ContributionManager.ResetEncounter(e.encounter)
+	e.PositionManager.ResetEncounter(e.encounter)
+	e.EnemyManager.ResetEncounter(e.encounter)
 	e.LogLineManager.Reset()
 	e.log.ModuleName = fmt.Sprintf("ENCOUNTER/%s", e.encounter.UID)
 	e.LogLineManager.SetEncounterUID(e.encounter.UID)
@@ -132,7 +165,10 @@ func (e *EncounterManager) End(successLevel uint8) {
 	e.encounter.SuccessLevel = successLevel
 	// save
 	err := e.Save()
-	if err != nil {
+	if err == ErrQuotaExceeded {
+		// session sends this to the user's web clients
+		e.quotaExceeded = true
+	} else if err != nil {
 		e.log.Error(err)
 	}
 	// log status
@@ -208,11 +244,11 @@ func (e *EncounterManager) checkTeamStatus() {
 	}
 	// set 'time wipe time'
 	if e.teamWipeTime.Before(e.encounter.StartTime) {
-		e.teamWipeTime = time.Now().Add(time.Millisecond * teamDeadTimeout)
+		e.teamWipeTime = e.clock().Add(time.Millisecond * teamDeadTimeout)
 		e.log.Log(fmt.Sprintf("Team %d has no remaining combatants.", deadTeam))
 	}
 	// 'team wipe time' has passed
-	if time.Now().After(e.teamWipeTime) {
+	if e.clock().After(e.teamWipeTime) {
 		for team := range ctMap {
 			if ctMap[team] == 0 {
 				if e.playerTeam == 0 {
@@ -298,9 +334,9 @@ func (e *EncounterManager) ReadLogLine(l *ParsedLogLine) {
 			}
 			// update team wipe time if action was just performed
 			if e.teamWipeTime.After(e.encounter.StartTime) {
-				e.teamWipeTime = time.Now().Add(time.Millisecond * teamDeadTimeout)
+				e.teamWipeTime = e.clock().Add(time.Millisecond * teamDeadTimeout)
 			}
-			e.lastActionTime = time.Now()
+			e.lastActionTime = e.clock()
 			break
 		}
 	case LogTypeRemoveCombatant, LogTypeDefeat:
@@ -327,7 +363,7 @@ func (e *EncounterManager) ReadLogLine(l *ParsedLogLine) {
 				// if zone change while waiting for team wipe to be determined
 				// then force team wipe check now
 				if e.IsWaitForTeamWipe() {
-					e.teamWipeTime = time.Now().Add(-time.Second)
+					e.teamWipeTime = e.clock().Add(-time.Second)
 					e.checkTeamStatus()
 				}
 				// otherwise flag unknown end
@@ -356,12 +392,8 @@ func (e *EncounterManager) ReadLogLine(l *ParsedLogLine) {
 				}
 			case LogMsgIDEcho:
 				{
-					re, err := regexp.Compile("00:0038:end")
-					if err != nil {
-						break
-					}
 					// end encounter if match
-					if re.MatchString(l.Raw) && e.encounter.Active {
+					if strings.HasPrefix(l.Message, "end") && e.encounter.Active {
 						e.End(EncounterSuccessEnd)
 						e.log.Log("Clear flag (end echo) detected.")
 					}
@@ -376,7 +408,7 @@ func (e *EncounterManager) ReadLogLine(l *ParsedLogLine) {
 					// if countdown while waiting for team wipe to be determined
 					// then force team wipe check now
 					if e.IsWaitForTeamWipe() {
-						e.teamWipeTime = time.Now().Add(-time.Second)
+						e.teamWipeTime = e.clock().Add(-time.Second)
 						e.checkTeamStatus()
 					}
 					e.End(EncounterSuccessEnd)
@@ -388,22 +420,40 @@ func (e *EncounterManager) ReadLogLine(l *ParsedLogLine) {
 					// if 'boss' is talking then extend team wipe timeout
 					if e.IsWaitForTeamWipe() {
 						e.log.Log("Extend team wipe timeout.")
-						e.teamWipeTime = time.Now().Add(time.Millisecond * teamDeadTimeout)
+						e.teamWipeTime = e.clock().Add(time.Millisecond * teamDeadTimeout)
 					}
 					break
 				}
 			}
 		}
 	}
-	// send log line to combatant manager
+	// attribute dot/hot ticks to the combatant that applied the effect
+	if l.Type == LogTypeDot && l.AttackerID == 0 {
+		effect := e.EffectManager.GetTickSource(l)
+		if effect != nil {
+			l.AttackerID = int(effect.SourceID)
+			l.AttackerName = effect.SourceName
+		}
+	}
+	// send log line to the other encounter managers
 	// log line manager will recieve log line from session manager
+	e.OwnerManager.ReadLogLine(l)
+	e.OwnerManager.Attribute(l)
+	e.EffectManager.ReadLogLine(l)
+	e.CastManager.ReadLogLine(l)
+	e.DeathRecapManager.ReadLogLine(l)
+	e.AbilityStatManager.ReadLogLine(l)
+	e.TimeSeriesManager.ReadLogLine(l)
+	e.ContributionManager.ReadLogLine(l, &e.EffectManager)
+	e.PositionManager.ReadLogLine(l)
+	e.EnemyManager.ReadLogLine(l, &e.OwnerManager)
 	e.CombatantManager.ReadLogLine(l)
 }
 
 // Tick - perform status checks
 func (e *EncounterManager) Tick() {
 	e.checkTeamStatus()
-	if e.encounter.Active && e.lastActionTime.Add(time.Millisecond*noActionTimeout).Before(time.Now()) {
+	if e.encounter.Active && e.lastActionTime.Add(time.Millisecond*noActionTimeout).Before(e.clock()) {
 		e.End(EncounterSuccessEnd)
 	}
 }
@@ -414,9 +464,14 @@ func (e *EncounterManager) GetEncounter() data.Encounter {
 	return e.encounter
 }
 
+// IsQuotaExceeded - true if the last encounter wasn't saved because the user is over their storage quota
+func (e *EncounterManager) IsQuotaExceeded() bool {
+	return e.quotaExceeded
+}
+
 // IsWaitForTeamWipe - determine if waiting for team wipe time out to end encounter
 func (e *EncounterManager) IsWaitForTeamWipe() bool {
-	return e.teamWipeTime.After(e.encounter.StartTime) && e.teamWipeTime.After(time.Now())
+	return e.teamWipeTime.After(e.encounter.StartTime) && e.teamWipeTime.After(e.clock())
 }
 
 // Save - save encounter
@@ -434,8 +489,44 @@ func (e *EncounterManager) Save() error {
 	if duration < app.MinEncounterSaveLength*time.Millisecond || duration > app.MaxEncounterSaveLength*time.Millisecond {
 		return nil
 	}
+	// rows are stored in one transaction so a failed or concurrent save is never seen half done
+	err := e.database.Transaction(e.store)
+	if err != nil {
+		return err
+	}
+	// store movement tracks
+	err = e.PositionManager.Save()
+	if err != nil {
+		return err
+	}
+	// store log lines
+	err = e.LogLineManager.Save()
+	if err != nil {
+		return err
+	}
+	// account storage used by encounter
+	return e.database.UpdateEncounterStorage(e.LogLineManager.storage, e.encounter.UID)
+}
+
+// store - store encounter and its data to database if user has storage left
+func (e *EncounterManager) store(tx *DatabaseHandler) error {
+	// ensure user has storage left for this encounter, user is locked until the
+	// transaction ends so concurrent saves can't both pass
+	err := tx.lockUser(e.User.ID)
+	if err != nil {
+		return err
+	}
+	usage, err := tx.FetchUserStorageUsage(e.User.ID)
+	if err != nil {
+		return err
+	}
+	pending := e.pendingStorageUsage()
+	if usage.ExceedsQuota(pending) {
+		e.log.Log(fmt.Sprintf("Encounter not saved, storage quota exceeded. (%d rows, %s of log lines)", pending.Rows, pending.BytesString()))
+		return ErrQuotaExceeded
+	}
 	// store encounter to database
-	err := e.database.StoreEncounter(&e.encounter)
+	err = tx.StoreEncounter(&e.encounter)
 	if err != nil {
 		return err
 	}
@@ -446,12 +537,99 @@ func (e *EncounterManager) Save() error {
 		combatants[index].UserID = e.User.ID
 		storeCombatants = append(storeCombatants, &combatants[index])
 	}
-	err = e.database.StoreCombatants(storeCombatants)
+	err = tx.StoreCombatants(storeCombatants)
 	if err != nil {
 		return err
 	}
-	// store log lines
-	return e.LogLineManager.Save()
+	// store casts
+	casts := e.CastManager.GetCasts()
+	storeCasts := make([]*data.Cast, 0)
+	for index := range casts {
+		casts[index].UserID = e.User.ID
+		casts[index].EncounterUID = e.encounter.UID
+		storeCasts = append(storeCasts, &casts[index])
+	}
+	err = tx.StoreCasts(storeCasts)
+	if err != nil {
+		return err
+	}
+	// store death recaps
+	deathRecaps := e.DeathRecapManager.GetDeathRecaps()
+	storeDeathRecaps := make([]*data.DeathRecap, 0)
+	for index := range deathRecaps {
+		deathRecaps[index].UserID = e.User.ID
+		deathRecaps[index].EncounterUID = e.encounter.UID
+		storeDeathRecaps = append(storeDeathRecaps, &deathRecaps[index])
+	}
+	err = tx.StoreDeathRecaps(storeDeathRecaps)
+	if err != nil {
+		return err
+	}
+	// store ability stats
+	abilityStats := e.AbilityStatManager.GetAbilityStats()
+	storeAbilityStats := make([]*data.AbilityStat, 0)
+	for index := range abilityStats {
+		abilityStats[index].UserID = e.User.ID
+		abilityStats[index].EncounterUID = e.encounter.UID
+		storeAbilityStats = append(storeAbilityStats, &abilityStats[index])
+	}
+	err = tx.StoreAbilityStats(storeAbilityStats)
+	if err != nil {
+		return err
+	}
+	// store time series
+	timeSeries := e.TimeSeriesManager.GetTimeSeries()
+	storeTimeSeries := make([]*data.TimeSeries, 0)
+	for index := range timeSeries {
+		timeSeries[index].UserID = e.User.ID
+		timeSeries[index].EncounterUID = e.encounter.UID
+		storeTimeSeries = append(storeTimeSeries, &timeSeries[index])
+	}
+	err = tx.StoreTimeSeries(storeTimeSeries)
+	if err != nil {
+		return err
+	}
+	// store raid buff contributions
+	contributions := e.ContributionManager.GetContributions()
+	storeContributions := make([]*data.Contribution, 0)
+	for index := range contributions {
+		contributions[index].UserID = e.User.ID
+		contributions[index].EncounterUID = e.encounter.UID
+		storeContributions = append(storeContributions, &contributions[index])
+	}
+	err = tx.StoreContributions(storeContributions)
+	if err != nil {
+		return err
+	}
+	// store enemies
+	enemies := e.EnemyManager.GetEnemies()
+	storeEnemies := make([]*data.Enemy, 0)
+	for index := range enemies {
+		enemies[index].UserID = e.User.ID
+		enemies[index].EncounterUID = e.encounter.UID
+		storeEnemies = append(storeEnemies, &enemies[index])
+	}
+	return tx.StoreEnemies(storeEnemies)
+}
+
+// pendingStorageUsage - estimate storage the encounter will use once saved,
+// bytes are only known after files are saved so the log dump size is used
+func (e *EncounterManager) pendingStorageUsage() data.StorageUsage {
+	// rows, includes encounter itself
+	rows := 1 + len(e.CombatantManager.GetCombatants()) + len(e.CastManager.GetCasts()) +
+		len(e.AbilityStatManager.GetAbilityStats()) + len(e.TimeSeriesManager.GetTimeSeries()) +
+		len(e.ContributionManager.GetContributions())
+	for _, deathRecap := range e.DeathRecapManager.GetDeathRecaps() {
+		rows += 1 + len(deathRecap.Events)
+	}
+	for _, enemy := range e.EnemyManager.GetEnemies() {
+		rows += 1 + len(enemy.DamageSources)
+	}
+	return data.StorageUsage{
+		Encounters: 1,
+		Rows:       int64(rows),
+		Bytes:      e.LogLineManager.GetDumpSize(),
+	}
 }
 
 // Load - load previous encounter
@@ -473,5 +651,46 @@ func (e *EncounterManager) Load(encounterUID string) error {
 		return err
 	}
 	e.CombatantManager.SetCombatants(combatants)
+	// fetch casts
+	casts, err := e.database.FetchCastsForEncounter(encounterUID)
+	if err != nil {
+		return err
+	}
+	e.CastManager.SetCasts(casts)
+	// fetch death recaps
+	deathRecaps, err := e.database.FetchDeathRecapsForEncounter(encounterUID)
+	if err != nil {
+		return err
+	}
+	e.DeathRecapManager.SetDeathRecaps(deathRecaps)
+	// fetch ability stats
+	abilityStats, err := e.database.FetchAbilityStatsForEncounter(encounterUID)
+	if err != nil {
+		return err
+	}
+	e.AbilityStatManager.SetAbilityStats(abilityStats)
+	// fetch time series
+	timeSeries, err := e.database.FetchTimeSeriesForEncounter(encounterUID)
+	if err != nil {
+		return err
+	}
+	e.TimeSeriesManager.SetTimeSeries(timeSeries)
+	// fetch raid buff contributions
+	contributions, err := e.database.FetchContributionsForEncounter(encounterUID)
+	if err != nil {
+		return err
+	}
+	e.ContributionManager.SetContributions(contributions)
+	// fetch enemies
+	enemies, err := e.database.FetchEnemiesForEncounter(encounterUID)
+	if err != nil {
+		return err
+	}
+	e.EnemyManager.SetEnemies(enemies)
+	// load movement tracks
+	err = e.PositionManager.Load(encounterUID)
+	if err != nil {
+		return err
+	}
 	return nil
 }
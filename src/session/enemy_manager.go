@@ -0,0 +1,189 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"strings"
+	"sync"
+
+	"../app"
+	"../data"
+)
+
+// EnemyManager - tracks the enemy combatants in an encounter
+type EnemyManager struct {
+	enemies      []*data.Enemy
+	updated      []*data.Enemy
+	lock         *sync.Mutex
+	log          app.Logging
+	encounterUID string
+}
+
+// NewEnemyManager - create new enemy manager
+func NewEnemyManager() EnemyManager {
+	e := EnemyManager{
+		log:  app.Logging{ModuleName: "ENEMY"},
+		lock: &sync.Mutex{},
+	}
+	e.Reset()
+	return e
+}
+
+// Reset - reset enemy manager
+func (e *EnemyManager) Reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.enemies = make([]*data.Enemy, 0)
+	e.updated = make([]*data.Enemy, 0)
+	e.encounterUID = ""
+}
+
+// ResetEncounter - reset with new encounter data
+func (e *EnemyManager) ResetEncounter(encounter data.Encounter) {
+	e.Reset()
+	e.encounterUID = encounter.UID
+}
+
+// isEnemy - check if given combatant id belongs to an enemy
+func isEnemy(combatantID int, owners *OwnerManager) bool {
+	if combatantID <= combatantMaxPlayerID {
+		return false
+	}
+	return owners == nil || owners.GetOwnerID(int32(combatantID)) == 0
+}
+
+// getEnemy - get enemy with given id, create if it doesn't exist
+func (e *EnemyManager) getEnemy(l *ParsedLogLine, combatantID int, name string, maxHP int) *data.Enemy {
+	for index := range e.enemies {
+		if e.enemies[index].CombatantID == int32(combatantID) {
+			if maxHP > int(e.enemies[index].MaxHP) {
+				e.enemies[index].MaxHP = int32(maxHP)
+			}
+			return e.enemies[index]
+		}
+	}
+	enemy := &data.Enemy{
+		EncounterUID:  e.encounterUID,
+		CombatantID:   int32(combatantID),
+		Name:          name,
+		MaxHP:         int32(maxHP),
+		FirstSeen:     l.Time,
+		DamageSources: make([]data.EnemyDamageSource, 0),
+	}
+	e.enemies = append(e.enemies, enemy)
+	return enemy
+}
+
+// markUpdated - flag enemy as updated for next dump
+func (e *EnemyManager) markUpdated(enemy *data.Enemy) {
+	for index := range e.updated {
+		if e.updated[index] == enemy {
+			return
+		}
+	}
+	e.updated = append(e.updated, enemy)
+}
+
+// ReadLogLine - parse log line and update enemy roster, owners are passed
+// in so pets aren't mistaken for enemies
+func (e *EnemyManager) ReadLogLine(l *ParsedLogLine, owners *OwnerManager) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	switch l.Type {
+	case LogTypeSingleTarget, LogTypeAoe, LogTypeDot:
+		{
+			// enemy attacking
+			if isEnemy(l.AttackerID, owners) && l.Type != LogTypeDot {
+				e.markUpdated(e.getEnemy(l, l.AttackerID, l.AttackerName, l.AttackerMaxHP))
+			}
+			// enemy being attacked by a player or pet
+			if !isEnemy(l.TargetID, owners) {
+				break
+			}
+			enemy := e.getEnemy(l, l.TargetID, l.TargetName, l.TargetMaxHP)
+			e.markUpdated(enemy)
+			sourceID, sourceName, _, ok := getActor(l)
+			if !ok || !l.HasFlag(LogFlagDamage) {
+				break
+			}
+			enemy.DamageTaken += int64(l.Damage)
+			for index := range enemy.DamageSources {
+				if enemy.DamageSources[index].SourceID == sourceID {
+					enemy.DamageSources[index].Damage += int64(l.Damage)
+					return
+				}
+			}
+			enemy.DamageSources = append(enemy.DamageSources, data.EnemyDamageSource{
+				SourceID:   sourceID,
+				SourceName: sourceName,
+				Damage:     int64(l.Damage),
+			})
+			break
+		}
+	case LogTypeDefeat:
+		{
+			// defeat messages only include names, match the oldest enemy still alive
+			for index := range e.enemies {
+				if !e.enemies[index].IsDefeated() && strings.EqualFold(e.enemies[index].Name, l.TargetName) {
+					e.enemies[index].DefeatedTime = l.Time
+					e.markUpdated(e.enemies[index])
+					break
+				}
+			}
+			break
+		}
+	}
+}
+
+// GetEnemies - get all enemies in encounter
+func (e *EnemyManager) GetEnemies() []data.Enemy {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	output := make([]data.Enemy, 0)
+	for index := range e.enemies {
+		enemy := *e.enemies[index]
+		enemy.DamageSources = append([]data.EnemyDamageSource{}, e.enemies[index].DamageSources...)
+		output = append(output, enemy)
+	}
+	return output
+}
+
+// SetEnemies - set enemies, used when loading previous encounter
+func (e *EnemyManager) SetEnemies(enemies []data.Enemy) {
+	e.Reset()
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for index := range enemies {
+		e.encounterUID = enemies[index].EncounterUID
+		e.enemies = append(e.enemies, &enemies[index])
+	}
+}
+
+// Dump - get enemies that have changed since last dump
+func (e *EnemyManager) Dump() []data.Enemy {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	output := make([]data.Enemy, 0)
+	for index := range e.updated {
+		enemy := *e.updated[index]
+		enemy.DamageSources = append([]data.EnemyDamageSource{}, e.updated[index].DamageSources...)
+		output = append(output, enemy)
+	}
+	e.updated = make([]*data.Enemy, 0)
+	return output
+}
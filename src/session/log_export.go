@@ -0,0 +1,132 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"../app"
+	"../data"
+)
+
+// logExportTimeFormat - time format of log line prefix in act log files
+const logExportTimeFormat = "15:04:05.000"
+
+// ExportLogLine - format log line the way act writes it to its log file, '[hh:mm:ss.fff] TT:field:...'
+func ExportLogLine(logLine data.LogLine, loc *time.Location) string {
+	raw := logLine.LogLine
+	if isPipeLogLine(raw) {
+		// network log types are decimal and lines carry a timestamp and hash
+		fields := strings.Split(raw, "|")
+		logType, _ := strconv.Atoi(fields[LogFieldType])
+		if len(fields) >= 3 {
+			fields = append(fields[:1], fields[2:len(fields)-1]...)
+		}
+		fields[LogFieldType] = fmt.Sprintf("%02X", logType)
+		raw = strings.Join(fields, ":")
+	} else if strings.HasPrefix(raw, "[") {
+		// replace time act added with the time the log line was received
+		if index := strings.Index(raw, "] "); index >= 0 {
+			raw = raw[index+2:]
+		}
+	}
+	logTime := logLine.Time
+	if loc != nil {
+		logTime = logTime.In(loc)
+	}
+	return "[" + logTime.Format(logExportTimeFormat) + "] " + raw
+}
+
+// ExportLog - write log lines of encounter in range to act log file, reads permanent log file in chunks
+func ExportLog(w io.Writer, storage BlobStorage, encounterUID string, rng LogLineRange, loc *time.Location) error {
+	bw := bufio.NewWriter(w)
+	_, err := storage.Stat(GetLogIndexFileKey(encounterUID))
+	if err == ErrBlobNotExist {
+		// log files written before the index existed can't be read in chunks
+		// without decompressing them again for each one, read them in one pass
+		err = exportLogWithoutIndex(bw, storage, encounterUID, rng, loc)
+		if err != nil {
+			return err
+		}
+		return bw.Flush()
+	} else if err != nil {
+		return err
+	}
+	rng.Limit = app.LogLineRangeLimit
+	for {
+		result, err := ReadLogLineRange(storage, encounterUID, rng)
+		if err != nil {
+			return err
+		}
+		if len(result.LogLines) == 0 {
+			break
+		}
+		for index := range result.LogLines {
+			// act writes windows line endings
+			_, err = bw.WriteString(ExportLogLine(result.LogLines[index], loc) + "\r\n")
+			if err != nil {
+				return err
+			}
+		}
+		rng.Offset = result.Offset + len(result.LogLines)
+	}
+	return bw.Flush()
+}
+
+// exportLogWithoutIndex - write log lines in range from log file that has no index
+func exportLogWithoutIndex(bw *bufio.Writer, storage BlobStorage, encounterUID string, rng LogLineRange, loc *time.Location) error {
+	f, err := storage.Open(GetLogFileKey(encounterUID), 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	lr, err := NewLogLineReader(gz)
+	if err != nil {
+		return err
+	}
+	// log lines are in time order, same as the range bounds of an index
+	for offset := 0; ; offset++ {
+		logLine, err := lr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if offset < rng.Offset || (!rng.Start.IsZero() && logLine.Time.Before(rng.Start)) {
+			continue
+		}
+		if !rng.End.IsZero() && logLine.Time.After(rng.End) {
+			return nil
+		}
+		_, err = bw.WriteString(ExportLogLine(logLine, loc) + "\r\n")
+		if err != nil {
+			return err
+		}
+	}
+}
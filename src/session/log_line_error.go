@@ -0,0 +1,245 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"../app"
+	"../data"
+)
+
+// ParseErrorTooShort - Parse error kind, log line too short to contain its type
+const ParseErrorTooShort = 1
+
+// ParseErrorInvalidType - Parse error kind, log line type could not be read
+const ParseErrorInvalidType = 2
+
+// ParseErrorMissingFields - Parse error kind, log line has fewer fields than its type requires
+const ParseErrorMissingFields = 3
+
+// ParseErrorInvalidField - Parse error kind, field value could not be converted
+const ParseErrorInvalidField = 4
+
+// ParseErrorInvalidText - Parse error kind, value taken from a text log line could not be converted
+const ParseErrorInvalidText = 5
+
+// parseErrorKindNames - names of parse error kinds, used in error messages
+var parseErrorKindNames = map[int]string{
+	ParseErrorTooShort:      "too short",
+	ParseErrorInvalidType:   "invalid type",
+	ParseErrorMissingFields: "missing fields",
+	ParseErrorInvalidField:  "invalid field",
+	ParseErrorInvalidText:   "invalid text",
+}
+
+// ParseError - error that occurred while parsing a log line
+type ParseError struct {
+	Kind    int
+	LogType int // -1 when type could not be read
+	Field   int // field index, for missing fields this is the number of fields found, -1 when not applicable
+	Value   string
+	Err     error
+}
+
+// Error - error message
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("%s parsing log type %02X", parseErrorKindNames[e.Kind], e.LogType)
+	if e.LogType < 0 {
+		msg = fmt.Sprintf("%s parsing log line", parseErrorKindNames[e.Kind])
+	}
+	if e.Field >= 0 {
+		msg += fmt.Sprintf(", field %d", e.Field)
+	}
+	if e.Value != "" {
+		msg += fmt.Sprintf(", value '%s'", e.Value)
+	}
+	if e.Err != nil {
+		msg += fmt.Sprintf(", %s", e.Err)
+	}
+	return msg
+}
+
+// Unwrap - get underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newMissingFieldsError - create error for log line with too few fields
+func newMissingFieldsError(logType int, fields []string) error {
+	return &ParseError{Kind: ParseErrorMissingFields, LogType: logType, Field: len(fields)}
+}
+
+// wrapParseError - wrap error that isn't already a parse error, used for
+// values taken from text log lines where there is no field index
+func wrapParseError(logType int, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*ParseError); ok {
+		return err
+	}
+	return &ParseError{Kind: ParseErrorInvalidText, LogType: logType, Field: -1, Err: err}
+}
+
+// invalidFieldError - create error for field that could not be converted
+func invalidFieldError(logType int, fields []string, index int, err error) error {
+	return &ParseError{Kind: ParseErrorInvalidField, LogType: logType, Field: index, Value: fields[index], Err: err}
+}
+
+// hexField - convert hex field to int
+func hexField(logType int, fields []string, index int) (int, error) {
+	value, err := hexToInt(fields[index])
+	if err != nil {
+		return 0, invalidFieldError(logType, fields, index, err)
+	}
+	return value, nil
+}
+
+// intField - convert decimal field to int
+func intField(logType int, fields []string, index int) (int, error) {
+	value, err := strconv.Atoi(fields[index])
+	if err != nil {
+		return 0, invalidFieldError(logType, fields, index, err)
+	}
+	return value, nil
+}
+
+// floatField - convert decimal field to float
+func floatField(logType int, fields []string, index int) (float64, error) {
+	value, err := strconv.ParseFloat(fields[index], 64)
+	if err != nil {
+		return 0, invalidFieldError(logType, fields, index, err)
+	}
+	return value, nil
+}
+
+// isKnownLogType - check if log type is one the parser understands
+func isKnownLogType(logType int) bool {
+	switch logType {
+	case LogTypeGameLog, LogTypeZoneChange, LogTypeAddCombatant, LogTypeRemoveCombatant,
+		LogTypeCastStart, LogTypeSingleTarget, LogTypeAoe, LogTypeCastCancel, LogTypeDot,
+		LogTypeDefeat, LogTypeGainEffect, LogTypeLoseEffect, LogTypeHPPercent, LogTypeFFLPCombatant:
+		return true
+	}
+	return false
+}
+
+// parseStatKey - key used for log type in parse stats
+func parseStatKey(logType int) string {
+	if logType < 0 {
+		return "invalid"
+	}
+	return fmt.Sprintf("%02X", logType)
+}
+
+// ParseStats - counts log lines that failed to parse or are of an unknown
+// type, failing lines are optionally sampled to disk for later fixing
+type ParseStats struct {
+	errors       map[int]int64
+	unknownTypes map[int]int64
+	samplePath   string
+	log          app.Logging
+	lock         *sync.Mutex
+}
+
+// NewParseStats - create new parse stats, sample path can be empty to disable sampling
+func NewParseStats(samplePath string) ParseStats {
+	return ParseStats{
+		errors:       make(map[int]int64),
+		unknownTypes: make(map[int]int64),
+		samplePath:   samplePath,
+		log:          app.Logging{ModuleName: "PARSE"},
+		lock:         &sync.Mutex{},
+	}
+}
+
+// ReadParseResult - account for the result of parsing a log line
+func (p *ParseStats) ReadParseResult(logLine data.LogLine, l *ParsedLogLine, err error) {
+	p.lock.Lock()
+	if err == nil {
+		if !isKnownLogType(l.Type) {
+			p.unknownTypes[l.Type]++
+		}
+		p.lock.Unlock()
+		return
+	}
+	logType := l.Type
+	parseErr, ok := err.(*ParseError)
+	if ok {
+		logType = parseErr.LogType
+	}
+	p.errors[logType]++
+	// sample first lines of each type then every nth line after
+	count := p.errors[logType]
+	p.lock.Unlock()
+	if p.samplePath == "" || (count > app.ParseErrorSampleMax && count%app.ParseErrorSampleRate != 0) {
+		return
+	}
+	// written outside the lock so slow disk doesn't block other parsers,
+	// each sample is a single append so concurrent writes don't interleave
+	if err := p.writeSample(logType, logLine, err); err != nil {
+		p.log.Error(err)
+	}
+}
+
+// writeSample - append failing log line to sample file for its type
+func (p *ParseStats) writeSample(logType int, logLine data.LogLine, parseErr error) error {
+	err := os.MkdirAll(p.samplePath, 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(
+		filepath.Join(p.samplePath, fmt.Sprintf("parse_errors_%s.log", parseStatKey(logType))),
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
+		0644,
+	)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\t%s\t%s\n", logLine.Time.Format(time.RFC3339Nano), parseErr, logLine.LogLine)
+	return err
+}
+
+// GetErrorCounts - get number of log lines that failed to parse by log type
+func (p *ParseStats) GetErrorCounts() map[string]int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	output := make(map[string]int64)
+	for logType, count := range p.errors {
+		output[parseStatKey(logType)] = count
+	}
+	return output
+}
+
+// GetUnknownTypeCounts - get number of log lines with a type the parser doesn't understand by log type
+func (p *ParseStats) GetUnknownTypeCounts() map[string]int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	output := make(map[string]int64)
+	for logType, count := range p.unknownTypes {
+		output[parseStatKey(logType)] = count
+	}
+	return output
+}
@@ -0,0 +1,302 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"time"
+
+	"../data"
+)
+
+// LogFileVersion - version of length prefixed log file format
+const LogFileVersion = 2
+
+// LogLineMaxRecordSize - max size of a single log line record
+const LogLineMaxRecordSize = 1 << 20
+
+// LogLineIndexBlockSize - number of log lines per gzip member in permanent log files
+const LogLineIndexBlockSize = 256
+
+// ErrLegacyLogFile - error returned when a legacy log file can't be indexed
+var ErrLegacyLogFile = errors.New("legacy log files have no index")
+
+// logFileMagic - marks start of length prefixed log file, legacy files start with a log line
+var logFileMagic = []byte("FFLL")
+
+// logIndexMagic - marks start of log index file
+var logIndexMagic = []byte("FFLI")
+
+// logIndexEntrySize - size of a single entry in log index file
+const logIndexEntrySize = 24
+
+// LogLineIndexEntry - location of a single log line record in a log file
+type LogLineIndexEntry struct {
+	Time        time.Time
+	Offset      int64 // offset of record in uncompressed log data
+	BlockOffset int64 // offset of gzip member containing record, same as offset for uncompressed files
+}
+
+// logFileHeader - get header for length prefixed log file
+func logFileHeader() []byte {
+	return append(append([]byte{}, logFileMagic...), LogFileVersion)
+}
+
+// writeLogLineRecord - write log line prefixed with its length, returns number of bytes written
+func writeLogLineRecord(w io.Writer, logLine *data.LogLine) (int, error) {
+	logLineBytes := logLine.ToBytes()
+	record := make([]byte, 4, 4+len(logLineBytes))
+	binary.BigEndian.PutUint32(record, uint32(len(logLineBytes)))
+	record = append(record, logLineBytes...)
+	return w.Write(record)
+}
+
+// readLogLineRecord - read length prefixed log line
+func readLogLineRecord(r io.Reader) (data.LogLine, error) {
+	logLine := data.LogLine{}
+	var size uint32
+	err := binary.Read(r, binary.BigEndian, &size)
+	if err != nil {
+		return logLine, err
+	}
+	if size == 0 || size > LogLineMaxRecordSize {
+		return logLine, fmt.Errorf("log line record has invalid size %d", size)
+	}
+	logLineBytes := make([]byte, size)
+	_, err = io.ReadFull(r, logLineBytes)
+	if err == io.EOF {
+		return logLine, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return logLine, err
+	}
+	err = logLine.FromBytes(logLineBytes)
+	return logLine, err
+}
+
+// readLegacyLogLineRecord - read log line padded to LogLineByteSize
+func readLegacyLogLineRecord(r io.Reader) (data.LogLine, error) {
+	logLine := data.LogLine{}
+	logLineBytes := make([]byte, LogLineByteSize)
+	_, err := io.ReadFull(r, logLineBytes)
+	if err == io.ErrUnexpectedEOF {
+		return logLine, fmt.Errorf("log read was less then %d bytes", LogLineByteSize)
+	} else if err != nil {
+		return logLine, err
+	}
+	err = logLine.FromBytes(logLineBytes)
+	return logLine, err
+}
+
+// LogLineReader - reads log lines from a log file, handles both length prefixed and legacy padded log files
+type LogLineReader struct {
+	r      *bufio.Reader
+	Legacy bool
+}
+
+// NewLogLineReader - create new log line reader, detects log file format from header
+func NewLogLineReader(r io.Reader) (*LogLineReader, error) {
+	lr := &LogLineReader{r: bufio.NewReader(r)}
+	header, err := lr.r.Peek(len(logFileMagic) + 1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case len(header) == 0:
+		// empty log file
+		return lr, nil
+	case len(header) > len(logFileMagic) && bytes.Equal(header[:len(logFileMagic)], logFileMagic):
+		if header[len(logFileMagic)] > LogFileVersion {
+			return nil, fmt.Errorf("log file version %d is not supported", header[len(logFileMagic)])
+		}
+		_, err = lr.r.Discard(len(header))
+		return lr, err
+	}
+	// files written before length prefixed records have no header
+	lr.Legacy = true
+	return lr, nil
+}
+
+// Read - read next log line, returns io.EOF when there are no more log lines
+func (lr *LogLineReader) Read() (data.LogLine, error) {
+	if lr.Legacy {
+		return readLegacyLogLineRecord(lr.r)
+	}
+	return readLogLineRecord(lr.r)
+}
+
+// GetLogIndexFileKey - get storage key of permanent log index file
+func GetLogIndexFileKey(encounterUID string) string {
+	return fmt.Sprintf("fflp_%s_LogLine.idx", encounterUID)
+}
+
+// GetLogIndexFilePath - get path to permanent log index file in local storage
+func GetLogIndexFilePath(savePath string, encounterUID string) string {
+	return path.Join(savePath, GetLogIndexFileKey(encounterUID))
+}
+
+// WriteLogLineIndex - write log line index entries
+func WriteLogLineIndex(w io.Writer, index []LogLineIndexEntry) error {
+	output := logIndexHeader()
+	buf := make([]byte, logIndexEntrySize)
+	for _, entry := range index {
+		binary.BigEndian.PutUint64(buf[0:], uint64(entry.Time.UnixNano()))
+		binary.BigEndian.PutUint64(buf[8:], uint64(entry.Offset))
+		binary.BigEndian.PutUint64(buf[16:], uint64(entry.BlockOffset))
+		output = append(output, buf...)
+	}
+	_, err := w.Write(output)
+	return err
+}
+
+// ReadLogLineIndex - read log line index entries
+func ReadLogLineIndex(r io.Reader) ([]LogLineIndexEntry, error) {
+	header := make([]byte, len(logIndexHeader()))
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(logIndexMagic)], logIndexMagic) {
+		return nil, fmt.Errorf("invalid log index header")
+	}
+	if header[len(logIndexMagic)] > LogFileVersion {
+		return nil, fmt.Errorf("log index version %d is not supported", header[len(logIndexMagic)])
+	}
+	index := make([]LogLineIndexEntry, 0)
+	buf := make([]byte, logIndexEntrySize)
+	for {
+		_, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return index, err
+		}
+		index = append(index, LogLineIndexEntry{
+			Time:        time.Unix(0, int64(binary.BigEndian.Uint64(buf[0:]))),
+			Offset:      int64(binary.BigEndian.Uint64(buf[8:])),
+			BlockOffset: int64(binary.BigEndian.Uint64(buf[16:])),
+		})
+	}
+	return index, nil
+}
+
+// BuildLogLineIndex - rebuild index of permanent log file from its contents
+func BuildLogLineIndex(r io.Reader) ([]LogLineIndexEntry, error) {
+	// gzip reads compressed data byte by byte from a byte reader, so the
+	// count is exactly at the start of the next member once one ends
+	cr := &countingByteReader{r: bufio.NewReader(r)}
+	gf, err := gzip.NewReader(cr)
+	if err != nil {
+		return nil, err
+	}
+	gf.Multistream(false)
+	// header is in its own member
+	header, err := ioutil.ReadAll(gf)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header, logFileHeader()) {
+		if len(header) > len(logFileMagic) && bytes.Equal(header[:len(logFileMagic)], logFileMagic) {
+			return nil, fmt.Errorf("log file version %d is not supported", header[len(logFileMagic)])
+		}
+		return nil, ErrLegacyLogFile
+	}
+	index := make([]LogLineIndexEntry, 0)
+	ur := &countingReader{r: gf, n: int64(len(header))}
+	for {
+		blockOffset := cr.n
+		err = gf.Reset(cr)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return index, err
+		}
+		gf.Multistream(false)
+		for {
+			offset := ur.n
+			logLine, err := readLogLineRecord(ur)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return index, err
+			}
+			index = append(index, LogLineIndexEntry{
+				Time:        logLine.Time,
+				Offset:      offset,
+				BlockOffset: blockOffset,
+			})
+		}
+	}
+	return index, nil
+}
+
+// logIndexHeader - get header for log index file
+func logIndexHeader() []byte {
+	return append(append([]byte{}, logIndexMagic...), LogFileVersion)
+}
+
+// countingWriter - writer that tracks number of bytes written
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingReader - reader that tracks number of bytes read
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingByteReader - byte reader that tracks number of bytes read
+type countingByteReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingByteReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingByteReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
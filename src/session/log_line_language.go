@@ -0,0 +1,119 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LogLanguageEnglish - Game client language code, English
+const LogLanguageEnglish = "en"
+
+// LogLanguageJapanese - Game client language code, Japanese
+const LogLanguageJapanese = "ja"
+
+// LogLanguageGerman - Game client language code, German
+const LogLanguageGerman = "de"
+
+// LogLanguageFrench - Game client language code, French
+const LogLanguageFrench = "fr"
+
+// LogLanguage - patterns for log lines that are sent as text in the language of the game client
+type LogLanguage struct {
+	Code string
+	// Defeat - groups 'target' and 'source'
+	Defeat *regexp.Regexp
+	// ZoneChange - group 'zone'
+	ZoneChange *regexp.Regexp
+	// RemoveCombatant - groups 'id', 'name' and 'maxhp'
+	RemoveCombatant *regexp.Regexp
+	// WorldName - groups 'first', 'last' and 'world', character names are always latin
+	WorldName *regexp.Regexp
+}
+
+// logWorldNamePattern - character name followed directly by world name
+const logWorldNamePattern = "^(?P<first>[a-zA-Z'\\-]*) (?P<last>[A-Z'][a-z'\\-]*)(?P<world>[A-Z][a-z]*)"
+
+// logLanguages - pattern sets for all supported game client languages, english first as it is the most common
+var logLanguages = []*LogLanguage{
+	{
+		Code:            LogLanguageEnglish,
+		Defeat:          regexp.MustCompile("^(?P<target>.+?) was defeated by (?P<source>.+?)\\.?$"),
+		ZoneChange:      regexp.MustCompile("^Changed Zone to (?P<zone>.*)\\.$"),
+		RemoveCombatant: regexp.MustCompile("^(?P<id>[A-F0-9]*):Removing combatant (?P<name>.+?)\\.  Max HP: (?P<maxhp>[0-9]*)\\."),
+		WorldName:       regexp.MustCompile(logWorldNamePattern),
+	},
+	{
+		Code:            LogLanguageJapanese,
+		Defeat:          regexp.MustCompile("^(?P<target>.+?)は、?(?P<source>.+?)に倒された。?$"),
+		ZoneChange:      regexp.MustCompile("^(?P<zone>.+?)にゾーンチェンジした。?$"),
+		RemoveCombatant: regexp.MustCompile("^(?P<id>[A-F0-9]*):戦闘メンバーから(?P<name>.+?)を削除しました。\\s*最大HP: ?(?P<maxhp>[0-9]*)。?"),
+		WorldName:       regexp.MustCompile(logWorldNamePattern),
+	},
+	{
+		Code:            LogLanguageGerman,
+		Defeat:          regexp.MustCompile("^(?P<target>.+?) wurde von (?P<source>.+?) besiegt\\.?$"),
+		ZoneChange:      regexp.MustCompile("^Gebiet gewechselt zu (?P<zone>.*)\\.$"),
+		RemoveCombatant: regexp.MustCompile("^(?P<id>[A-F0-9]*):Entferne Kampfteilnehmer (?P<name>.+?)\\.  Max\\. LP: (?P<maxhp>[0-9]*)\\."),
+		WorldName:       regexp.MustCompile(logWorldNamePattern),
+	},
+	{
+		Code:            LogLanguageFrench,
+		Defeat:          regexp.MustCompile("^(?P<target>.+?) a été vaincue? par (?P<source>.+?)\\.?$"),
+		ZoneChange:      regexp.MustCompile("^Changement de zone vers (?P<zone>.*)\\.$"),
+		RemoveCombatant: regexp.MustCompile("^(?P<id>[A-F0-9]*):Retrait du combattant (?P<name>.+?)\\.  PV max ?: (?P<maxhp>[0-9]*)\\."),
+		WorldName:       regexp.MustCompile(logWorldNamePattern),
+	},
+}
+
+// GetLogLanguages - get pattern sets to try for given game client language,
+// all languages are tried when the language is unknown
+func GetLogLanguages(code string) []*LogLanguage {
+	code = strings.ToLower(strings.TrimSpace(code))
+	// accept region suffixes, 'ja-JP', 'de_DE', etc
+	if len(code) > 2 {
+		code = code[:2]
+	}
+	for index := range logLanguages {
+		if logLanguages[index].Code == code {
+			return logLanguages[index : index+1]
+		}
+	}
+	return logLanguages
+}
+
+// matchLogLanguage - match message against the pattern picked from each
+// language, returns named groups of the first match or nil
+func matchLogLanguage(languages []*LogLanguage, pattern func(*LogLanguage) *regexp.Regexp, message string) map[string]string {
+	for _, language := range languages {
+		re := pattern(language)
+		match := re.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+		groups := make(map[string]string)
+		for index, name := range re.SubexpNames() {
+			if name != "" {
+				groups[name] = match[index]
+			}
+		}
+		return groups
+	}
+	return nil
+}
@@ -19,22 +19,21 @@ package session
 
 import (
 	"bufio"
+	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
-	"path/filepath"
 	"sync"
 	"time"
 
 	"../app"
 	"../data"
-	times "gopkg.in/djherbis/times.v1"
 )
 
-// LogLineByteSize - size of log line bytes
+// LogLineByteSize - size of log line bytes in legacy log files
 const LogLineByteSize = 512
 
 // LogLineReadLimit - limit of log lines per read
@@ -48,8 +47,10 @@ type LogLineManager struct {
 	logLines     []*data.LogLine
 	lastTime     time.Time
 	dumpFile     *os.File
+	dumpOffset   int64
+	index        []LogLineIndexEntry
 	dumpFileLock *sync.Mutex
-	savePath     string
+	storage      BlobStorage
 	log          app.Logging
 	encounterUID string
 }
@@ -59,7 +60,7 @@ func NewLogLineManager() LogLineManager {
 	l := LogLineManager{
 		log:          app.Logging{ModuleName: "LOGLINE"},
 		dumpFileLock: &sync.Mutex{},
-		savePath:     app.FileStorePath,
+		storage:      GetBlobStorage(),
 	}
 	l.Reset()
 	return l
@@ -70,6 +71,8 @@ func (l *LogLineManager) Reset() {
 	l.logLines = make([]*data.LogLine, 0)
 	l.lastTime = time.Now()
 	l.encounterUID = ""
+	l.index = make([]LogLineIndexEntry, 0)
+	l.dumpOffset = 0
 	if l.dumpFile != nil {
 		l.dumpFileLock.Lock()
 		defer l.dumpFileLock.Unlock()
@@ -85,20 +88,24 @@ func (l *LogLineManager) SetEncounterUID(encounterUID string) {
 	l.encounterUID = encounterUID
 }
 
-// SetSavePath - set path to save permanent log file to
+// SetSavePath - set local path to save permanent log file to
 func (l *LogLineManager) SetSavePath(path string) {
-	l.savePath = path
+	l.storage = NewLocalBlobStorage(path)
 }
 
-// Update - add new log line
-func (l *LogLineManager) Update(logLine data.LogLine) {
+// SetStorage - set storage to save permanent log file to
+func (l *LogLineManager) SetStorage(storage BlobStorage) {
+	l.storage = storage
+}
+
+// UpdateParsed - add new log line that has already been parsed
+func (l *LogLineManager) UpdateParsed(logLine data.LogLine, pLogLine *ParsedLogLine) {
 	// ignore log lines in the past
 	if logLine.Time.Before(l.lastTime) {
 		return
 	}
 	// if log line parser sets 'Raw' to empty then it should be ignored
 	// (does this for chat messages)
-	pLogLine, _ := ParseLogLine(logLine)
 	if pLogLine.Raw == "" {
 		return
 	}
@@ -121,49 +128,53 @@ func (l *LogLineManager) Dump() ([]data.LogLine, error) {
 		if err != nil {
 			return nil, err
 		}
+		header := logFileHeader()
+		_, err = l.dumpFile.Write(header)
+		if err != nil {
+			return nil, err
+		}
+		l.dumpOffset = int64(len(header))
+		l.index = make([]LogLineIndexEntry, 0)
 	}
-	// write to dump file
+	// write length prefixed log lines to dump file
 	output := make([]data.LogLine, 0)
+	var buf bytes.Buffer
 	for index := range l.logLines {
 		output = append(output, *l.logLines[index])
-		// get log line bytes and fill to max size
-		logLineBytes := l.logLines[index].ToBytes()
-		if len(logLineBytes) > LogLineByteSize {
-			continue
-		}
-		for len(logLineBytes) < LogLineByteSize {
-			logLineBytes = append(logLineBytes, 0)
-		}
-		// write to file
-		_, err := l.dumpFile.Write(logLineBytes)
+		n, err := writeLogLineRecord(&buf, l.logLines[index])
 		if err != nil {
 			return nil, err
 		}
+		offset := l.dumpOffset + int64(buf.Len()-n)
+		l.index = append(l.index, LogLineIndexEntry{
+			Time:        l.logLines[index].Time,
+			Offset:      offset,
+			BlockOffset: offset,
+		})
 	}
+	_, err = l.dumpFile.Write(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	l.dumpOffset += int64(buf.Len())
 	l.logLines = make([]*data.LogLine, 0)
 	return output, nil
 }
 
-// GetLogLinesFromReader - retrieve log lines from a reader
-func GetLogLinesFromReader(r io.Reader) ([]data.LogLine, error) {
+// GetLogLinesFromReader - retrieve log lines from a log line reader
+func GetLogLinesFromReader(r *LogLineReader) ([]data.LogLine, error) {
 	output := make([]data.LogLine, 0)
 	for {
-		logLineBytes := make([]byte, LogLineByteSize)
-		n, err := io.ReadFull(r, logLineBytes)
-		if err == io.EOF || n == 0 {
+		logLine, err := r.Read()
+		if err == io.EOF {
 			break
 		} else if err != nil {
 			return output, err
-		} else if n < LogLineByteSize {
-			return output, fmt.Errorf("log read was less then %d bytes", LogLineByteSize)
-		} else if n == LogLineByteSize {
-			logLine := data.LogLine{}
-			logLine.FromBytes(logLineBytes)
-			output = append(output, logLine)
-			// reached read limit
-			if len(output) >= LogLineReadLimit {
-				break
-			}
+		}
+		output = append(output, logLine)
+		// reached read limit
+		if len(output) >= LogLineReadLimit {
+			break
 		}
 	}
 	return output, nil
@@ -173,25 +184,75 @@ func GetLogLinesFromReader(r io.Reader) ([]data.LogLine, error) {
 func (l *LogLineManager) GetLogLines(offset int) ([]data.LogLine, error) {
 	l.dumpFileLock.Lock()
 	defer l.dumpFileLock.Unlock()
+	if offset < 0 || offset >= len(l.index) {
+		return make([]data.LogLine, 0), nil
+	}
 	defer l.dumpFile.Seek(0, 2)
-	_, err := l.dumpFile.Seek(int64(offset*LogLineByteSize), 0)
+	_, err := l.dumpFile.Seek(l.index[offset].Offset, 0)
 	if err != nil {
 		return nil, err
 	}
-	return GetLogLinesFromReader(l.dumpFile)
+	return GetLogLinesFromReader(&LogLineReader{r: bufio.NewReader(l.dumpFile)})
+}
+
+// GetDumpSize - get size of dump file, log lines are uncompressed so the
+// permanent log file will be smaller
+func (l *LogLineManager) GetDumpSize() int64 {
+	l.dumpFileLock.Lock()
+	defer l.dumpFileLock.Unlock()
+	return l.dumpOffset
+}
+
+// GetLogLineIndex - get index of log lines in dump
+func (l *LogLineManager) GetLogLineIndex() []LogLineIndexEntry {
+	l.dumpFileLock.Lock()
+	defer l.dumpFileLock.Unlock()
+	return append([]LogLineIndexEntry{}, l.index...)
+}
+
+// GetLogLineRange - retrieve range of log lines from dump, or from permanent log file if there is no dump
+func (l *LogLineManager) GetLogLineRange(rng LogLineRange) (LogLineRangeResult, error) {
+	l.dumpFileLock.Lock()
+	defer l.dumpFileLock.Unlock()
+	if l.dumpFile == nil {
+		return ReadLogLineRange(l.storage, l.encounterUID, rng)
+	}
+	start, end := rng.Bounds(l.index)
+	result := LogLineRangeResult{
+		Offset:   start,
+		Total:    len(l.index),
+		LogLines: make([]data.LogLine, 0),
+	}
+	if start >= end {
+		return result, nil
+	}
+	defer l.dumpFile.Seek(0, 2)
+	_, err := l.dumpFile.Seek(l.index[start].Offset, 0)
+	if err != nil {
+		return result, err
+	}
+	result.LogLines, err = readLogLineRecords(&LogLineReader{r: bufio.NewReader(l.dumpFile)}, end-start)
+	return result, err
 }
 
-// GetLogFilePath - get path to permanent log file
+// GetLogFileKey - get storage key of permanent log file
+func GetLogFileKey(encounterUID string) string {
+	return fmt.Sprintf("fflp_%s_LogLine.dat", encounterUID)
+}
+
+// GetLogFilePath - get path to permanent log file in local storage
 func GetLogFilePath(savePath string, encounterUID string) string {
-	return path.Join(savePath, fmt.Sprintf("fflp_%s_LogLine.dat", encounterUID))
+	return path.Join(savePath, GetLogFileKey(encounterUID))
 }
 
-// GetLogFilePath - get path to permanent log file
-func (l *LogLineManager) GetLogFilePath() string {
-	return GetLogFilePath(l.savePath, l.encounterUID)
+// OpenLogFile - open permanent log file, returns ErrBlobNotExist if it hasn't been saved
+func (l *LogLineManager) OpenLogFile() (io.ReadCloser, error) {
+	return l.storage.Open(GetLogFileKey(l.encounterUID), 0)
 }
 
 // Save - save log lines to permanent storage
+// log lines are written in blocks of separate gzip members so that the
+// index file can be used to start decompressing near any log line
 func (l *LogLineManager) Save() error {
 	if l.encounterUID == "" {
 		return fmt.Errorf("can't save log lines without encounter uid set")
@@ -200,76 +261,85 @@ func (l *LogLineManager) Save() error {
 	globalLogLock.Unlock()
 	l.dumpFileLock.Lock()
 	defer l.dumpFileLock.Unlock()
+	if l.dumpFile == nil {
+		return fmt.Errorf("can't save log lines before they are dumped")
+	}
 	defer l.dumpFile.Seek(0, 2)
-	// open output file
-	f, err := os.OpenFile(l.GetLogFilePath(), os.O_CREATE|os.O_WRONLY, 0664)
+	// open output file, it is only stored once closed
+	f, err := l.storage.Create(GetLogFileKey(l.encounterUID))
 	if err != nil {
 		return err
 	}
-	// prepare gzip write
-	gf := gzip.NewWriter(f)
-	fw := bufio.NewWriter(gf)
-	defer f.Close()
+	index, err := l.writeLogFile(f)
+	if err != nil {
+		f.Discard()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	// write index file
+	idxf, err := l.storage.Create(GetLogIndexFileKey(l.encounterUID))
+	if err != nil {
+		return err
+	}
+	err = WriteLogLineIndex(idxf, index)
+	if err != nil {
+		idxf.Discard()
+		return err
+	}
+	return idxf.Close()
+}
+
+// writeLogFile - write compressed dump file contents to permanent log file, returns index of written log lines
+func (l *LogLineManager) writeLogFile(w io.Writer) ([]LogLineIndexEntry, error) {
+	// header gets its own gzip member so every block starts on a log line
+	cw := &countingWriter{w: w}
+	gf := gzip.NewWriter(cw)
+	header := logFileHeader()
+	_, err := gf.Write(header)
+	if err != nil {
+		return nil, err
+	}
 	// read dump file
-	l.dumpFile.Seek(0, 0)
-	buf := make([]byte, LogLineByteSize)
+	_, err = l.dumpFile.Seek(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	lr, err := NewLogLineReader(l.dumpFile)
+	if err != nil {
+		return nil, err
+	}
+	index := make([]LogLineIndexEntry, 0)
+	offset := int64(len(header))
+	blockOffset := int64(0)
 	for {
-		n, err := io.ReadFull(l.dumpFile, buf)
-		if err == io.EOF || n == 0 {
+		logLine, err := lr.Read()
+		if err == io.EOF {
 			break
 		} else if err != nil {
-			return err
-		} else if n == LogLineByteSize {
-			_, err := fw.Write(buf)
-			if err != nil {
-				return err
-			}
+			return nil, err
 		}
-	}
-	fw.Flush()
-	gf.Flush()
-	gf.Close()
-	return nil
-}
-
-// LogLineCleanUpRoutine - perform log line clean up operations at regular interval
-func LogLineCleanUpRoutine() {
-	cleanUp := func() {
-		globalLogLock.Lock()
-		defer globalLogLock.Unlock()
-		logger := app.Logging{ModuleName: "LOGLINE-CLEANUP"}
-		cleanCount := 0
-		noBirthTimeCount := 0
-		cleanUpDate := time.Now().Add(time.Duration(-app.EncounterLogDeleteDays*24) * time.Hour)
-		logger.Start(fmt.Sprintf("Start log file clean up. (Clean up log older than %s.)", cleanUpDate))
-		err := filepath.Walk(app.FileStorePath, func(path string, info os.FileInfo, err error) error {
+		// start new block
+		if len(index)%LogLineIndexBlockSize == 0 {
+			err = gf.Close()
 			if err != nil {
-				return err
+				return nil, err
 			}
-			if info.IsDir() || filepath.Ext(path) != ".dat" {
-				return nil
-			}
-			t, err := times.Stat(path)
-			if err == nil && !t.HasBirthTime() {
-				noBirthTimeCount++
-			}
-			if (err == nil && t.HasBirthTime() && t.BirthTime().Before(cleanUpDate)) || info.ModTime().Before(cleanUpDate) {
-				cleanCount++
-				return os.Remove(path)
-			}
-			return nil
-		})
-		if err != nil {
-			logger.Error(err)
-			return
+			blockOffset = cw.n
+			gf = gzip.NewWriter(cw)
 		}
-		logger.Finish(fmt.Sprintf("Finish file clean up. (%d files removed.)", cleanCount))
-		if noBirthTimeCount > 0 {
-			logger.Log(fmt.Sprintf("%d files had no creation time.", noBirthTimeCount))
+		n, err := writeLogLineRecord(gf, &logLine)
+		if err != nil {
+			return nil, err
 		}
+		index = append(index, LogLineIndexEntry{
+			Time:        logLine.Time,
+			Offset:      offset,
+			BlockOffset: blockOffset,
+		})
+		offset += int64(n)
 	}
-	cleanUp()
-	for range time.Tick(time.Millisecond * app.CleanUpRoutineRate) {
-		cleanUp()
-	}
+	return index, gf.Close()
 }
@@ -19,9 +19,7 @@ package session
 
 import (
 	"fmt"
-	"log"
 	"regexp"
-	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -35,15 +33,24 @@ const LogTypeGameLog = 0x00
 // LogTypeZoneChange - Log type identifier, zone change
 const LogTypeZoneChange = 0x01
 
+// LogTypeAddCombatant - Log type identifier, add combatant
+const LogTypeAddCombatant = 0x03
+
 // LogTypeRemoveCombatant - Log type identifier, remove combatant
 const LogTypeRemoveCombatant = 0x04
 
+// LogTypeCastStart - Log type identifier, start casting
+const LogTypeCastStart = 0x14
+
 // LogTypeSingleTarget - Log type identifier, single target action
 const LogTypeSingleTarget = 0x15
 
 // LogTypeAoe - Log type identifier, aoe action
 const LogTypeAoe = 0x16
 
+// LogTypeCastCancel - Log type identifier, cast cancelled/interrupted
+const LogTypeCastCancel = 0x17
+
 // LogTypeDot - Log type identifier, dot/hot tick
 const LogTypeDot = 0x18
 
@@ -101,6 +108,96 @@ const LogFieldAttackerCurrentHP = 33
 // LogFieldAttackerMaxHP - Log field identifier, attacker max hp
 const LogFieldAttackerMaxHP = 34
 
+// LogFieldAddCombatantID - Log field identifier, new combatant id (add combatant)
+const LogFieldAddCombatantID = 1
+
+// LogFieldAddCombatantName - Log field identifier, new combatant name (add combatant)
+const LogFieldAddCombatantName = 2
+
+// LogFieldAddCombatantOwnerID - Log field identifier, owner id of pets/summons (add combatant)
+const LogFieldAddCombatantOwnerID = 5
+
+// LogFieldAddCombatantMaxHP - Log field identifier, max hp (add/remove combatant)
+const LogFieldAddCombatantMaxHP = 11
+
+// LogFieldDefeatTargetID - Log field identifier, defeated combatant id (defeated)
+const LogFieldDefeatTargetID = 1
+
+// LogFieldDefeatTargetName - Log field identifier, defeated combatant name (defeated)
+const LogFieldDefeatTargetName = 2
+
+// LogFieldDefeatSourceID - Log field identifier, defeating combatant id (defeated)
+const LogFieldDefeatSourceID = 3
+
+// LogFieldDefeatSourceName - Log field identifier, defeating combatant name (defeated)
+const LogFieldDefeatSourceName = 4
+
+// LogFieldZoneName - Log field identifier, zone name (zone change)
+const LogFieldZoneName = 2
+
+// LogFieldTargetPosition - Log field identifier, target x position (followed by y, z and heading)
+const LogFieldTargetPosition = 29
+
+// LogFieldAttackerPosition - Log field identifier, attacker x position (followed by y, z and heading)
+const LogFieldAttackerPosition = 39
+
+// LogFieldCastDuration - Log field identifier, cast time in seconds (start casting)
+const LogFieldCastDuration = 7
+
+// LogFieldCancelReason - Log field identifier, reason cast ended (cast cancelled)
+const LogFieldCancelReason = 5
+
+// LogFieldEffectID - Log field identifier, effect id (gain/lose effect)
+const LogFieldEffectID = 1
+
+// LogFieldEffectName - Log field identifier, effect name (gain/lose effect)
+const LogFieldEffectName = 2
+
+// LogFieldEffectDuration - Log field identifier, effect duration in seconds (gain/lose effect)
+const LogFieldEffectDuration = 3
+
+// LogFieldEffectSourceID - Log field identifier, effect source id (gain/lose effect)
+const LogFieldEffectSourceID = 4
+
+// LogFieldEffectSourceName - Log field identifier, effect source name (gain/lose effect)
+const LogFieldEffectSourceName = 5
+
+// LogFieldEffectTargetID - Log field identifier, effect target id (gain/lose effect)
+const LogFieldEffectTargetID = 6
+
+// LogFieldEffectTargetName - Log field identifier, effect target name (gain/lose effect)
+const LogFieldEffectTargetName = 7
+
+// LogFieldEffectStacks - Log field identifier, effect stacks (gain/lose effect)
+const LogFieldEffectStacks = 8
+
+// LogFieldTickTargetID - Log field identifier, tick target id (dot/hot tick)
+const LogFieldTickTargetID = 1
+
+// LogFieldTickTargetName - Log field identifier, tick target name (dot/hot tick)
+const LogFieldTickTargetName = 2
+
+// LogFieldTickType - Log field identifier, tick type, either 'DoT' or 'HoT' (dot/hot tick)
+const LogFieldTickType = 3
+
+// LogFieldTickEffectID - Log field identifier, tick effect id (dot/hot tick)
+const LogFieldTickEffectID = 4
+
+// LogFieldTickDamage - Log field identifier, tick damage/heal amount (dot/hot tick)
+const LogFieldTickDamage = 5
+
+// LogFieldTickTargetCurrentHP - Log field identifier, tick target current hp (dot/hot tick)
+const LogFieldTickTargetCurrentHP = 6
+
+// LogFieldTickTargetMaxHP - Log field identifier, tick target max hp (dot/hot tick)
+const LogFieldTickTargetMaxHP = 7
+
+// LogFieldTickSourceID - Log field identifier, tick source id, newer plugin versions only (dot/hot tick)
+const LogFieldTickSourceID = 16
+
+// LogFieldTickSourceName - Log field identifier, tick source name, newer plugin versions only (dot/hot tick)
+const LogFieldTickSourceName = 17
+
 // LogFlagDamage - Log flag, damage
 const LogFlagDamage = 1
 
@@ -125,6 +222,9 @@ const LogFlagParry = 7
 // LogFlagInstantDeath - Log flag, instant death
 const LogFlagInstantDeath = 8
 
+// LogFlagInterrupted - Log flag, cast was interrupted
+const LogFlagInterrupted = 9
+
 // LogMsgIDCharacterWorldName - Log message ID for message with character world name
 const LogMsgIDCharacterWorldName = 0x102b
 
@@ -152,10 +252,23 @@ var LogMsgIDCountdown = [...]int{0x0039, 0x00b9, 0x0139}
 // logShiftValues
 var logShiftValues = [...]int{0x3E, 0x113, 0x213, 0x313}
 
+// logAddCombatantTextRegex - add combatant sent as text by older plugin versions
+var logAddCombatantTextRegex = regexp.MustCompile(" 03:([A-F0-9]*):Added new combatant ([a-zA-Z0-9'\\- ]*)\\.")
+
+// logOwnerTextRegex - owner of combatant in add combatant text
+var logOwnerTextRegex = regexp.MustCompile("Owner: ([A-F0-9]+)")
+
+// logEffectTextRegex - gain/lose effect sent as text by older plugin versions
+var logEffectTextRegex = regexp.MustCompile(" (?:1A|1E):([a-zA-Z0-9'\\- ]*) (?:gains|loses) the effect of (.*) from ([a-zA-Z0-9'\\- ]*?)(?: for ([0-9.]*) Seconds)?\\.")
+
+// logTickTextRegex - dot/hot tick sent as text by older plugin versions
+var logTickTextRegex = regexp.MustCompile(" 18:(DoT|HoT) Tick on ([a-zA-Z0-9'\\- ]*) for ([0-9]+) damage\\.")
+
 // ParsedLogLine - Data retrieved by parsing a log line
 type ParsedLogLine struct {
 	Type              int
 	GameLogType       int
+	Message           string
 	Raw               string
 	AttackerID        int
 	AttackerName      string
@@ -169,6 +282,12 @@ type ParsedLogLine struct {
 	AttackerMaxHP     int
 	TargetCurrentHP   int
 	TargetMaxHP       int
+	AttackerOwnerID   int
+	AttackerOwnerName string
+	AttackerPosition  *data.Position
+	TargetPosition    *data.Position
+	Duration          time.Duration
+	Stacks            int
 	Time              time.Time
 }
 
@@ -188,48 +307,311 @@ func hexToInt(hexString string) (int, error) {
 		return 0, nil
 	}
 	output, err := strconv.ParseInt(hexString, 16, 64)
-	if err != nil {
-		_, fn, line, _ := runtime.Caller(1)
-		log.Println(hexString, fn, line)
-	}
 	return int(output), err
 }
 
-// ParseLogLine - Parse log line in to data structure
+// parsePosition - parse x, y, z and heading fields starting at given field
+func parsePosition(fields []string, start int) *data.Position {
+	if len(fields) < start+4 {
+		return nil
+	}
+	var values [4]float32
+	for index := range values {
+		value, err := strconv.ParseFloat(fields[start+index], 32)
+		if err != nil {
+			return nil
+		}
+		values[index] = float32(value)
+	}
+	return &data.Position{
+		X:       values[0],
+		Y:       values[1],
+		Z:       values[2],
+		Heading: values[3],
+	}
+}
+
+// splitColonFields - split colon delimited fields, fields reference the
+// original string so nothing is copied. A colon with a space afterwards is
+// part of a name rather than a delimiter...probably. Examples... Kaeshi:
+// Higanbana, Hissatsu: Guren
+func splitColonFields(logLineString string) []string {
+	count := 1
+	for index := 0; index < len(logLineString); index++ {
+		if logLineString[index] == ':' && (index+1 >= len(logLineString) || logLineString[index+1] != ' ') {
+			count++
+		}
+	}
+	fields := make([]string, 0, count)
+	start := 0
+	for index := 0; index < len(logLineString); index++ {
+		if logLineString[index] == ':' && (index+1 >= len(logLineString) || logLineString[index+1] != ' ') {
+			fields = append(fields, logLineString[start:index])
+			start = index + 1
+		}
+	}
+	return append(fields, logLineString[start:])
+}
+
+// stripFlagField - remove fields from the flag field onward, used for log
+// types where that field is something other than flags
+func stripFlagField(fields []string) []string {
+	if len(fields) > LogFieldFlags {
+		return fields[:LogFieldFlags]
+	}
+	return fields
+}
+
+// LogLineParser - converts raw log lines in to ParsedLogLine
+type LogLineParser interface {
+	Parse(logLine data.LogLine) (ParsedLogLine, error)
+	Name() string
+}
+
+// ColonLogLineParser - parser for colon delimited '[hh:mm:ss.fff] 15:...' log lines sent by older plugin versions
+type ColonLogLineParser struct {
+	Language string
+}
+
+// PipeLogLineParser - parser for pipe delimited '21|2021-01-01T00:00:00.0000000-00:00|...' network log lines sent by newer plugin versions
+type PipeLogLineParser struct {
+	Language string
+}
+
+// isPipeLogLine - check if raw log line is in the pipe delimited network format
+func isPipeLogLine(logLineString string) bool {
+	index := strings.IndexByte(logLineString, '|')
+	if index < 1 || index > 3 {
+		return false
+	}
+	for _, char := range logLineString[:index] {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// DetectLogLineParser - get the parser that can handle the given raw log line,
+// language is the game client language, patterns for all languages are tried when empty
+func DetectLogLineParser(logLineString string, language string) LogLineParser {
+	if isPipeLogLine(logLineString) {
+		return PipeLogLineParser{Language: language}
+	}
+	return ColonLogLineParser{Language: language}
+}
+
+// ParseLogLine - Parse log line in to data structure, format is detected from the line itself
 func ParseLogLine(logLine data.LogLine) (ParsedLogLine, error) {
+	// avoid boxing the parser in an interface, this is called for every log line
+	if isPipeLogLine(logLine.LogLine) {
+		return PipeLogLineParser{}.Parse(logLine)
+	}
+	return ColonLogLineParser{}.Parse(logLine)
+}
+
+// Name - name of log line format
+func (p ColonLogLineParser) Name() string {
+	return "colon"
+}
+
+// Parse - Parse colon delimited log line in to data structure
+func (p ColonLogLineParser) Parse(logLine data.LogLine) (ParsedLogLine, error) {
+	data, err := p.parse(logLine)
+	return data, wrapParseError(data.Type, err)
+}
+
+// parse - Parse colon delimited log line, errors from text log lines are returned unwrapped
+func (p ColonLogLineParser) parse(logLine data.LogLine) (ParsedLogLine, error) {
 	logLineString := logLine.LogLine
 	if len(logLineString) <= 17 {
-		return ParsedLogLine{}, fmt.Errorf("tried to parse log line with too few characters")
+		return ParsedLogLine{}, &ParseError{Kind: ParseErrorTooShort, LogType: -1, Field: -1}
 	}
 	// get field type
 	logLineType, err := hexToInt(logLineString[15:17])
 	if err != nil {
-		log.Print(logLineString)
-		return ParsedLogLine{}, err
+		return ParsedLogLine{}, &ParseError{Kind: ParseErrorInvalidType, LogType: -1, Field: LogFieldType, Value: logLineString[15:17], Err: err}
 	}
-	// semi colon with space afterwards is ability name instead of delimiter
-	// probably........... examples... Kaeshi: Higanbana, Hissatsu: Guren
-	logLineString = strings.Replace(logLineString, ": ", "####", -1)
 	// split fields
-	fields := strings.Split(logLineString[15:], ":")
+	fields := splitColonFields(logLineString[15:])
 	// create data object
 	data := ParsedLogLine{
 		Type: int(logLineType),
-		Raw:  strings.Replace(logLineString, "####", ": ", -1),
+		Raw:  logLineString,
 		Time: logLine.Time,
 	}
-	// parse remaining
+	// older plugin versions send some log types as text, in the game client's language
+	languages := GetLogLanguages(p.Language)
+	message := data.Raw[18:]
 	switch logLineType {
+	case LogTypeDefeat:
+		{
+			match := matchLogLanguage(languages, func(l *LogLanguage) *regexp.Regexp { return l.Defeat }, message)
+			if match == nil {
+				break
+			}
+			data.AttackerName = match["source"]
+			data.TargetName = match["target"]
+			return data, nil
+		}
+	case LogTypeZoneChange:
+		{
+			match := matchLogLanguage(languages, func(l *LogLanguage) *regexp.Regexp { return l.ZoneChange }, message)
+			if match == nil {
+				break
+			}
+			// special case, target name is zone name
+			data.TargetName = match["zone"]
+			return data, nil
+		}
+	case LogTypeAddCombatant:
+		{
+			if !strings.Contains(logLineString, "Added new combatant") {
+				break
+			}
+			// special case, target is the new combatant, attacker is its owner
+			// owner only included when known
+			match := logAddCombatantTextRegex.FindStringSubmatch(logLineString)
+			if len(match) < 3 {
+				return data, nil
+			}
+			targetID, err := hexToInt(match[1])
+			if err != nil {
+				return data, err
+			}
+			data.TargetID = targetID
+			data.TargetName = match[2]
+			match = logOwnerTextRegex.FindStringSubmatch(logLineString)
+			if len(match) >= 2 {
+				data.AttackerID, err = hexToInt(match[1])
+				if err != nil {
+					return data, err
+				}
+			}
+			return data, nil
+		}
+	case LogTypeRemoveCombatant:
+		{
+			match := matchLogLanguage(languages, func(l *LogLanguage) *regexp.Regexp { return l.RemoveCombatant }, message)
+			if match == nil {
+				// newer plugin versions send fields instead of text
+				if len(fields) <= LogFieldAddCombatantOwnerID {
+					return data, nil
+				}
+				break
+			}
+			targetID, err := hexToInt(match["id"])
+			if err != nil {
+				return data, err
+			}
+			data.TargetID = targetID
+			data.TargetName = match["name"]
+			maxHP, err := strconv.Atoi(match["maxhp"])
+			if err != nil {
+				return data, err
+			}
+			data.TargetMaxHP = int(maxHP)
+			return data, nil
+		}
+	case LogTypeGainEffect, LogTypeLoseEffect:
+		{
+			if len(fields) > LogFieldEffectTargetName {
+				break
+			}
+			// special case, attacker is effect source, ability is the effect
+			match := logEffectTextRegex.FindStringSubmatch(logLineString)
+			if len(match) < 5 {
+				return data, nil
+			}
+			data.TargetName = match[1]
+			data.AbilityName = match[2]
+			data.AttackerName = match[3]
+			if match[4] != "" {
+				duration, err := strconv.ParseFloat(match[4], 64)
+				if err != nil {
+					return data, err
+				}
+				data.Duration = time.Duration(duration * float64(time.Second))
+			}
+			return data, nil
+		}
+	case LogTypeDot:
+		{
+			if len(fields) > LogFieldTickDamage {
+				break
+			}
+			// special case, target and amount only, source is unknown
+			match := logTickTextRegex.FindStringSubmatch(logLineString)
+			if len(match) < 4 {
+				return data, nil
+			}
+			data.TargetName = match[2]
+			damage, err := strconv.Atoi(match[3])
+			if err != nil {
+				return data, err
+			}
+			data.Damage = damage
+			if match[1] == "HoT" {
+				data.Flags = append(data.Flags, LogFlagHeal)
+			} else {
+				data.Flags = append(data.Flags, LogFlagDamage)
+			}
+			return data, nil
+		}
+	}
+	return parseLogLineFields(data, fields, languages)
+}
+
+// Name - name of log line format
+func (p PipeLogLineParser) Name() string {
+	return "pipe"
+}
+
+// Parse - Parse pipe delimited network log line in to data structure
+func (p PipeLogLineParser) Parse(logLine data.LogLine) (ParsedLogLine, error) {
+	// fields are type, timestamp, type specific fields and finally a hash
+	fields := strings.Split(logLine.LogLine, "|")
+	if len(fields) < 3 {
+		return ParsedLogLine{}, &ParseError{Kind: ParseErrorTooShort, LogType: -1, Field: -1}
+	}
+	// network log types are decimal
+	logLineType, err := strconv.Atoi(fields[LogFieldType])
+	if err != nil {
+		return ParsedLogLine{}, &ParseError{Kind: ParseErrorInvalidType, LogType: -1, Field: LogFieldType, Value: fields[LogFieldType], Err: err}
+	}
+	// prefer the time act reported, fall back to the full timestamp in the line
+	logTime := logLine.Time
+	if logTime.IsZero() {
+		logTime, err = time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return ParsedLogLine{}, invalidFieldError(logLineType, fields, 1, err)
+		}
+	}
+	// drop timestamp and hash, remaining fields line up with the colon format
+	fields = append(fields[:1], fields[2:len(fields)-1]...)
+	data := ParsedLogLine{
+		Type: logLineType,
+		Raw:  logLine.LogLine,
+		Time: logTime,
+	}
+	data, err = parseLogLineFields(data, fields, GetLogLanguages(p.Language))
+	return data, wrapParseError(data.Type, err)
+}
+
+// parseLogLineFields - parse fields shared by all log line formats, fields
+// are expected to start with the log type
+func parseLogLineFields(data ParsedLogLine, fields []string, languages []*LogLanguage) (ParsedLogLine, error) {
+	switch data.Type {
 	case LogTypeSingleTarget, LogTypeAoe:
 		{
 			// ensure there are enough fields
 			if len(fields) < 24 {
-				return ParsedLogLine{}, fmt.Errorf("not enough fields when parsing ability")
+				return ParsedLogLine{}, newMissingFieldsError(data.Type, fields)
 			}
 			// Shift damage and flags forward for mysterious spurious :3E:0:.
 			// Plenary Indulgence also appears to prepend confession stacks.
 			// UNKNOWN: Can these two happen at the same time?
-			flagsInt, err := hexToInt(fields[LogFieldFlags])
+			flagsInt, err := hexField(data.Type, fields, LogFieldFlags)
 			if err != nil {
 				return data, err
 			}
@@ -247,7 +629,7 @@ func ParseLogLine(logLine data.LogLine) (ParsedLogLine, error) {
 				// Get the left four bytes as damage.
 				damage, err = hexToInt(fields[LogFieldDamage][0:4])
 				if err != nil {
-					return data, err
+					return data, invalidFieldError(data.Type, fields, LogFieldDamage, err)
 				}
 			}
 			// Check for third byte == 0x40.
@@ -255,13 +637,13 @@ func ParseLogLine(logLine data.LogLine) (ParsedLogLine, error) {
 				// Wrap in the 4th byte as extra damage.  See notes above.
 				rightDamage, err := hexToInt(fields[LogFieldDamage][damageFieldLength-2 : damageFieldLength])
 				if err != nil {
-					return data, err
+					return data, invalidFieldError(data.Type, fields, LogFieldDamage, err)
 				}
 				damage = damage - rightDamage + (rightDamage << 16)
 			}
 			data.Damage = int(damage)
 			// attacker id
-			attackerID, err := hexToInt(fields[LogFieldAttackerID])
+			attackerID, err := hexField(data.Type, fields, LogFieldAttackerID)
 			if err != nil {
 				return data, err
 			}
@@ -269,26 +651,24 @@ func ParseLogLine(logLine data.LogLine) (ParsedLogLine, error) {
 			// attacker name
 			data.AttackerName = fields[LogFieldAttackerName]
 			// ability id
-			abilityID, err := hexToInt(fields[LogFieldAbilityID])
+			abilityID, err := hexField(data.Type, fields, LogFieldAbilityID)
 			if err != nil {
 				return data, err
 			}
 			data.AbilityID = int(abilityID)
 			// ability name
 			data.AbilityName = fields[LogFieldAbilityName]
-			// restore ability name with semicolon
-			data.AbilityName = strings.Replace(data.AbilityName, "####", ": ", -1)
 			// target id
-			targetID, err := hexToInt(fields[LogFieldTargetID])
+			targetID, err := hexField(data.Type, fields, LogFieldTargetID)
 			if err != nil {
 				return data, err
 			}
 			data.TargetID = int(targetID)
 			// target name
 			data.TargetName = fields[LogFieldTargetName]
-			// target current hp
+			// target current hp (hp values are decimal)
 			if len(fields)-1 >= LogFieldTargetCurrentHP && fields[LogFieldTargetCurrentHP] != "" {
-				targetCurrentHP, err := hexToInt(fields[LogFieldTargetCurrentHP])
+				targetCurrentHP, err := intField(data.Type, fields, LogFieldTargetCurrentHP)
 				if err != nil {
 					return data, err
 				}
@@ -296,7 +676,7 @@ func ParseLogLine(logLine data.LogLine) (ParsedLogLine, error) {
 			}
 			// target max hp
 			if len(fields)-1 >= LogFieldTargetMaxHP && fields[LogFieldTargetMaxHP] != "" {
-				targetMaxHP, err := hexToInt(fields[LogFieldTargetMaxHP])
+				targetMaxHP, err := intField(data.Type, fields, LogFieldTargetMaxHP)
 				if err != nil {
 					return data, err
 				}
@@ -304,72 +684,236 @@ func ParseLogLine(logLine data.LogLine) (ParsedLogLine, error) {
 			}
 			// attacker current hp
 			if len(fields)-1 >= LogFieldAttackerCurrentHP && fields[LogFieldAttackerCurrentHP] != "" {
-				attackerCurrentHP, err := hexToInt(fields[LogFieldAttackerCurrentHP])
+				attackerCurrentHP, err := intField(data.Type, fields, LogFieldAttackerCurrentHP)
 				if err != nil {
 					return data, err
 				}
 				data.AttackerCurrentHP = int(attackerCurrentHP)
 			}
-			// target max hp
+			// attacker max hp
 			if len(fields)-1 >= LogFieldAttackerMaxHP && fields[LogFieldAttackerMaxHP] != "" {
-				attackerMaxHP, err := hexToInt(fields[LogFieldAttackerMaxHP])
+				attackerMaxHP, err := intField(data.Type, fields, LogFieldAttackerMaxHP)
 				if err != nil {
 					return data, err
 				}
 				data.AttackerMaxHP = int(attackerMaxHP)
 			}
+			// positions
+			data.TargetPosition = parsePosition(fields, LogFieldTargetPosition)
+			data.AttackerPosition = parsePosition(fields, LogFieldAttackerPosition)
 			break
 		}
 	case LogTypeDefeat:
 		{
-			re, err := regexp.Compile(" 19:([a-zA-Z0-9'\\- ]*) was defeated by ([a-zA-Z0-9'\\- ]*)")
+			fields = stripFlagField(fields)
+			if len(fields) <= LogFieldDefeatSourceName {
+				break
+			}
+			// target was defeated by attacker
+			targetID, err := hexField(data.Type, fields, LogFieldDefeatTargetID)
 			if err != nil {
 				return data, err
 			}
-			match := re.FindStringSubmatch(logLineString)
-			if len(match) < 3 {
-				break
+			data.TargetID = targetID
+			data.TargetName = fields[LogFieldDefeatTargetName]
+			attackerID, err := hexField(data.Type, fields, LogFieldDefeatSourceID)
+			if err != nil {
+				return data, err
 			}
-			data.AttackerName = match[2]
-			data.TargetName = match[1]
+			data.AttackerID = attackerID
+			data.AttackerName = fields[LogFieldDefeatSourceName]
 			break
 		}
 	case LogTypeZoneChange:
 		{
-			re, err := regexp.Compile(" 01:Changed Zone to (.*)\\.")
+			fields = stripFlagField(fields)
+			if len(fields) <= LogFieldZoneName {
+				break
+			}
+			// special case, target name is zone name
+			data.TargetName = fields[LogFieldZoneName]
+			break
+		}
+	case LogTypeAddCombatant, LogTypeRemoveCombatant:
+		{
+			// special case, target is the new combatant, attacker is its owner
+			if len(fields) <= LogFieldAddCombatantOwnerID {
+				return ParsedLogLine{}, newMissingFieldsError(data.Type, fields)
+			}
+			targetID, err := hexField(data.Type, fields, LogFieldAddCombatantID)
 			if err != nil {
 				return data, err
 			}
-			match := re.FindStringSubmatch(logLineString)
-			if len(match) < 2 {
-				break
+			data.TargetID = targetID
+			data.TargetName = fields[LogFieldAddCombatantName]
+			if fields[LogFieldAddCombatantOwnerID] != "" {
+				data.AttackerID, err = hexField(data.Type, fields, LogFieldAddCombatantOwnerID)
+				if err != nil {
+					return data, err
+				}
 			}
-			// special case, target name is zone name
-			data.TargetName = strings.Replace(match[1], "####", ": ", -1)
+			if len(fields)-1 >= LogFieldAddCombatantMaxHP && fields[LogFieldAddCombatantMaxHP] != "" {
+				data.TargetMaxHP, err = intField(data.Type, fields, LogFieldAddCombatantMaxHP)
+				if err != nil {
+					return data, err
+				}
+			}
+			fields = stripFlagField(fields)
 			break
 		}
-	case LogTypeRemoveCombatant:
+	case LogTypeGainEffect, LogTypeLoseEffect:
 		{
-			// remember...we replace ': ' with '####'
-			re, err := regexp.Compile(" 04:([A-F0-9]*):Removing combatant ([a-zA-Z0-9'\\- ]*)\\.  Max HP####([0-9]*)\\.")
+			// special case, attacker is effect source, ability is the effect
+			if len(fields) <= LogFieldEffectTargetName {
+				return ParsedLogLine{}, newMissingFieldsError(data.Type, fields)
+			}
+			// effect id
+			effectID, err := hexField(data.Type, fields, LogFieldEffectID)
 			if err != nil {
 				return data, err
 			}
-			match := re.FindStringSubmatch(logLineString)
-			if len(match) < 4 {
+			data.AbilityID = effectID
+			// effect name
+			data.AbilityName = fields[LogFieldEffectName]
+			// duration
+			if fields[LogFieldEffectDuration] != "" {
+				duration, err := floatField(data.Type, fields, LogFieldEffectDuration)
+				if err != nil {
+					return data, err
+				}
+				data.Duration = time.Duration(duration * float64(time.Second))
+			}
+			// source
+			sourceID, err := hexField(data.Type, fields, LogFieldEffectSourceID)
+			if err != nil {
+				return data, err
+			}
+			data.AttackerID = sourceID
+			data.AttackerName = fields[LogFieldEffectSourceName]
+			// target
+			targetID, err := hexField(data.Type, fields, LogFieldEffectTargetID)
+			if err != nil {
+				return data, err
+			}
+			data.TargetID = targetID
+			data.TargetName = fields[LogFieldEffectTargetName]
+			// stacks
+			if len(fields)-1 >= LogFieldEffectStacks {
+				stacks, err := hexField(data.Type, fields, LogFieldEffectStacks)
+				if err != nil {
+					return data, err
+				}
+				data.Stacks = stacks
+			}
+			fields = stripFlagField(fields)
+			break
+		}
+	case LogTypeCastStart, LogTypeCastCancel:
+		{
+			// ensure there are enough fields
+			if len(fields) <= LogFieldAbilityName {
+				return ParsedLogLine{}, newMissingFieldsError(data.Type, fields)
+			}
+			// caster
+			attackerID, err := hexField(data.Type, fields, LogFieldAttackerID)
+			if err != nil {
+				return data, err
+			}
+			data.AttackerID = attackerID
+			data.AttackerName = fields[LogFieldAttackerName]
+			// ability
+			abilityID, err := hexField(data.Type, fields, LogFieldAbilityID)
+			if err != nil {
+				return data, err
+			}
+			data.AbilityID = abilityID
+			data.AbilityName = fields[LogFieldAbilityName]
+			if data.Type == LogTypeCastCancel {
+				if len(fields)-1 >= LogFieldCancelReason && fields[LogFieldCancelReason] == "Interrupted" {
+					data.Flags = append(data.Flags, LogFlagInterrupted)
+				}
+				fields = stripFlagField(fields)
 				break
 			}
-			targetID, err := hexToInt(match[1])
+			// target
+			if len(fields)-1 >= LogFieldTargetName {
+				targetID, err := hexField(data.Type, fields, LogFieldTargetID)
+				if err != nil {
+					return data, err
+				}
+				data.TargetID = targetID
+				data.TargetName = fields[LogFieldTargetName]
+			}
+			// cast time
+			if len(fields)-1 >= LogFieldCastDuration && fields[LogFieldCastDuration] != "" {
+				duration, err := floatField(data.Type, fields, LogFieldCastDuration)
+				if err != nil {
+					return data, err
+				}
+				data.Duration = time.Duration(duration * float64(time.Second))
+			}
+			fields = stripFlagField(fields)
+			break
+		}
+	case LogTypeDot:
+		{
+			// ensure there are enough fields
+			if len(fields) <= LogFieldTickDamage {
+				return ParsedLogLine{}, newMissingFieldsError(data.Type, fields)
+			}
+			// target
+			targetID, err := hexField(data.Type, fields, LogFieldTickTargetID)
 			if err != nil {
 				return data, err
 			}
 			data.TargetID = targetID
-			data.TargetName = match[2]
-			maxHP, err := hexToInt(match[3])
+			data.TargetName = fields[LogFieldTickTargetName]
+			// special case, ability is the effect that ticked
+			effectID, err := hexField(data.Type, fields, LogFieldTickEffectID)
 			if err != nil {
 				return data, err
 			}
-			data.TargetMaxHP = int(maxHP)
+			data.AbilityID = effectID
+			// damage/heal amount
+			damage, err := hexField(data.Type, fields, LogFieldTickDamage)
+			if err != nil {
+				return data, err
+			}
+			data.Damage = damage
+			// target hp
+			if len(fields)-1 >= LogFieldTickTargetMaxHP {
+				data.TargetCurrentHP, err = intField(data.Type, fields, LogFieldTickTargetCurrentHP)
+				if err != nil {
+					return data, err
+				}
+				data.TargetMaxHP, err = intField(data.Type, fields, LogFieldTickTargetMaxHP)
+				if err != nil {
+					return data, err
+				}
+			}
+			// source, when not provided the effect timeline is used to find it
+			if len(fields)-1 >= LogFieldTickSourceName {
+				sourceID, err := hexField(data.Type, fields, LogFieldTickSourceID)
+				if err != nil {
+					return data, err
+				}
+				data.AttackerID = sourceID
+				data.AttackerName = fields[LogFieldTickSourceName]
+			}
+			// flags
+			switch fields[LogFieldTickType] {
+			case "DoT":
+				{
+					data.Flags = append(data.Flags, LogFlagDamage)
+					break
+				}
+			case "HoT":
+				{
+					data.Flags = append(data.Flags, LogFlagHeal)
+					break
+				}
+			}
+			fields = stripFlagField(fields)
 			break
 		}
 	case LogTypeHPPercent:
@@ -384,11 +928,13 @@ func ParseLogLine(logLine data.LogLine) (ParsedLogLine, error) {
 				break
 			}
 			// get Log message ID
-			gameLogType, err := hexToInt(fields[1])
+			gameLogType, err := hexField(data.Type, fields, 1)
 			if err != nil {
 				return data, err
 			}
 			data.GameLogType = int(gameLogType)
+			// message text is always the last field, speaker before it may be omitted
+			data.Message = fields[len(fields)-1]
 			// player chat message, ignore
 			if data.GameLogType <= LogMsgChatID && data.GameLogType != LogMsgIDEcho {
 				data.Raw = ""
@@ -398,19 +944,14 @@ func ParseLogLine(logLine data.LogLine) (ParsedLogLine, error) {
 			// try to strip out world name from message
 			case LogMsgIDCharacterWorldName:
 				{
-					re, err := regexp.Compile("102b:([a-zA-Z'\\-]*) ([A-Z'])([a-z'\\-]*)([A-Z])([a-z]*)")
-					if err != nil {
-						return data, err
-					}
-					match := re.FindStringSubmatch(logLineString)
-					if len(match) < 6 {
+					message := strings.TrimSpace(strings.Join(fields[2:], " "))
+					match := matchLogLanguage(languages, func(l *LogLanguage) *regexp.Regexp { return l.WorldName }, message)
+					if match == nil {
 						break
 					}
-					attackerName := fmt.Sprintf("%s %s%s", match[1], match[2], match[3])
-					worldName := fmt.Sprintf("%s%s", match[4], match[5])
-					data.AttackerName = attackerName
+					data.AttackerName = fmt.Sprintf("%s %s", match["first"], match["last"])
 					// special case, target name is world name
-					data.TargetName = worldName
+					data.TargetName = match["world"]
 					break
 				}
 			}
@@ -0,0 +1,174 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"../app"
+	"../data"
+)
+
+// LogLineRange - range of log lines to read from an encounter log, by line offset and/or time
+type LogLineRange struct {
+	Offset int
+	Limit  int
+	Start  time.Time
+	End    time.Time
+}
+
+// LogLineRangeResult - log lines read for a log line range
+type LogLineRangeResult struct {
+	Offset   int
+	Total    int
+	LogLines []data.LogLine
+}
+
+// Bounds - get first and last (exclusive) line offsets covered by range
+func (r LogLineRange) Bounds(index []LogLineIndexEntry) (int, int) {
+	start := r.Offset
+	if start < 0 {
+		start = 0
+	}
+	end := len(index)
+	if !r.Start.IsZero() {
+		timeStart := sort.Search(len(index), func(i int) bool {
+			return !index[i].Time.Before(r.Start)
+		})
+		if timeStart > start {
+			start = timeStart
+		}
+	}
+	if !r.End.IsZero() {
+		timeEnd := sort.Search(len(index), func(i int) bool {
+			return index[i].Time.After(r.End)
+		})
+		if timeEnd < end {
+			end = timeEnd
+		}
+	}
+	limit := r.Limit
+	if limit <= 0 || limit > app.LogLineRangeLimit {
+		limit = app.LogLineRangeLimit
+	}
+	if end > start+limit {
+		end = start + limit
+	}
+	if start > end {
+		start = end
+	}
+	return start, end
+}
+
+// ReadLogLineRange - read range of log lines from permanent log file
+func ReadLogLineRange(storage BlobStorage, encounterUID string, rng LogLineRange) (LogLineRangeResult, error) {
+	idxf, err := storage.Open(GetLogIndexFileKey(encounterUID), 0)
+	if err == ErrBlobNotExist {
+		// log files written before the index existed have to be read in full
+		f, err := storage.Open(GetLogFileKey(encounterUID), 0)
+		if err != nil {
+			return LogLineRangeResult{}, err
+		}
+		defer f.Close()
+		return readLogLineRangeWithoutIndex(f, rng)
+	} else if err != nil {
+		return LogLineRangeResult{}, err
+	}
+	defer idxf.Close()
+	index, err := ReadLogLineIndex(bufio.NewReader(idxf))
+	if err != nil {
+		return LogLineRangeResult{}, err
+	}
+	start, end := rng.Bounds(index)
+	result := LogLineRangeResult{
+		Offset:   start,
+		Total:    len(index),
+		LogLines: make([]data.LogLine, 0),
+	}
+	if start >= end {
+		return result, nil
+	}
+	// find first log line in block so that the rest of the block can be skipped over
+	blockStart := start
+	for blockStart > 0 && index[blockStart-1].BlockOffset == index[start].BlockOffset {
+		blockStart--
+	}
+	f, err := storage.Open(GetLogFileKey(encounterUID), index[start].BlockOffset)
+	if err != nil {
+		return result, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return result, err
+	}
+	defer gz.Close()
+	_, err = io.CopyN(ioutil.Discard, gz, index[start].Offset-index[blockStart].Offset)
+	if err != nil {
+		return result, err
+	}
+	result.LogLines, err = readLogLineRecords(&LogLineReader{r: bufio.NewReader(gz)}, end-start)
+	return result, err
+}
+
+// readLogLineRangeWithoutIndex - read range of log lines from log file that has no index
+func readLogLineRangeWithoutIndex(f io.Reader, rng LogLineRange) (LogLineRangeResult, error) {
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return LogLineRangeResult{}, err
+	}
+	defer gz.Close()
+	lr, err := NewLogLineReader(gz)
+	if err != nil {
+		return LogLineRangeResult{}, err
+	}
+	logLines, err := readLogLineRecords(lr, -1)
+	if err != nil {
+		return LogLineRangeResult{}, err
+	}
+	index := make([]LogLineIndexEntry, len(logLines))
+	for i := range logLines {
+		index[i].Time = logLines[i].Time
+	}
+	start, end := rng.Bounds(index)
+	return LogLineRangeResult{
+		Offset:   start,
+		Total:    len(logLines),
+		LogLines: logLines[start:end],
+	}, nil
+}
+
+// readLogLineRecords - read up to count log lines, or all remaining log lines if count is negative
+func readLogLineRecords(lr *LogLineReader, count int) ([]data.LogLine, error) {
+	output := make([]data.LogLine, 0)
+	for count < 0 || len(output) < count {
+		logLine, err := lr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return output, err
+		}
+		output = append(output, logLine)
+	}
+	return output, nil
+}
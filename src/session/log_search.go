@@ -0,0 +1,166 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"compress/gzip"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"../app"
+)
+
+// LogSearchQuery - filters for searching stored encounter logs, empty filters match everything
+type LogSearchQuery struct {
+	Types   []int
+	Actor   string
+	Target  string
+	Ability string
+	Regex   *regexp.Regexp
+	Offset  int
+	Limit   int
+}
+
+// LogSearchMatch - log line that matched a log search
+type LogSearchMatch struct {
+	EncounterUID string    `json:"encounter_uid"`
+	Offset       int       `json:"offset"`
+	Time         time.Time `json:"time"`
+	LogLine      string    `json:"log_line"`
+}
+
+// LogSearchResult - page of log search matches
+type LogSearchResult struct {
+	Offset  int              `json:"offset"`
+	HasMore bool             `json:"has_more"`
+	Matches []LogSearchMatch `json:"matches"`
+}
+
+// MatchRaw - check if raw log line could match query, used to skip parsing
+func (q *LogSearchQuery) MatchRaw(raw string) bool {
+	return q.Regex == nil || q.Regex.MatchString(raw)
+}
+
+// Match - check if parsed log line matches query
+func (q *LogSearchQuery) Match(l *ParsedLogLine) bool {
+	if len(q.Types) > 0 {
+		hasType := false
+		for _, logType := range q.Types {
+			if l.Type == logType {
+				hasType = true
+				break
+			}
+		}
+		if !hasType {
+			return false
+		}
+	}
+	if q.Actor != "" && !strings.EqualFold(q.Actor, l.AttackerName) {
+		return false
+	}
+	if q.Target != "" && !strings.EqualFold(q.Target, l.TargetName) {
+		return false
+	}
+	if q.Ability != "" && !strings.EqualFold(q.Ability, l.AbilityName) {
+		// ability can also be given as hex id
+		abilityID, err := strconv.ParseInt(strings.TrimPrefix(strings.ToLower(q.Ability), "0x"), 16, 64)
+		if err != nil || l.AbilityID == 0 || l.AbilityID != int(abilityID) {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchLogs - search permanent log files of given encounters, in the order given
+func SearchLogs(storage BlobStorage, encounterUIDs []string, query LogSearchQuery) (LogSearchResult, error) {
+	if query.Offset < 0 {
+		query.Offset = 0
+	}
+	if query.Limit <= 0 || query.Limit > app.LogSearchLimit {
+		query.Limit = app.LogSearchLimit
+	}
+	result := LogSearchResult{
+		Offset:  query.Offset,
+		Matches: make([]LogSearchMatch, 0),
+	}
+	skip := query.Offset
+	for _, encounterUID := range encounterUIDs {
+		done, err := searchLogFile(storage, encounterUID, &query, &result, &skip)
+		if err != nil {
+			return result, err
+		}
+		if done {
+			break
+		}
+	}
+	return result, nil
+}
+
+// searchLogFile - add log lines matching query in encounter log file to result, returns true once the page is full
+func searchLogFile(storage BlobStorage, encounterUID string, query *LogSearchQuery, result *LogSearchResult, skip *int) (bool, error) {
+	f, err := storage.Open(GetLogFileKey(encounterUID), 0)
+	if err == ErrBlobNotExist {
+		// log file has been cleaned up
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return false, err
+	}
+	defer gz.Close()
+	lr, err := NewLogLineReader(gz)
+	if err != nil {
+		return false, err
+	}
+	for offset := 0; ; offset++ {
+		logLine, err := lr.Read()
+		if err == io.EOF {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if !query.MatchRaw(logLine.LogLine) {
+			continue
+		}
+		pLogLine, err := ParseLogLine(logLine)
+		if err != nil || !query.Match(&pLogLine) {
+			continue
+		}
+		if *skip > 0 {
+			*skip--
+			continue
+		}
+		// one extra match is looked for to know if there is another page
+		if len(result.Matches) >= query.Limit {
+			result.HasMore = true
+			return true, nil
+		}
+		result.Matches = append(result.Matches, LogSearchMatch{
+			EncounterUID: encounterUID,
+			Offset:       offset,
+			Time:         logLine.Time,
+			LogLine:      logLine.LogLine,
+		})
+	}
+}
@@ -0,0 +1,259 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"../data"
+)
+
+// schemaModels - models stored in database once all migrations are applied
+func schemaModels() []interface{} {
+	return []interface{}{
+		&data.User{},
+		&data.RetentionGroup{},
+		&data.Encounter{},
+		&data.Combatant{},
+		&data.Player{},
+		&data.Cast{},
+		&data.DeathRecap{},
+		&data.DeathRecapEvent{},
+		&data.AbilityStat{},
+		&data.TimeSeries{},
+		&data.Contribution{},
+		&data.Enemy{},
+		&data.EnemyDamageSource{},
+	}
+}
+
+// checkSchema - check migrations created a table and column for every model field
+func checkSchema(t *testing.T, d *DatabaseHandler) {
+	for _, model := range schemaModels() {
+		scope := d.conn.NewScope(model)
+		if !d.conn.HasTable(model) {
+			t.Errorf("Expected table %s to exist.", scope.TableName())
+			continue
+		}
+		for _, field := range scope.GetModelStruct().StructFields {
+			if field.IsIgnored || !field.IsNormal || field.Struct.Type.Kind() == reflect.Interface {
+				continue
+			}
+			if !d.conn.Dialect().HasColumn(scope.TableName(), field.DBName) {
+				t.Errorf("Expected column %s.%s to exist.", scope.TableName(), field.DBName)
+			}
+		}
+	}
+	for _, index := range []struct {
+		table string
+		name  string
+	}{
+		{"combatants", "idx_combatants_encounter_uid"},
+		{"combatants", "idx_combatants_time"},
+		{"encounters", "idx_encounters_start_time"},
+		{"encounters", "uix_encounters_uid"},
+	} {
+		if !d.conn.Dialect().HasIndex(index.table, index.name) {
+			t.Errorf("Expected index %s to exist.", index.name)
+		}
+	}
+}
+
+func TestMigrateEmpty(t *testing.T) {
+	runDatabaseTest(t, testMigrateEmpty)
+}
+
+func testMigrateEmpty(t *testing.T, dialect string) {
+	savePath, err := ioutil.TempDir("", "fflp-migrate")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.RemoveAll(savePath)
+	d, closeDatabase := openTestDatabaseHandler(t, dialect, savePath)
+	defer closeDatabase()
+	// up
+	err = d.Migrate(LatestSchemaVersion())
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	version, err := d.SchemaVersion()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("Expected schema version %d, got %d.", LatestSchemaVersion(), version)
+	}
+	checkSchema(t, &d)
+	user := data.NewUser()
+	err = d.StoreUser(&user)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	// down
+	err = d.Migrate(0)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	for _, model := range schemaModels() {
+		if d.conn.HasTable(model) {
+			t.Errorf("Expected table for %T to be dropped.", model)
+		}
+	}
+	applied, err := d.FetchAppliedMigrations()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Expected no applied migrations, got %d.", len(applied))
+	}
+	// up again, one step at a time
+	for _, migration := range Migrations {
+		err = d.Migrate(migration.Version)
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+	}
+	checkSchema(t, &d)
+	if err = d.Migrate(LatestSchemaVersion() + 1); err == nil {
+		t.Errorf("Expected error migrating to unknown version.")
+	}
+}
+
+// TestMigrateFixture - upgrade sqlite database created by auto migrate before migrations were added
+func TestMigrateFixture(t *testing.T) {
+	savePath, err := ioutil.TempDir("", "fflp-migrate")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.RemoveAll(savePath)
+	d, err := openDatabaseHandler("sqlite3", path.Join(savePath, "test.db"))
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer d.Close()
+	fixture, err := ioutil.ReadFile("testdata/migrations/sqlite_baseline.sql")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	res := d.conn.Exec(string(fixture))
+	if res.Error != nil {
+		t.Fatalf("Error occurred...%s", res.Error)
+	}
+	version, err := d.SchemaVersion()
+	if err != nil || version != 0 {
+		t.Fatalf("Expected fixture to have no migrations applied.")
+	}
+	err = d.Migrate(LatestSchemaVersion())
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	checkSchema(t, &d)
+	// existing data is kept and backfilled
+	tests := []struct {
+		uid        string
+		combatants int
+		rows       int64
+	}{
+		{"BASELINE_E1", 3, 4},
+		{"BASELINE_E2", 1, 2},
+	}
+	checkData := func(backfilled bool) {
+		for _, test := range tests {
+			encounter, err := d.FetchEncounter(test.uid)
+			if err != nil {
+				t.Fatalf("Error occurred...%s", err)
+			}
+			if encounter.CompareHash == "" || encounter.StartTime.IsZero() {
+				t.Errorf("%s: expected encounter values to be kept.", test.uid)
+			}
+			if backfilled && (encounter.Pinned || encounter.StorageRows != test.rows) {
+				t.Errorf("%s: expected unpinned with %d rows, got pinned=%t rows=%d.", test.uid, test.rows, encounter.Pinned, encounter.StorageRows)
+			}
+			combatants, err := d.FetchCombatantsForEncounter(test.uid)
+			if err != nil {
+				t.Fatalf("Error occurred...%s", err)
+			}
+			if len(combatants) != test.combatants || combatants[0].Player.Name == "" {
+				t.Errorf("%s: expected %d combatants with players to be kept.", test.uid, test.combatants)
+			}
+		}
+		user, err := d.FetchUserFromWebKey("baselineweb1")
+		if err != nil || user.FFToolsUID != "fftools1" {
+			t.Errorf("Expected user to be kept.")
+		}
+	}
+	checkData(true)
+	user, err := d.FetchUserFromID(1)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if user.RetentionDays != 0 || user.RetentionGroup != "" || user.QuotaEncounters != 0 || user.QuotaBytes != 0 {
+		t.Errorf("Expected new user columns to default to zero.")
+	}
+	usage, err := d.FetchUserStorageUsage(1)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if usage.Encounters != 1 || usage.Rows != 4 {
+		t.Errorf("Unexpected storage usage %+v.", usage)
+	}
+	// applying again does nothing
+	err = d.Migrate(LatestSchemaVersion())
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	applied, err := d.FetchAppliedMigrations()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(applied) != len(Migrations) {
+		t.Errorf("Expected %d applied migrations, got %d.", len(Migrations), len(applied))
+	}
+	// reverting to the baseline rebuilds tables without the added columns and keeps their data
+	err = d.Migrate(1)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	for _, column := range []struct {
+		table  string
+		column string
+	}{
+		{"combatants", "dot_damage"},
+		{"users", "retention_days"},
+		{"users", "quota_bytes"},
+		{"encounters", "pinned"},
+		{"encounters", "storage_rows"},
+	} {
+		if d.conn.Dialect().HasColumn(column.table, column.column) {
+			t.Errorf("Expected column %s.%s to be dropped.", column.table, column.column)
+		}
+	}
+	if !d.conn.Dialect().HasIndex("encounters", "uix_encounters_uid") || !d.conn.Dialect().HasIndex("users", "idx_users_ff_tools_uid") {
+		t.Errorf("Expected indexes of rebuilt tables to be recreated.")
+	}
+	err = d.Migrate(LatestSchemaVersion())
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	checkSchema(t, &d)
+	checkData(true)
+}
@@ -0,0 +1,407 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migration - numbered database schema change, down reverts up
+// migrations run in a transaction and must only use the connection they are given
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// migrationSQL - migration statements keyed by database dialect
+type migrationSQL map[string][]string
+
+// schemaMigrationsSQL - table recording applied migrations
+var schemaMigrationsSQL = migrationSQL{
+	"sqlite3": {
+		`CREATE TABLE IF NOT EXISTS "schema_migrations" ("version" integer PRIMARY KEY,"name" varchar(128),"applied_at" datetime)`,
+	},
+	"postgres": {
+		`CREATE TABLE IF NOT EXISTS "schema_migrations" ("version" integer PRIMARY KEY,"name" varchar(128),"applied_at" timestamp with time zone)`,
+	},
+}
+
+// sqlite table definitions needed again when a migration that added columns is reverted,
+// sqlite can't drop columns so the table is rebuilt from the definition before the migration
+const (
+	sqliteUsersV1 = `CREATE TABLE IF NOT EXISTS "users" ("id" integer primary key autoincrement,"created" datetime,"accessed" datetime,"upload_key" varchar(32) NOT NULL UNIQUE,"web_key" varchar(32) NOT NULL UNIQUE,"ff_tools_uid" varchar(32) )`
+	sqliteUsersV9 = `CREATE TABLE IF NOT EXISTS "users" ("id" integer primary key autoincrement,"created" datetime,"accessed" datetime,"upload_key" varchar(32) NOT NULL UNIQUE,"web_key" varchar(32) NOT NULL UNIQUE,"ff_tools_uid" varchar(32),` +
+		`"retention_days" integer NOT NULL DEFAULT 0,"retention_group" varchar(64) NOT NULL DEFAULT '')`
+	sqliteUsersIndex   = `CREATE INDEX IF NOT EXISTS idx_users_ff_tools_uid ON "users"(ff_tools_uid)`
+	sqliteEncountersV1 = `CREATE TABLE IF NOT EXISTS "encounters" ("user_id" bigint,"uid" varchar(32) NOT NULL,"act_id" integer,"compare_hash" varchar(32) NOT NULL,"start_time" datetime,"end_time" datetime,"zone" varchar(256),"damage" integer,"active" bool,"end_wait" bool,"success_level" integer )`
+	sqliteEncountersV9 = `CREATE TABLE IF NOT EXISTS "encounters" ("user_id" bigint,"uid" varchar(32) NOT NULL,"act_id" integer,"compare_hash" varchar(32) NOT NULL,"start_time" datetime,"end_time" datetime,"zone" varchar(256),"damage" integer,"active" bool,"end_wait" bool,"success_level" integer,` +
+		`"pinned" boolean NOT NULL DEFAULT false)`
+	sqliteEncountersSearchIndex = `CREATE INDEX IF NOT EXISTS idx_encounter_search ON "encounters"(user_id, start_time, end_time)`
+	sqliteEncountersUIDIndex    = `CREATE UNIQUE INDEX IF NOT EXISTS uix_encounters_uid ON "encounters"("uid")`
+	sqliteContributionsV7       = `CREATE TABLE "contributions" ("id" integer primary key autoincrement,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"combatant_name" varchar(128),"owner_id" integer,"damage" bigint,"damage_received" bigint,"damage_given" bigint,"time" datetime)`
+	sqliteContributionsV12      = `CREATE TABLE "contributions" ("id" integer primary key autoincrement,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"combatant_name" varchar(128),"owner_id" integer,"damage" bigint,"damage_received" bigint,"damage_given" bigint,"time" datetime, "owner_name" varchar(128) NOT NULL DEFAULT '')`
+	sqliteContributionsIndex    = `CREATE INDEX idx_contributions_encounter_uid ON "contributions"(encounter_uid)`
+	sqliteCombatantsV1          = `CREATE TABLE IF NOT EXISTS "combatants" ("id" integer primary key autoincrement UNIQUE,"user_id" bigint,"player_id" integer,"encounter_uid" varchar(32),"act_encounter_id" integer,"time" datetime,"job" varchar(3),"damage" integer,"damage_taken" integer,"damage_healed" integer,"deaths" integer,"hits" integer,"heals" integer,"kills" integer )`
+)
+
+// Migrations - all database migrations in order, never change a released migration, add a new one
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			// schema created by auto migrate before migrations were added, databases
+			// from then already have these tables so the baseline applies to both
+			return execDialectSQL(tx, migrationSQL{
+				"sqlite3": {
+					sqliteUsersV1,
+					sqliteUsersIndex,
+					sqliteEncountersV1,
+					sqliteEncountersSearchIndex,
+					sqliteEncountersUIDIndex,
+					sqliteCombatantsV1,
+					`CREATE TABLE IF NOT EXISTS "players" ("player_id" integer primary key autoincrement NOT NULL,"name" varchar(128),"act_name" varchar(128),"world" varchar(64) )`,
+				},
+				"postgres": {
+					`CREATE TABLE IF NOT EXISTS "users" ("id" bigserial PRIMARY KEY,"created" timestamp with time zone,"accessed" timestamp with time zone,"upload_key" varchar(32) NOT NULL UNIQUE,"web_key" varchar(32) NOT NULL UNIQUE,"ff_tools_uid" varchar(32))`,
+					`CREATE INDEX IF NOT EXISTS idx_users_ff_tools_uid ON "users"(ff_tools_uid)`,
+					`CREATE TABLE IF NOT EXISTS "encounters" ("user_id" bigint,"uid" varchar(32) NOT NULL,"act_id" bigint,"compare_hash" varchar(32) NOT NULL,"start_time" timestamp with time zone,"end_time" timestamp with time zone,"zone" varchar(256),"damage" integer,"active" boolean,"end_wait" boolean,"success_level" integer)`,
+					`CREATE INDEX IF NOT EXISTS idx_encounter_search ON "encounters"(user_id, start_time, end_time)`,
+					`CREATE UNIQUE INDEX IF NOT EXISTS uix_encounters_uid ON "encounters"("uid")`,
+					`CREATE TABLE IF NOT EXISTS "combatants" ("id" bigserial PRIMARY KEY,"user_id" bigint,"player_id" integer,"encounter_uid" varchar(32),"act_encounter_id" bigint,"time" timestamp with time zone,"job" varchar(3),"damage" integer,"damage_taken" integer,"damage_healed" integer,"deaths" integer,"hits" integer,"heals" integer,"kills" integer)`,
+					`CREATE TABLE IF NOT EXISTS "players" ("player_id" integer PRIMARY KEY,"name" varchar(128),"act_name" varchar(128),"world" varchar(64))`,
+				},
+			})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "players", "combatants", "encounters", "users")
+		},
+	},
+	{
+		Version: 2,
+		Name:    "combatant dot and hot totals",
+		Up: func(tx *gorm.DB) error {
+			return execSQL(tx,
+				`ALTER TABLE "combatants" ADD COLUMN "dot_damage" integer NOT NULL DEFAULT 0`,
+				`ALTER TABLE "combatants" ADD COLUMN "hot_healed" integer NOT NULL DEFAULT 0`,
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "combatants", []string{"dot_damage", "hot_healed"}, sqliteCombatantsV1)
+		},
+	},
+	{
+		Version: 3,
+		Name:    "casts",
+		Up: func(tx *gorm.DB) error {
+			return execDialectSQL(tx, migrationSQL{
+				"sqlite3": {
+					`CREATE TABLE "casts" ("id" integer primary key autoincrement,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"combatant_name" varchar(128),"ability_id" integer,"ability_name" varchar(128),"target_id" integer,"target_name" varchar(128),"start_time" datetime,"duration" integer,"end_time" datetime,"status" integer)`,
+					`CREATE INDEX idx_casts_encounter_uid ON "casts"(encounter_uid)`,
+				},
+				"postgres": {
+					`CREATE TABLE "casts" ("id" bigserial PRIMARY KEY,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"combatant_name" varchar(128),"ability_id" integer,"ability_name" varchar(128),"target_id" integer,"target_name" varchar(128),"start_time" timestamp with time zone,"duration" integer,"end_time" timestamp with time zone,"status" integer)`,
+					`CREATE INDEX idx_casts_encounter_uid ON "casts"(encounter_uid)`,
+				},
+			})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "casts")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "death recaps",
+		Up: func(tx *gorm.DB) error {
+			return execDialectSQL(tx, migrationSQL{
+				"sqlite3": {
+					`CREATE TABLE "death_recaps" ("id" integer primary key autoincrement,"user_id" bigint,"encounter_uid" varchar(32),"target_id" integer,"target_name" varchar(128),"time" datetime)`,
+					`CREATE INDEX idx_death_recaps_encounter_uid ON "death_recaps"(encounter_uid)`,
+					`CREATE TABLE "death_recap_events" ("id" integer primary key autoincrement,"death_recap_id" bigint,"time" datetime,"type" integer,"source_id" integer,"source_name" varchar(128),"ability_id" integer,"ability_name" varchar(128),"amount" integer,"hp_before" integer,"max_hp" integer,"killing_blow" bool)`,
+					`CREATE INDEX idx_death_recap_events_death_recap_id ON "death_recap_events"(death_recap_id)`,
+				},
+				"postgres": {
+					`CREATE TABLE "death_recaps" ("id" bigserial PRIMARY KEY,"user_id" bigint,"encounter_uid" varchar(32),"target_id" integer,"target_name" varchar(128),"time" timestamp with time zone)`,
+					`CREATE INDEX idx_death_recaps_encounter_uid ON "death_recaps"(encounter_uid)`,
+					`CREATE TABLE "death_recap_events" ("id" bigserial PRIMARY KEY,"death_recap_id" bigint,"time" timestamp with time zone,"type" integer,"source_id" integer,"source_name" varchar(128),"ability_id" integer,"ability_name" varchar(128),"amount" integer,"hp_before" integer,"max_hp" integer,"killing_blow" boolean)`,
+					`CREATE INDEX idx_death_recap_events_death_recap_id ON "death_recap_events"(death_recap_id)`,
+				},
+			})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "death_recap_events", "death_recaps")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "ability stats",
+		Up: func(tx *gorm.DB) error {
+			return execDialectSQL(tx, migrationSQL{
+				"sqlite3": {
+					`CREATE TABLE "ability_stats" ("id" integer primary key autoincrement,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"combatant_name" varchar(128),"owner_id" integer,"ability_id" integer,"ability_name" varchar(128),"type" integer,"total" integer,"hits" integer,"crits" integer,"direct_hits" integer,"max_hit" integer,"time" datetime)`,
+					`CREATE INDEX idx_ability_stats_encounter_uid ON "ability_stats"(encounter_uid)`,
+				},
+				"postgres": {
+					`CREATE TABLE "ability_stats" ("id" bigserial PRIMARY KEY,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"combatant_name" varchar(128),"owner_id" integer,"ability_id" integer,"ability_name" varchar(128),"type" integer,"total" integer,"hits" integer,"crits" integer,"direct_hits" integer,"max_hit" integer,"time" timestamp with time zone)`,
+					`CREATE INDEX idx_ability_stats_encounter_uid ON "ability_stats"(encounter_uid)`,
+				},
+			})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "ability_stats")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "time series",
+		Up: func(tx *gorm.DB) error {
+			return execDialectSQL(tx, migrationSQL{
+				"sqlite3": {
+					`CREATE TABLE "time_series" ("id" integer primary key autoincrement,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"combatant_name" varchar(128),"owner_id" integer,"start_time" datetime,"resolution" integer,"buckets" blob)`,
+					`CREATE INDEX idx_time_series_encounter_uid ON "time_series"(encounter_uid)`,
+				},
+				"postgres": {
+					`CREATE TABLE "time_series" ("id" bigserial PRIMARY KEY,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"combatant_name" varchar(128),"owner_id" integer,"start_time" timestamp with time zone,"resolution" integer,"buckets" bytea)`,
+					`CREATE INDEX idx_time_series_encounter_uid ON "time_series"(encounter_uid)`,
+				},
+			})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "time_series")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "raid buff contributions",
+		Up: func(tx *gorm.DB) error {
+			return execDialectSQL(tx, migrationSQL{
+				"sqlite3": {
+					sqliteContributionsV7,
+					sqliteContributionsIndex,
+				},
+				"postgres": {
+					`CREATE TABLE "contributions" ("id" bigserial PRIMARY KEY,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"combatant_name" varchar(128),"owner_id" integer,"damage" bigint,"damage_received" bigint,"damage_given" bigint,"time" timestamp with time zone)`,
+					`CREATE INDEX idx_contributions_encounter_uid ON "contributions"(encounter_uid)`,
+				},
+			})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "contributions")
+		},
+	},
+	{
+		Version: 8,
+		Name:    "enemy roster",
+		Up: func(tx *gorm.DB) error {
+			return execDialectSQL(tx, migrationSQL{
+				"sqlite3": {
+					`CREATE TABLE "enemies" ("id" integer primary key autoincrement,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"name" varchar(128),"max_hp" integer,"first_seen" datetime,"defeated_time" datetime,"damage_taken" bigint)`,
+					`CREATE INDEX idx_enemies_encounter_uid ON "enemies"(encounter_uid)`,
+					`CREATE TABLE "enemy_damage_sources" ("id" integer primary key autoincrement,"enemy_id" bigint,"source_id" integer,"source_name" varchar(128),"damage" bigint)`,
+					`CREATE INDEX idx_enemy_damage_sources_enemy_id ON "enemy_damage_sources"(enemy_id)`,
+				},
+				"postgres": {
+					`CREATE TABLE "enemies" ("id" bigserial PRIMARY KEY,"user_id" bigint,"encounter_uid" varchar(32),"combatant_id" integer,"name" varchar(128),"max_hp" integer,"first_seen" timestamp with time zone,"defeated_time" timestamp with time zone,"damage_taken" bigint)`,
+					`CREATE INDEX idx_enemies_encounter_uid ON "enemies"(encounter_uid)`,
+					`CREATE TABLE "enemy_damage_sources" ("id" bigserial PRIMARY KEY,"enemy_id" bigint,"source_id" integer,"source_name" varchar(128),"damage" bigint)`,
+					`CREATE INDEX idx_enemy_damage_sources_enemy_id ON "enemy_damage_sources"(enemy_id)`,
+				},
+			})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, "enemy_damage_sources", "enemies")
+		},
+	},
+	{
+		Version: 9,
+		Name:    "pinned encounters and retention",
+		Up: func(tx *gorm.DB) error {
+			return execSQL(tx,
+				`ALTER TABLE "users" ADD COLUMN "retention_days" integer NOT NULL DEFAULT 0`,
+				`ALTER TABLE "users" ADD COLUMN "retention_group" varchar(64) NOT NULL DEFAULT ''`,
+				`ALTER TABLE "encounters" ADD COLUMN "pinned" boolean NOT NULL DEFAULT false`,
+				`CREATE TABLE "retention_groups" ("name" varchar(64) PRIMARY KEY,"days" integer)`,
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			err := dropTables(tx, "retention_groups")
+			if err != nil {
+				return err
+			}
+			err = dropColumns(tx, "users", []string{"retention_days", "retention_group"}, sqliteUsersV1, sqliteUsersIndex)
+			if err != nil {
+				return err
+			}
+			return dropColumns(tx, "encounters", []string{"pinned"}, sqliteEncountersV1, sqliteEncountersSearchIndex, sqliteEncountersUIDIndex)
+		},
+	},
+	{
+		Version: 10,
+		Name:    "storage usage and quotas",
+		Up: func(tx *gorm.DB) error {
+			return execSQL(tx,
+				`ALTER TABLE "users" ADD COLUMN "quota_encounters" bigint NOT NULL DEFAULT 0`,
+				`ALTER TABLE "users" ADD COLUMN "quota_bytes" bigint NOT NULL DEFAULT 0`,
+				`ALTER TABLE "encounters" ADD COLUMN "storage_rows" bigint NOT NULL DEFAULT 0`,
+				`ALTER TABLE "encounters" ADD COLUMN "storage_bytes" bigint NOT NULL DEFAULT 0`,
+				// file bytes are counted by the storage check
+				`UPDATE "encounters" SET "storage_rows" = 1
+					+ (SELECT COUNT(*) FROM combatants WHERE combatants.encounter_uid = encounters.uid)
+					+ (SELECT COUNT(*) FROM casts WHERE casts.encounter_uid = encounters.uid)
+					+ (SELECT COUNT(*) FROM death_recaps WHERE death_recaps.encounter_uid = encounters.uid)
+					+ (SELECT COUNT(*) FROM death_recap_events WHERE death_recap_id IN (
+						SELECT id FROM death_recaps WHERE death_recaps.encounter_uid = encounters.uid))
+					+ (SELECT COUNT(*) FROM ability_stats WHERE ability_stats.encounter_uid = encounters.uid)
+					+ (SELECT COUNT(*) FROM time_series WHERE time_series.encounter_uid = encounters.uid)
+					+ (SELECT COUNT(*) FROM contributions WHERE contributions.encounter_uid = encounters.uid)
+					+ (SELECT COUNT(*) FROM enemies WHERE enemies.encounter_uid = encounters.uid)
+					+ (SELECT COUNT(*) FROM enemy_damage_sources WHERE enemy_id IN (
+						SELECT id FROM enemies WHERE enemies.encounter_uid = encounters.uid))`,
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			err := dropColumns(tx, "users", []string{"quota_encounters", "quota_bytes"}, sqliteUsersV9, sqliteUsersIndex)
+			if err != nil {
+				return err
+			}
+			return dropColumns(tx, "encounters", []string{"storage_rows", "storage_bytes"}, sqliteEncountersV9, sqliteEncountersSearchIndex, sqliteEncountersUIDIndex)
+		},
+	},
+	{
+		Version: 11,
+		Name:    "encounter lookup indexes",
+		Up: func(tx *gorm.DB) error {
+			return execSQL(tx,
+				`CREATE INDEX idx_combatants_encounter_uid ON "combatants"(encounter_uid)`,
+				`CREATE INDEX idx_combatants_time ON "combatants"("time")`,
+				`CREATE INDEX idx_encounters_start_time ON "encounters"(start_time)`,
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return execSQL(tx,
+				`DROP INDEX idx_combatants_encounter_uid`,
+				`DROP INDEX idx_combatants_time`,
+				`DROP INDEX idx_encounters_start_time`,
+			)
+		},
+	},
+	{
+		Version: 12,
+		Name:    "contribution owner names",
+		Up: func(tx *gorm.DB) error {
+			return execSQL(tx,
+				`ALTER TABLE "contributions" ADD COLUMN "owner_name" varchar(128) NOT NULL DEFAULT ''`,
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "contributions", []string{"owner_name"}, sqliteContributionsV7, sqliteContributionsIndex)
+		},
+	},
+	{
+		Version: 13,
+		Name:    "contribution approximate flag",
+		Up: func(tx *gorm.DB) error {
+			return execDialectSQL(tx, migrationSQL{
+				"sqlite3":  {`ALTER TABLE "contributions" ADD COLUMN "approximate" bool NOT NULL DEFAULT 0`},
+				"postgres": {`ALTER TABLE "contributions" ADD COLUMN "approximate" boolean NOT NULL DEFAULT false`},
+			})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, "contributions", []string{"approximate"}, sqliteContributionsV12, sqliteContributionsIndex)
+		},
+	},
+}
+
+// execSQL - run statements in order, stops at first error
+func execSQL(tx *gorm.DB, queries ...string) error {
+	for _, query := range queries {
+		res := tx.Exec(query)
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
+}
+
+// execDialectSQL - run statements for connection's database dialect
+func execDialectSQL(tx *gorm.DB, statements migrationSQL) error {
+	queries, exists := statements[tx.Dialect().GetName()]
+	if !exists {
+		return fmt.Errorf("no migration statements for database driver '%s'", tx.Dialect().GetName())
+	}
+	return execSQL(tx, queries...)
+}
+
+// dropTables - drop given tables if they exist
+func dropTables(tx *gorm.DB, tables ...string) error {
+	for _, table := range tables {
+		err := execSQL(tx, fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, table))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropColumns - drop columns from table, sqlite tables are rebuilt from given
+// definition of the table without the columns followed by its indexes
+func dropColumns(tx *gorm.DB, table string, columns []string, sqliteSchema ...string) error {
+	if tx.Dialect().GetName() != "sqlite3" {
+		for _, column := range columns {
+			err := execSQL(tx, fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN "%s"`, table, column))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// indexes move with the renamed table and are dropped with it
+	oldTable := table + "_old"
+	err := execSQL(tx, fmt.Sprintf(`ALTER TABLE "%s" RENAME TO "%s"`, table, oldTable), sqliteSchema[0])
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Raw(fmt.Sprintf(`SELECT * FROM "%s" LIMIT 0`, table)).Rows()
+	if err != nil {
+		return err
+	}
+	keepColumns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	columnList := `"` + strings.Join(keepColumns, `","`) + `"`
+	err = execSQL(tx,
+		fmt.Sprintf(`INSERT INTO "%s" (%s) SELECT %s FROM "%s"`, table, columnList, columnList, oldTable),
+		fmt.Sprintf(`DROP TABLE "%s"`, oldTable),
+	)
+	if err != nil {
+		return err
+	}
+	return execSQL(tx, sqliteSchema[1:]...)
+}
+
+// LatestSchemaVersion - version of database schema once all migrations are applied
+func LatestSchemaVersion() int {
+	return Migrations[len(Migrations)-1].Version
+}
@@ -0,0 +1,124 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"sync"
+
+	"../app"
+)
+
+// petOwner - owner of a pet/summon
+type petOwner struct {
+	ID   int32
+	Name string
+}
+
+// OwnerManager - maps pets and summons to the player that owns them, the mapping
+// is kept between encounters as pets are usually summoned before the pull
+type OwnerManager struct {
+	owners map[int32]petOwner
+	names  map[int32]string
+	lock   *sync.Mutex
+	log    app.Logging
+}
+
+// NewOwnerManager - create new owner manager
+func NewOwnerManager() OwnerManager {
+	o := OwnerManager{
+		log:  app.Logging{ModuleName: "OWNER"},
+		lock: &sync.Mutex{},
+	}
+	o.Reset()
+	return o
+}
+
+// Reset - reset owner manager
+func (o *OwnerManager) Reset() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.owners = make(map[int32]petOwner)
+	o.names = make(map[int32]string)
+}
+
+// ReadLogLine - parse log line and update owner mapping
+func (o *OwnerManager) ReadLogLine(l *ParsedLogLine) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	switch l.Type {
+	case LogTypeSingleTarget, LogTypeAoe:
+		{
+			// remember player names so owners can be named
+			if l.AttackerID > 0 && l.AttackerID <= combatantMaxPlayerID {
+				o.names[int32(l.AttackerID)] = l.AttackerName
+			}
+			break
+		}
+	case LogTypeAddCombatant:
+		{
+			// only pets of players are tracked
+			if l.TargetID <= combatantMaxPlayerID || l.AttackerID <= 0 || l.AttackerID > combatantMaxPlayerID {
+				break
+			}
+			o.owners[int32(l.TargetID)] = petOwner{
+				ID:   int32(l.AttackerID),
+				Name: o.names[int32(l.AttackerID)],
+			}
+			break
+		}
+	case LogTypeRemoveCombatant:
+		{
+			delete(o.owners, int32(l.TargetID))
+			break
+		}
+	}
+}
+
+// Attribute - set owner of log line's attacker if it is a known pet
+func (o *OwnerManager) Attribute(l *ParsedLogLine) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	owner, exists := o.owners[int32(l.AttackerID)]
+	if !exists {
+		return
+	}
+	l.AttackerOwnerID = int(owner.ID)
+	l.AttackerOwnerName = owner.Name
+	if l.AttackerOwnerName == "" {
+		l.AttackerOwnerName = o.names[owner.ID]
+	}
+}
+
+// GetOwnerID - get owner id of given pet, 0 if not a known pet
+func (o *OwnerManager) GetOwnerID(petID int32) int32 {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.owners[petID].ID
+}
+
+// getActor - get the combatant a log line's action should be credited to, pets are
+// credited to themselves with their owner set, ok is false for non player actions
+func getActor(l *ParsedLogLine) (id int32, name string, ownerID int32, ok bool) {
+	if l.AttackerID > 0 && l.AttackerID <= combatantMaxPlayerID {
+		return int32(l.AttackerID), l.AttackerName, 0, true
+	}
+	if l.AttackerOwnerID > 0 {
+		return int32(l.AttackerID), l.AttackerName, int32(l.AttackerOwnerID), true
+	}
+	return 0, "", 0, false
+}
@@ -0,0 +1,254 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"../app"
+	"../data"
+)
+
+// positionSampleInterval - min time in ms between position samples for a combatant
+const positionSampleInterval = 500
+
+// PositionManager - samples combatant positions from log lines to build movement tracks
+type PositionManager struct {
+	tracks       []*data.MovementTrack
+	updated      map[int32]int
+	startTime    time.Time
+	storage      BlobStorage
+	lock         *sync.Mutex
+	log          app.Logging
+	encounterUID string
+}
+
+// NewPositionManager - create new position manager
+func NewPositionManager() PositionManager {
+	p := PositionManager{
+		log:     app.Logging{ModuleName: "POSITION"},
+		lock:    &sync.Mutex{},
+		storage: GetBlobStorage(),
+	}
+	p.Reset()
+	return p
+}
+
+// Reset - reset position manager
+func (p *PositionManager) Reset() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.tracks = make([]*data.MovementTrack, 0)
+	p.updated = make(map[int32]int)
+	p.startTime = time.Time{}
+	p.encounterUID = ""
+}
+
+// ResetEncounter - reset with new encounter data
+func (p *PositionManager) ResetEncounter(encounter data.Encounter) {
+	p.Reset()
+	p.encounterUID = encounter.UID
+}
+
+// SetSavePath - set local path to save movement track file to
+func (p *PositionManager) SetSavePath(path string) {
+	p.storage = NewLocalBlobStorage(path)
+}
+
+// SetStorage - set storage to save movement track file to
+func (p *PositionManager) SetStorage(storage BlobStorage) {
+	p.storage = storage
+}
+
+// getTrack - get movement track for given combatant, create if it doesn't exist
+func (p *PositionManager) getTrack(combatantID int32, combatantName string) *data.MovementTrack {
+	for index := range p.tracks {
+		if p.tracks[index].CombatantID == combatantID {
+			return p.tracks[index]
+		}
+	}
+	track := &data.MovementTrack{
+		EncounterUID:  p.encounterUID,
+		CombatantID:   combatantID,
+		CombatantName: combatantName,
+		StartTime:     p.startTime,
+		Samples:       make([]data.PositionSample, 0),
+	}
+	p.tracks = append(p.tracks, track)
+	return track
+}
+
+// addSample - add position sample to combatant's track if enough time has passed
+func (p *PositionManager) addSample(combatantID int32, combatantName string, position *data.Position, t time.Time) {
+	if combatantID <= 0 || position == nil {
+		return
+	}
+	track := p.getTrack(combatantID, combatantName)
+	if len(track.Samples) > 0 && t.Sub(track.Samples[len(track.Samples)-1].Time) < positionSampleInterval*time.Millisecond {
+		return
+	}
+	track.Samples = append(track.Samples, data.PositionSample{
+		Position: *position,
+		Time:     t,
+	})
+	if _, exists := p.updated[combatantID]; !exists {
+		p.updated[combatantID] = len(track.Samples) - 1
+	}
+}
+
+// ReadLogLine - parse log line and sample attacker/target positions
+func (p *PositionManager) ReadLogLine(l *ParsedLogLine) {
+	if l.Type != LogTypeSingleTarget && l.Type != LogTypeAoe {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.startTime.IsZero() {
+		p.startTime = l.Time
+	}
+	p.addSample(int32(l.AttackerID), l.AttackerName, l.AttackerPosition, l.Time)
+	p.addSample(int32(l.TargetID), l.TargetName, l.TargetPosition, l.Time)
+}
+
+// GetTracks - get all movement tracks in encounter
+func (p *PositionManager) GetTracks() []data.MovementTrack {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	output := make([]data.MovementTrack, 0)
+	for index := range p.tracks {
+		track := *p.tracks[index]
+		track.Samples = append([]data.PositionSample{}, p.tracks[index].Samples...)
+		output = append(output, track)
+	}
+	return output
+}
+
+// Dump - get position samples added since last dump
+func (p *PositionManager) Dump() []data.MovementTrack {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	output := make([]data.MovementTrack, 0)
+	for index := range p.tracks {
+		firstIndex, exists := p.updated[p.tracks[index].CombatantID]
+		if !exists {
+			continue
+		}
+		track := *p.tracks[index]
+		track.Samples = append([]data.PositionSample{}, p.tracks[index].Samples[firstIndex:]...)
+		output = append(output, track)
+	}
+	p.updated = make(map[int32]int)
+	return output
+}
+
+// GetPositionFileKey - get storage key of movement track file
+func GetPositionFileKey(encounterUID string) string {
+	return fmt.Sprintf("fflp_%s_Position.dat", encounterUID)
+}
+
+// GetPositionFilePath - get path to movement track file in local storage
+func GetPositionFilePath(savePath string, encounterUID string) string {
+	return path.Join(savePath, GetPositionFileKey(encounterUID))
+}
+
+// Save - save movement tracks next to encounter log file
+func (p *PositionManager) Save() error {
+	if p.encounterUID == "" {
+		return fmt.Errorf("can't save movement tracks without encounter uid set")
+	}
+	tracks := p.GetTracks()
+	if len(tracks) == 0 {
+		return nil
+	}
+	f, err := p.storage.Create(GetPositionFileKey(p.encounterUID))
+	if err != nil {
+		return err
+	}
+	gf := gzip.NewWriter(f)
+	fw := bufio.NewWriter(gf)
+	// each track is prefixed with its length
+	for index := range tracks {
+		trackBytes := tracks[index].ToBytes()
+		err = binary.Write(fw, binary.BigEndian, uint32(len(trackBytes)))
+		if err != nil {
+			f.Discard()
+			return err
+		}
+		_, err = fw.Write(trackBytes)
+		if err != nil {
+			f.Discard()
+			return err
+		}
+	}
+	fw.Flush()
+	err = gf.Close()
+	if err != nil {
+		f.Discard()
+		return err
+	}
+	return f.Close()
+}
+
+// Load - load movement tracks for given encounter, encounters
+// saved before positions were tracked have no file
+func (p *PositionManager) Load(encounterUID string) error {
+	p.Reset()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.encounterUID = encounterUID
+	f, err := p.storage.Open(GetPositionFileKey(encounterUID), 0)
+	if err == ErrBlobNotExist {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+	for {
+		var length uint32
+		err = binary.Read(gr, binary.BigEndian, &length)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		trackBytes := make([]byte, length)
+		_, err = io.ReadFull(gr, trackBytes)
+		if err != nil {
+			return err
+		}
+		track := &data.MovementTrack{}
+		err = track.FromBytes(trackBytes)
+		if err != nil {
+			return err
+		}
+		p.tracks = append(p.tracks, track)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"../app"
+	"../data"
+)
+
+// updateGolden - regenerate golden files instead of comparing against them,
+// go test -run TestReplay -update
+var updateGolden = flag.Bool("update", false, "regenerate replay test golden files")
+
+// replayFixturePath - directory containing recorded log files and their golden files
+const replayFixturePath = "testdata/replay"
+
+// replayCombatantDirective - fixture line prefix for a combatant update from act, followed by json
+const replayCombatantDirective = "#combatant "
+
+// replayEncounterDirective - fixture line prefix for an encounter update from act, followed by json
+const replayEncounterDirective = "#encounter "
+
+// replayCombatant - combatant totals at the end of a replayed encounter
+type replayCombatant struct {
+	Name         string `json:"name"`
+	Job          string `json:"job"`
+	Damage       int32  `json:"damage"`
+	DamageTaken  int32  `json:"damage_taken"`
+	DamageHealed int32  `json:"damage_healed"`
+	Deaths       int32  `json:"deaths"`
+	Hits         int32  `json:"hits"`
+	Heals        int32  `json:"heals"`
+	Kills        int32  `json:"kills"`
+	DotDamage    int32  `json:"dot_damage"`
+	HotHealed    int32  `json:"hot_healed"`
+}
+
+// replayEncounter - encounter detected while replaying a log file
+type replayEncounter struct {
+	Zone         string            `json:"zone"`
+	SuccessLevel uint8             `json:"success_level"`
+	Start        string            `json:"start"`
+	Duration     string            `json:"duration"`
+	Combatants   []replayCombatant `json:"combatants"`
+}
+
+// replayResult - everything compared against the golden file
+type replayResult struct {
+	LogLines    int               `json:"log_lines"`
+	ParseErrors int               `json:"parse_errors"`
+	Encounters  []replayEncounter `json:"encounters"`
+}
+
+// replayLogLineTime - get time of a recorded log line, colon log lines only
+// contain a time of day so they are placed on the given date
+func replayLogLineTime(logLineString string, date time.Time, last time.Time) time.Time {
+	if isPipeLogLine(logLineString) {
+		return time.Time{}
+	}
+	if len(logLineString) < 14 || logLineString[0] != '[' {
+		return last
+	}
+	timeOfDay, err := time.Parse("15:04:05.000", logLineString[1:13])
+	if err != nil {
+		return last
+	}
+	output := date.Add(
+		time.Duration(timeOfDay.Hour())*time.Hour +
+			time.Duration(timeOfDay.Minute())*time.Minute +
+			time.Duration(timeOfDay.Second())*time.Second +
+			time.Duration(timeOfDay.Nanosecond()),
+	)
+	// log passed midnight
+	for output.Before(last.Add(-time.Hour * 12)) {
+		output = output.Add(time.Hour * 24)
+	}
+	return output
+}
+
+// replayCollectEncounter - get encounter and combatant totals from encounter manager
+func replayCollectEncounter(e *EncounterManager, replayStart time.Time) replayEncounter {
+	encounter := e.GetEncounter()
+	output := replayEncounter{
+		Zone:         encounter.Zone,
+		SuccessLevel: encounter.SuccessLevel,
+		Start:        encounter.StartTime.Sub(replayStart).String(),
+		Duration:     encounter.EndTime.Sub(encounter.StartTime).String(),
+		Combatants:   make([]replayCombatant, 0),
+	}
+	for _, combatant := range e.CombatantManager.GetLastCombatants() {
+		output.Combatants = append(output.Combatants, replayCombatant{
+			Name:         combatant.Player.Name,
+			Job:          combatant.Job,
+			Damage:       combatant.Damage,
+			DamageTaken:  combatant.DamageTaken,
+			DamageHealed: combatant.DamageHealed,
+			Deaths:       combatant.Deaths,
+			Hits:         combatant.Hits,
+			Heals:        combatant.Heals,
+			Kills:        combatant.Kills,
+			DotDamage:    combatant.DotDamage,
+			HotHealed:    combatant.HotHealed,
+		})
+	}
+	sort.Slice(output.Combatants, func(i, j int) bool {
+		return output.Combatants[i].Name < output.Combatants[j].Name
+	})
+	return output
+}
+
+// replayLogFile - replay recorded log file through the parser, encounter
+// manager and combatant manager, the encounter manager clock follows the log
+func replayLogFile(t *testing.T, path string) replayResult {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer f.Close()
+	result := replayResult{Encounters: make([]replayEncounter, 0)}
+	replayDate := time.Date(2019, 8, 4, 0, 0, 0, 0, time.UTC)
+	var replayStart, now time.Time
+	e := NewEncounterManager(nil, data.User{})
+	e.clock = func() time.Time { return now }
+	e.Reset()
+	// record encounter once it goes from active to inactive
+	wasActive := false
+	checkEncounter := func() {
+		if wasActive && !e.GetEncounter().Active {
+			result.Encounters = append(result.Encounters, replayCollectEncounter(&e, replayStart))
+		}
+		wasActive = e.GetEncounter().Active
+	}
+	// tick at the same rate as the session manager until given time
+	tickUntil := func(until time.Time) {
+		for !now.IsZero() && now.Add(time.Millisecond*app.TickRate).Before(until) {
+			now = now.Add(time.Millisecond * app.TickRate)
+			e.Tick()
+			checkEncounter()
+		}
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// combatant update sent by act
+		if strings.HasPrefix(line, replayCombatantDirective) {
+			combatant := data.Combatant{}
+			if err := json.Unmarshal([]byte(line[len(replayCombatantDirective):]), &combatant); err != nil {
+				t.Fatalf("Error occurred...%s", err)
+			}
+			combatant.Time = now
+			e.CombatantManager.Update(combatant)
+			continue
+		}
+		// encounter update sent by act
+		if strings.HasPrefix(line, replayEncounterDirective) {
+			encounter := data.Encounter{}
+			if err := json.Unmarshal([]byte(line[len(replayEncounterDirective):]), &encounter); err != nil {
+				t.Fatalf("Error occurred...%s", err)
+			}
+			e.Update(encounter)
+			continue
+		}
+		// blank line or comment
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		logLine := data.LogLine{Time: replayLogLineTime(line, replayDate, now), LogLine: line}
+		l, err := ParseLogLine(logLine)
+		result.LogLines++
+		if err != nil {
+			result.ParseErrors++
+			continue
+		}
+		tickUntil(l.Time)
+		now = l.Time
+		if replayStart.IsZero() {
+			replayStart = now
+		}
+		e.ReadLogLine(&l)
+		checkEncounter()
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	// let any pending team wipe or inactivity timeout expire
+	tickUntil(now.Add(time.Millisecond * (noActionTimeout + teamDeadTimeout)))
+	return result
+}
+
+func TestReplay(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(replayFixturePath, "*.log"))
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("No replay fixtures found in '%s.'", replayFixturePath)
+	}
+	for _, path := range paths {
+		goldenPath := strings.TrimSuffix(path, ".log") + ".golden.json"
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			result := replayLogFile(t, path)
+			resultJSON, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				t.Fatalf("Error occurred...%s", err)
+			}
+			resultJSON = append(resultJSON, '\n')
+			if *updateGolden {
+				if err := ioutil.WriteFile(goldenPath, resultJSON, 0644); err != nil {
+					t.Fatalf("Error occurred...%s", err)
+				}
+				return
+			}
+			golden, err := ioutil.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("Error occurred...%s (run with -update to create golden file)", err)
+			}
+			if strings.Replace(string(golden), "\r\n", "\n", -1) != string(resultJSON) {
+				t.Errorf("Replay of '%s' does not match '%s' (run with -update if the change is intended).\nGot:\n%s\nExpected:\n%s", path, goldenPath, resultJSON, golden)
+			}
+		})
+	}
+}
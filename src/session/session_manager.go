@@ -33,6 +33,7 @@ type UserSession struct {
 	Session          data.Session
 	User             data.User
 	EncounterManager EncounterManager
+	LogLineParser    LogLineParser
 	StartTime        time.Time
 }
 
@@ -44,6 +45,7 @@ type Manager struct {
 	Database          *DatabaseHandler
 	UserManager       UserManager
 	logLinesProcessed int64
+	ParseStats        ParseStats
 }
 
 // NewSessionManager - create new session manager
@@ -55,6 +57,7 @@ func NewSessionManager(dbHandler *DatabaseHandler, events *emitter.Emitter) (Man
 		UserManager:       NewUserManager(dbHandler),
 		events:            events,
 		logLinesProcessed: 0,
+		ParseStats:        NewParseStats(app.GetParseErrorSamplePath()),
 	}, nil
 }
 
@@ -106,6 +109,8 @@ func (m *Manager) Update(dataStr []byte, addr *net.UDPAddr) {
 				for index := range m.sessions {
 					if m.sessions[index].User.ID == user.ID {
 						m.sessions[index].Session = actSessionData
+						// plugin may have been updated, detect log line format again
+						m.sessions[index].LogLineParser = nil
 						m.log.Log(fmt.Sprintf("Updated session for user '%d' from '%s.'", m.sessions[index].User.ID, addr))
 						return
 					}
@@ -190,10 +195,16 @@ func (m *Manager) Update(dataStr []byte, addr *net.UDPAddr) {
 				m.log.Error(err)
 				return
 			}
+			// detect log line format from first log line of session
+			if session.LogLineParser == nil {
+				session.LogLineParser = DetectLogLineParser(logLine.LogLine, session.Session.Language)
+				m.log.Log(fmt.Sprintf("Using %s log line format (language '%s') for user '%d.'", session.LogLineParser.Name(), session.Session.Language, session.User.ID))
+			}
+			// parse log line, once, shared by all managers
+			parsedLogLine, err := session.LogLineParser.Parse(logLine)
+			m.ParseStats.ReadParseResult(logLine, &parsedLogLine, err)
 			// add to log line manager
-			session.EncounterManager.LogLineManager.Update(logLine)
-			// parse log line
-			parsedLogLine, err := ParseLogLine(logLine)
+			session.EncounterManager.LogLineManager.UpdateParsed(logLine, &parsedLogLine)
 			if err != nil {
 				m.log.Error(err)
 				return
@@ -236,6 +247,7 @@ func (m *Manager) handdleSession(session *UserSession) {
 	lastActivity := time.Now()
 	encounterActive := false
 	encounterEndWait := false
+	quotaExceeded := false
 	encounterZone := ""
 	lastCombatantUpdate := time.Time{}
 	lastEncounterSend := time.Time{}
@@ -264,6 +276,20 @@ func (m *Manager) handdleSession(session *UserSession) {
 			)
 		}
 		encounterActive = encounter.Active
+		// send flag when encounter wasn't saved because user is over quota
+		if session.EncounterManager.IsQuotaExceeded() != quotaExceeded {
+			quotaExceeded = session.EncounterManager.IsQuotaExceeded()
+			quotaFlag := data.Flag{Name: "quota_exceeded", Value: quotaExceeded}
+			quotaFlagBytes, err := data.CompressBytes(quotaFlag.ToBytes())
+			if err != nil {
+				continue
+			}
+			go m.events.Emit(
+				"act:flag",
+				session.User.ID,
+				quotaFlagBytes,
+			)
+		}
 		// send combatants
 		if session.EncounterManager.CombatantManager.GetLastUpdate().After(lastCombatantUpdate) {
 			combatantBytes := make([]byte, 0)
@@ -286,6 +312,115 @@ func (m *Manager) handdleSession(session *UserSession) {
 			}
 			lastCombatantUpdate = session.EncounterManager.CombatantManager.GetLastUpdate()
 		}
+		// send casts
+		castBytes := make([]byte, 0)
+		casts := session.EncounterManager.CastManager.Dump()
+		for index := range casts {
+			castBytes = append(castBytes, casts[index].ToBytes()...)
+		}
+		if len(castBytes) > 0 {
+			lastActivity = time.Now()
+			castBytes, err = data.CompressBytes(castBytes)
+			if err != nil {
+				continue
+			}
+			go m.events.Emit(
+				"act:cast",
+				session.User.ID,
+				castBytes,
+			)
+		}
+		// send death recaps
+		deathRecapBytes := make([]byte, 0)
+		deathRecaps := session.EncounterManager.DeathRecapManager.Dump()
+		for index := range deathRecaps {
+			deathRecaps[index].EncounterUID = encounter.UID
+			deathRecapBytes = append(deathRecapBytes, deathRecaps[index].ToBytes()...)
+		}
+		if len(deathRecapBytes) > 0 {
+			lastActivity = time.Now()
+			deathRecapBytes, err = data.CompressBytes(deathRecapBytes)
+			if err != nil {
+				continue
+			}
+			go m.events.Emit(
+				"act:deathRecap",
+				session.User.ID,
+				deathRecapBytes,
+			)
+		}
+		// send ability stats
+		abilityStatBytes := make([]byte, 0)
+		abilityStats := session.EncounterManager.AbilityStatManager.Dump()
+		for index := range abilityStats {
+			abilityStats[index].EncounterUID = encounter.UID
+			abilityStatBytes = append(abilityStatBytes, abilityStats[index].ToBytes()...)
+		}
+		if len(abilityStatBytes) > 0 {
+			abilityStatBytes, err = data.CompressBytes(abilityStatBytes)
+			if err != nil {
+				continue
+			}
+			go m.events.Emit(
+				"act:abilityStat",
+				session.User.ID,
+				abilityStatBytes,
+			)
+		}
+		// send time series
+		timeSeriesBytes := make([]byte, 0)
+		timeSeries := session.EncounterManager.TimeSeriesManager.Dump()
+		for index := range timeSeries {
+			timeSeries[index].EncounterUID = encounter.UID
+			timeSeriesBytes = append(timeSeriesBytes, timeSeries[index].ToBytes()...)
+		}
+		if len(timeSeriesBytes) > 0 {
+			timeSeriesBytes, err = data.CompressBytes(timeSeriesBytes)
+			if err != nil {
+				continue
+			}
+			go m.events.Emit(
+				"act:timeSeries",
+				session.User.ID,
+				timeSeriesBytes,
+			)
+		}
+		// send movement tracks
+		movementBytes := make([]byte, 0)
+		movementTracks := session.EncounterManager.PositionManager.Dump()
+		for index := range movementTracks {
+			movementTracks[index].EncounterUID = encounter.UID
+			movementBytes = append(movementBytes, movementTracks[index].ToBytes()...)
+		}
+		if len(movementBytes) > 0 {
+			movementBytes, err = data.CompressBytes(movementBytes)
+			if err != nil {
+				continue
+			}
+			go m.events.Emit(
+				"act:movement",
+				session.User.ID,
+				movementBytes,
+			)
+		}
+		// send enemies
+		enemyBytes := make([]byte, 0)
+		enemies := session.EncounterManager.EnemyManager.Dump()
+		for index := range enemies {
+			enemies[index].EncounterUID = encounter.UID
+			enemyBytes = append(enemyBytes, enemies[index].ToBytes()...)
+		}
+		if len(enemyBytes) > 0 {
+			enemyBytes, err = data.CompressBytes(enemyBytes)
+			if err != nil {
+				continue
+			}
+			go m.events.Emit(
+				"act:enemy",
+				session.User.ID,
+				enemyBytes,
+			)
+		}
 		// dump+send log lines
 		logLineBytes := make([]byte, 0)
 		logLines, err := session.EncounterManager.LogLineManager.Dump()
@@ -345,6 +480,8 @@ func (m *Manager) SnapshotListener() {
 				statSnapshot.Connections.ACT[m.sessions[index].User.ID] = 1
 			}
 			statSnapshot.LogLines = m.logLinesProcessed
+			statSnapshot.ParseErrors = m.ParseStats.GetErrorCounts()
+			statSnapshot.UnknownLogTypes = m.ParseStats.GetUnknownTypeCounts()
 		}
 	}
 }
@@ -18,13 +18,22 @@ along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
 package session
 
 import (
+	"bufio"
+	"bytes"
 	"compress/gzip"
-	"io"
+	"io/ioutil"
+	"math"
 	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"../data"
+	"github.com/jinzhu/gorm"
+	"github.com/rs/xid"
 )
 
 const logLineAttack = "[11:02:11.617] 15:4000B744:Rhitahtyn sas Arvina:366:Attack:106CB0ED:Minda Silva:710003:3880000:0:0:0:0:0:0:0:0:0:0:0:0:0:0:85041:85041:9600:10000:0:1000:-696.0057:-817.2678:65.7804:-2.090146:140279:140279:8010:8010:0:1000:-701.6327:-819.8078:66.75428:1.188309:00002584"
@@ -33,9 +42,28 @@ const logLineDefeat = "[11:02:31.874] 19:Rhitahtyn Sas Arvina was defeated by Mi
 const logLineZone = "[11:02:42.562] 01:Changed Zone to The Lavender Beds."
 const logLineEnd = "[11:02:42.562] 00:0038:end"
 const logLineWow = "[21:52:50.000] 00:000e:Minda Silva:wowowo"
+const logLineGainEffect = "[11:02:12.004] 1A:4C5:Chain Stratagem:15.00:106CB0ED:Minda Silva:4000B744:Rhitahtyn sas Arvina:00:85041:140279"
+const logLineLoseEffect = "[11:02:20.004] 1E:4C5:Chain Stratagem:0.00:106CB0ED:Minda Silva:4000B744:Rhitahtyn sas Arvina:00"
+const logLineDotTick = "[11:02:14.004] 18:4000B744:Rhitahtyn sas Arvina:DoT:767:1F4A:85041:85041:10000:10000:0:1000:-696.2116:-816.7462:65.75475:-2.381758"
+const logLineGainDot = "[11:02:12.104] 1A:767:Biolysis:30.00:106CB0ED:Minda Silva:4000B744:Rhitahtyn sas Arvina:00:85041:140279"
+const logLineCastStart = "[11:02:20.000] 14:4000B744:Rhitahtyn sas Arvina:1D3C:Magitek Missiles:106CB0ED:Minda Silva:2.70:-696.0057:-817.2678:65.7804:-2.090146"
+const logLineCastInterrupt = "[11:02:21.000] 17:4000B744:Rhitahtyn sas Arvina:1D3C:Magitek Missiles:Interrupted"
+const logLineCastComplete = "[11:02:22.700] 15:4000B744:Rhitahtyn sas Arvina:1D3C:Magitek Missiles:106CB0ED:Minda Silva:710003:3880000:0:0:0:0:0:0:0:0:0:0:0:0:0:0:85041:85041:9600:10000:0:1000:-696.0057:-817.2678:65.7804:-2.090146:140279:140279:8010:8010:0:1000:-701.6327:-819.8078:66.75428:1.188309:00002584"
+const logLineDotTickText = "[11:02:14.004] 18:DoT Tick on Striking Dummy for 1234 damage."
+const logLineHotTickText = "[11:02:14.004] 18:HoT Tick on Minda Silva for 520 damage."
+const logLineGainEffectText = "[11:02:12.004] 1A:Minda Silva gains the effect of Galvanize from Minda Silva for 30.00 Seconds."
+const logLinePlayerDefeat = "[11:02:25.000] 19:Minda Silva was defeated by Rhitahtyn sas Arvina."
+const logLineAddPet = "[11:01:50.000] 03:40016A8B:Eos:0:50:106CB0ED:0::1398:1398:63012:63012:10000:10000:0:0:-701.6327:-819.8078:66.75428:1.188309"
+const logLineRemovePetText = "[11:02:40.000] 04:40016A8B:Removing combatant Eos.  Max HP: 63012."
+const logLineAddPetText = "[11:01:50.000] 03:40016A8B:Added new combatant Eos.  Job: N/A Level: 80 Max HP: 63012 Max MP: 10000 Owner: 106CB0ED."
+const logLinePipeAbility = "21|2019-08-04T11:02:17.0920000-07:00|106CB0ED|Minda Silva|409D|Hissatsu:Guren|4000B744|Rhitahtyn sas Arvina|750103|65950000|1C|409D8000|0|0|0|0|0|0|0|0|0|0|0|0|109947|140279|8010|8010|0|1000|-697.5967|-818.204|65.92983|0.7683923|85041|85041|10000|10000|0|1000|-696.2116|-816.7462|65.75475|-2.381758|0000258D|c2ffa46a9ee1d3e4"
+const logLinePipeGainEffect = "26|2019-08-04T11:02:12.0040000-07:00|4C5|Chain Stratagem|15.00|106CB0ED|Minda Silva|4000B744|Rhitahtyn sas Arvina|00|85041|140279|3c1a2b9e8d7f6a50"
+const logLinePipeAddCombatant = "03|2019-08-04T11:01:50.0000000-07:00|4000B744|Rhitahtyn sas Arvina|00|46|0000|00||2296|4001|85041|85041|10000|10000|0|0|-700.1|-818.2|65.9|0.76|4f3a2b1c0d9e8f7a"
+const logLinePipeEnd = "00|2019-08-04T11:02:42.5620000-07:00|0038||end|7a1c3e5f2b4d6a8c"
+const logLinePipeDefeat = "25|2019-08-04T11:02:31.8740000-07:00|4000B744|Rhitahtyn sas Arvina|106CB0ED|Minda Silva|9d2e4f6a1b3c5d7e"
 
 func TestEncounterTeamDefeat(t *testing.T) {
-	e := NewEncounterManager(nil)
+	e := NewEncounterManager(nil, data.User{})
 
 	ll1, _ := ParseLogLine(
 		data.LogLine{
@@ -67,7 +95,7 @@ func TestEncounterTeamDefeat(t *testing.T) {
 }
 
 func TestEncounterTeamRevive(t *testing.T) {
-	e := NewEncounterManager(nil)
+	e := NewEncounterManager(nil, data.User{})
 	llEnemyAtk, _ := ParseLogLine(
 		data.LogLine{
 			Time:    time.Now().Add(time.Second),
@@ -112,7 +140,7 @@ func TestEncounterTeamRevive(t *testing.T) {
 }
 
 func TestEncounterZoneChange(t *testing.T) {
-	e := NewEncounterManager(nil)
+	e := NewEncounterManager(nil, data.User{})
 	llAtk, _ := ParseLogLine(
 		data.LogLine{
 			Time:    time.Now(),
@@ -157,31 +185,33 @@ func TestEncounterZoneChange(t *testing.T) {
 }
 
 func TestEncounterEchoEnd(t *testing.T) {
-	e := NewEncounterManager(nil)
-	llAtk, _ := ParseLogLine(
-		data.LogLine{
-			Time:    time.Now(),
-			LogLine: logLineBroil,
-		},
-	)
-	e.ReadLogLine(&llAtk)
-	llEnd, _ := ParseLogLine(
-		data.LogLine{
-			Time:    time.Now().Add(time.Second),
-			LogLine: logLineEnd,
-		},
-	)
-	e.ReadLogLine(&llEnd)
-	if e.GetEncounter().Active {
-		t.Errorf("Encounter should not be active after echo end event.")
-	}
-	if e.GetEncounter().SuccessLevel != EncounterSuccessEnd {
-		t.Errorf("Invalid success level flag after echo end event.")
+	for _, logLineEnd := range []string{logLineEnd, logLinePipeEnd} {
+		e := NewEncounterManager(nil, data.User{})
+		llAtk, _ := ParseLogLine(
+			data.LogLine{
+				Time:    time.Now(),
+				LogLine: logLineBroil,
+			},
+		)
+		e.ReadLogLine(&llAtk)
+		llEnd, _ := ParseLogLine(
+			data.LogLine{
+				Time:    time.Now().Add(time.Second),
+				LogLine: logLineEnd,
+			},
+		)
+		e.ReadLogLine(&llEnd)
+		if e.GetEncounter().Active {
+			t.Errorf("Encounter should not be active after echo end event (%s).", logLineEnd)
+		}
+		if e.GetEncounter().SuccessLevel != EncounterSuccessEnd {
+			t.Errorf("Invalid success level flag after echo end event (%s).", logLineEnd)
+		}
 	}
 }
 
 func TestEncounterLength(t *testing.T) {
-	e := NewEncounterManager(nil)
+	e := NewEncounterManager(nil, data.User{})
 	llAtk, _ := ParseLogLine(
 		data.LogLine{
 			Time:    time.Now(),
@@ -270,6 +300,12 @@ func TestCombatant(t *testing.T) {
 
 }
 
+// updateLogLine - parse log line and add it to log line manager
+func updateLogLine(l *LogLineManager, logLine data.LogLine) {
+	pLogLine, _ := ParseLogLine(logLine)
+	l.UpdateParsed(logLine, &pLogLine)
+}
+
 func TestLogLine(t *testing.T) {
 	l := NewLogLineManager()
 	defer l.Reset()
@@ -285,9 +321,9 @@ func TestLogLine(t *testing.T) {
 		Time:    time.Now().Add(time.Second * 2),
 		LogLine: logLineWow,
 	}
-	l.Update(ll1)
-	l.Update(ll2)
-	l.Update(ll3) // should be ignored
+	updateLogLine(&l, ll1)
+	updateLogLine(&l, ll2)
+	updateLogLine(&l, ll3) // should be ignored
 	logLines, err := l.Dump()
 	if err != nil {
 		t.Errorf("Error occurred...%s", err)
@@ -310,7 +346,7 @@ func TestLogLine(t *testing.T) {
 		Time:    time.Now().Add(time.Second * 3),
 		LogLine: logLineEnd,
 	}
-	l.Update(ll4)
+	updateLogLine(&l, ll4)
 	logLines, err = l.Dump()
 	if err != nil {
 		t.Errorf("Error occurred...%s", err)
@@ -338,13 +374,16 @@ func TestLogLine(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error occurred...%s", err)
 	}
+	lr, err := NewLogLineReader(gr)
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
 	// read past first log line to ensure we can seek ahead
-	buf := make([]byte, LogLineByteSize)
-	_, err = io.ReadFull(gr, buf)
+	_, err = lr.Read()
 	if err != nil {
 		t.Errorf("Error occurred...%s", err)
 	}
-	logLines, err = GetLogLinesFromReader(gr)
+	logLines, err = GetLogLinesFromReader(lr)
 	if len(logLines) != 2 {
 		t.Errorf("Log line save file has unexpected number of log lines.")
 	}
@@ -352,3 +391,1496 @@ func TestLogLine(t *testing.T) {
 		t.Errorf("Log lines in log line save file don't match.")
 	}
 }
+
+func TestLogLineFile(t *testing.T) {
+	l := NewLogLineManager()
+	defer l.Reset()
+	// lines longer than the legacy record size must not be dropped
+	longLine := logLineAttack + strings.Repeat(":0", LogLineByteSize)
+	start := time.Now()
+	lineCount := LogLineIndexBlockSize + 10
+	for i := 0; i < lineCount; i++ {
+		logLine := logLineBroil
+		if i == 1 {
+			logLine = longLine
+		}
+		updateLogLine(&l, data.LogLine{Time: start.Add(time.Duration(i) * time.Second), LogLine: logLine})
+	}
+	_, err := l.Dump()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	logLines, err := l.GetLogLines(1)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(logLines) == 0 || logLines[0].LogLine != longLine {
+		t.Errorf("Expected long log line to be read from dump file.")
+	}
+	if len(l.GetLogLineIndex()) != lineCount {
+		t.Errorf("Expected %d log lines in dump index.", lineCount)
+	}
+	// save and read back index
+	l.SetEncounterUID("TEST_E2")
+	l.SetSavePath(os.TempDir())
+	err = l.Save()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.Remove(GetLogFilePath(os.TempDir(), "TEST_E2"))
+	defer os.Remove(GetLogIndexFilePath(os.TempDir(), "TEST_E2"))
+	idxf, err := os.Open(GetLogIndexFilePath(os.TempDir(), "TEST_E2"))
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer idxf.Close()
+	index, err := ReadLogLineIndex(idxf)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(index) != lineCount {
+		t.Fatalf("Expected %d log lines in save index, got %d.", lineCount, len(index))
+	}
+	if !index[3].Time.Equal(start.Add(time.Second * 3)) {
+		t.Errorf("Log line index has unexpected time.")
+	}
+	// start decompressing at second block
+	entry := index[LogLineIndexBlockSize]
+	if entry.BlockOffset == index[0].BlockOffset {
+		t.Fatalf("Expected log lines to be saved in more than one block.")
+	}
+	f, err := os.Open(GetLogFilePath(os.TempDir(), "TEST_E2"))
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer f.Close()
+	_, err = f.Seek(entry.BlockOffset, 0)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	logLines, err = GetLogLinesFromReader(&LogLineReader{r: bufio.NewReader(gr)})
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(logLines) != lineCount-LogLineIndexBlockSize || !logLines[0].Time.Equal(entry.Time) {
+		t.Errorf("Log lines read from second block don't match.")
+	}
+}
+
+func TestLogLineRange(t *testing.T) {
+	l := NewLogLineManager()
+	defer l.Reset()
+	start := time.Now()
+	lineCount := LogLineIndexBlockSize*2 + 10
+	for i := 0; i < lineCount; i++ {
+		updateLogLine(&l, data.LogLine{Time: start.Add(time.Duration(i) * time.Second), LogLine: logLineBroil})
+	}
+	_, err := l.Dump()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	checkRange := func(name string, result LogLineRangeResult, offset int, count int) {
+		if result.Offset != offset || len(result.LogLines) != count || result.Total != lineCount {
+			t.Errorf("%s: expected offset %d with %d of %d log lines, got offset %d with %d of %d.", name, offset, count, lineCount, result.Offset, len(result.LogLines), result.Total)
+			return
+		}
+		if count > 0 && !result.LogLines[0].Time.Equal(start.Add(time.Duration(offset)*time.Second)) {
+			t.Errorf("%s: first log line has unexpected time.", name)
+		}
+	}
+	// time range within second block
+	timeRange := LogLineRange{
+		Start: start.Add(time.Duration(LogLineIndexBlockSize+5) * time.Second),
+		End:   start.Add(time.Duration(LogLineIndexBlockSize+34) * time.Second),
+	}
+	result, err := l.GetLogLineRange(timeRange)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	checkRange("dump time range", result, LogLineIndexBlockSize+5, 30)
+	// save and read from permanent log file
+	l.SetEncounterUID("TEST_E3")
+	l.SetSavePath(os.TempDir())
+	err = l.Save()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.Remove(GetLogFilePath(os.TempDir(), "TEST_E3"))
+	result, err = ReadLogLineRange(NewLocalBlobStorage(os.TempDir()), "TEST_E3", timeRange)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	checkRange("saved time range", result, LogLineIndexBlockSize+5, 30)
+	result, err = ReadLogLineRange(NewLocalBlobStorage(os.TempDir()), "TEST_E3", LogLineRange{Offset: lineCount - 5, Limit: 10})
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	checkRange("saved offset range", result, lineCount-5, 5)
+	result, err = ReadLogLineRange(NewLocalBlobStorage(os.TempDir()), "TEST_E3", LogLineRange{Offset: lineCount + 5})
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	checkRange("saved offset past end", result, lineCount, 0)
+	// log files without an index are read in full
+	os.Remove(GetLogIndexFilePath(os.TempDir(), "TEST_E3"))
+	result, err = ReadLogLineRange(NewLocalBlobStorage(os.TempDir()), "TEST_E3", timeRange)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	checkRange("saved time range without index", result, LogLineIndexBlockSize+5, 30)
+}
+
+func TestLogSearch(t *testing.T) {
+	savePath, err := ioutil.TempDir("", "fflp-search")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.RemoveAll(savePath)
+	// two encounters, each with three casts of broil, an auto attack and a gained effect
+	start := time.Now().Add(time.Second)
+	for _, encounterUID := range []string{"TEST_S1", "TEST_S2"} {
+		l := NewLogLineManager()
+		for i, logLine := range []string{logLineBroil, logLineAttack, logLineBroil, logLineGainEffect, logLineBroil} {
+			updateLogLine(&l, data.LogLine{Time: start.Add(time.Duration(i) * time.Second), LogLine: logLine})
+		}
+		l.SetEncounterUID(encounterUID)
+		l.SetSavePath(savePath)
+		_, err = l.Dump()
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+		err = l.Save()
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+		l.Reset()
+	}
+	encounterUIDs := []string{"TEST_S1", "TEST_MISSING", "TEST_S2"}
+	tests := []struct {
+		name    string
+		query   LogSearchQuery
+		matches int
+	}{
+		{"actor", LogSearchQuery{Actor: "minda silva"}, 8},
+		{"target", LogSearchQuery{Target: "Minda Silva"}, 2},
+		{"ability name", LogSearchQuery{Actor: "Rhitahtyn sas Arvina", Ability: "attack"}, 2},
+		{"ability id", LogSearchQuery{Ability: "409D"}, 6},
+		{"type", LogSearchQuery{Types: []int{LogTypeGainEffect}}, 2},
+		{"regex", LogSearchQuery{Regex: regexp.MustCompile(`Broil III:4000B744`)}, 6},
+		{"no match", LogSearchQuery{Actor: "Minda Silva", Target: "Minda Silva"}, 0},
+	}
+	for _, test := range tests {
+		result, err := SearchLogs(NewLocalBlobStorage(savePath), encounterUIDs, test.query)
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+		if len(result.Matches) != test.matches {
+			t.Errorf("%s: expected %d matches, got %d.", test.name, test.matches, len(result.Matches))
+		}
+	}
+	// paginate across encounters
+	query := LogSearchQuery{Ability: "Broil III", Offset: 2, Limit: 2}
+	result, err := SearchLogs(NewLocalBlobStorage(savePath), encounterUIDs, query)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(result.Matches) != 2 || !result.HasMore {
+		t.Fatalf("Expected a full page of matches with more to follow.")
+	}
+	if result.Matches[0].EncounterUID != "TEST_S1" || result.Matches[0].Offset != 4 {
+		t.Errorf("Unexpected first match on page, %s line %d.", result.Matches[0].EncounterUID, result.Matches[0].Offset)
+	}
+	if result.Matches[1].EncounterUID != "TEST_S2" || !result.Matches[1].Time.Equal(start) {
+		t.Errorf("Unexpected second match on page, %s at %s.", result.Matches[1].EncounterUID, result.Matches[1].Time)
+	}
+	query.Offset = 4
+	result, err = SearchLogs(NewLocalBlobStorage(savePath), encounterUIDs, query)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(result.Matches) != 2 || result.HasMore {
+		t.Errorf("Expected last page of matches.")
+	}
+}
+
+// runDatabaseTest - run database test against sqlite, and against postgres when
+// TEST_POSTGRES_DSN is set, each test gets its own schema in the postgres database
+func runDatabaseTest(t *testing.T, test func(t *testing.T, dialect string)) {
+	t.Run("sqlite3", func(t *testing.T) {
+		test(t, "sqlite3")
+	})
+	t.Run("postgres", func(t *testing.T) {
+		if os.Getenv("TEST_POSTGRES_DSN") == "" {
+			t.Skip("TEST_POSTGRES_DSN is not set, docker-compose.test.yml runs the tests with a postgres server.")
+		}
+		test(t, "postgres")
+	})
+}
+
+// openTestDatabaseHandler - open empty database, returns function that closes and removes it
+func openTestDatabaseHandler(t *testing.T, dialect string, savePath string) (DatabaseHandler, func()) {
+	if dialect == "sqlite3" {
+		d, err := openDatabaseHandler(dialect, path.Join(savePath, "test.db"))
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+		return d, func() { d.Close() }
+	}
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	admin, err := gorm.Open(dialect, dsn)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	schema := "fflp_test_" + xid.New().String()
+	res := admin.Exec("CREATE SCHEMA " + schema)
+	if res.Error != nil {
+		admin.Close()
+		t.Fatalf("Error occurred...%s", res.Error)
+	}
+	// unknown dsn parameters are set on the connection
+	switch {
+	case strings.Contains(dsn, "://") && strings.Contains(dsn, "?"):
+		dsn += "&search_path=" + schema
+	case strings.Contains(dsn, "://"):
+		dsn += "?search_path=" + schema
+	default:
+		dsn += " search_path=" + schema
+	}
+	d, err := openDatabaseHandler(dialect, dsn)
+	if err != nil {
+		admin.Close()
+		t.Fatalf("Error occurred...%s", err)
+	}
+	return d, func() {
+		d.Close()
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	}
+}
+
+// newTestDatabaseHandler - open empty database with all migrations applied, returns function that closes and removes it
+func newTestDatabaseHandler(t *testing.T, dialect string, savePath string) (DatabaseHandler, func()) {
+	d, closeDatabase := openTestDatabaseHandler(t, dialect, savePath)
+	err := d.Migrate(LatestSchemaVersion())
+	if err != nil {
+		closeDatabase()
+		t.Fatalf("Error occurred...%s", err)
+	}
+	return d, closeDatabase
+}
+
+func TestRetentionCleanUp(t *testing.T) {
+	runDatabaseTest(t, testRetentionCleanUp)
+}
+
+func testRetentionCleanUp(t *testing.T, dialect string) {
+	savePath, err := ioutil.TempDir("", "fflp-retention")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.RemoveAll(savePath)
+	storage := NewLocalBlobStorage(savePath)
+	defer SetBlobStorage(GetBlobStorage())
+	SetBlobStorage(storage)
+	d, closeDatabase := newTestDatabaseHandler(t, dialect, savePath)
+	defer closeDatabase()
+	// files are created now so run clean up far enough in the future for orphans to expire
+	now := time.Now().Add(30 * 24 * time.Hour)
+	days := func(n int) time.Time {
+		return now.Add(time.Duration(-n*24) * time.Hour)
+	}
+	err = d.StoreRetentionGroup(&data.RetentionGroup{Name: "forever", Days: -1})
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	err = d.StoreRetentionGroup(&data.RetentionGroup{Name: "short", Days: 3})
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	users := make([]data.User, 4)
+	for index := range users {
+		users[index] = data.NewUser()
+		err = d.StoreUser(&users[index])
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+	}
+	if err = d.SetUserRetentionDays(users[1].ID, 60); err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if err = d.SetUserRetentionGroup(users[2].ID, "forever"); err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if err = d.SetUserRetentionGroup(users[3].ID, "short"); err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	tests := []struct {
+		uid       string
+		user      data.User
+		startTime time.Time
+		pinned    bool
+		kept      bool
+	}{
+		{"TEST_R1", users[0], days(20), false, false},
+		{"TEST_R2", users[0], days(20), true, true},
+		{"TEST_R3", users[0], days(1), false, true},
+		{"TEST_R4", users[1], days(20), false, true},
+		{"TEST_R5", users[1], days(70), false, false},
+		{"TEST_R6", users[2], days(365), false, true},
+		{"TEST_R7", users[3], days(5), false, false},
+	}
+	for _, test := range tests {
+		encounter := data.Encounter{UID: test.uid, UserID: test.user.ID, StartTime: test.startTime, EndTime: test.startTime, Zone: "The Navel"}
+		err = d.StoreEncounter(&encounter)
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+		if test.pinned {
+			if err = d.SetEncounterPinned(test.uid, true); err != nil {
+				t.Fatalf("Error occurred...%s", err)
+			}
+		}
+		err = d.StoreCasts([]*data.Cast{{EncounterUID: test.uid, StartTime: test.startTime}})
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+		for _, key := range []string{GetLogFileKey(test.uid), GetPositionFileKey(test.uid)} {
+			w, err := storage.Create(key)
+			if err != nil {
+				t.Fatalf("Error occurred...%s", err)
+			}
+			w.Close()
+		}
+	}
+	// file without an encounter
+	w, err := storage.Create(GetLogFileKey("TEST_ORPHAN"))
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	w.Close()
+	_, err = d.CleanUp(now)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	for _, test := range tests {
+		_, err := d.FetchEncounter(test.uid)
+		if (err == nil) != test.kept {
+			t.Errorf("%s: expected kept=%t, got kept=%t.", test.uid, test.kept, err == nil)
+		}
+		casts, err := d.FetchCastsForEncounter(test.uid)
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+		if (len(casts) > 0) != test.kept {
+			t.Errorf("%s: expected casts kept=%t.", test.uid, test.kept)
+		}
+		for _, key := range []string{GetLogFileKey(test.uid), GetPositionFileKey(test.uid)} {
+			_, err := storage.Stat(key)
+			if (err == nil) != test.kept {
+				t.Errorf("%s: expected file kept=%t.", key, test.kept)
+			}
+		}
+	}
+	if _, err = storage.Stat(GetLogFileKey("TEST_ORPHAN")); err != ErrBlobNotExist {
+		t.Errorf("Expected orphaned log file to be removed.")
+	}
+}
+
+func TestStorageCheck(t *testing.T) {
+	runDatabaseTest(t, testStorageCheck)
+}
+
+func testStorageCheck(t *testing.T, dialect string) {
+	savePath, err := ioutil.TempDir("", "fflp-check")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.RemoveAll(savePath)
+	storage := NewLocalBlobStorage(savePath)
+	d, closeDatabase := newTestDatabaseHandler(t, dialect, savePath)
+	defer closeDatabase()
+	// files are created now so check far enough in the future for them to be settled
+	now := time.Now().Add(time.Hour)
+	start := time.Now().Add(time.Second)
+	// encounter with log spanning several blocks but no index
+	l := NewLogLineManager()
+	for i := 0; i < LogLineIndexBlockSize*2+10; i++ {
+		updateLogLine(&l, data.LogLine{Time: start.Add(time.Duration(i) * time.Millisecond), LogLine: logLineBroil})
+	}
+	l.SetEncounterUID("TEST_C1")
+	l.SetStorage(storage)
+	_, err = l.Dump()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	err = l.Save()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	l.Reset()
+	r, err := storage.Open(GetLogIndexFileKey("TEST_C1"), 0)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	index, err := ReadLogLineIndex(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	storage.Delete(GetLogIndexFileKey("TEST_C1"))
+	// encounter without log, orphaned file and orphaned rows
+	for _, encounterUID := range []string{"TEST_C1", "TEST_C2"} {
+		encounter := data.Encounter{UID: encounterUID, StartTime: start, EndTime: start, Zone: "The Navel"}
+		err = d.StoreEncounter(&encounter)
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+	}
+	w, err := storage.Create(GetPositionFileKey("TEST_GONE"))
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	w.Close()
+	err = d.StoreCasts([]*data.Cast{{EncounterUID: "TEST_C1"}, {EncounterUID: "TEST_GONE"}})
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	err = d.StoreDeathRecaps([]*data.DeathRecap{{EncounterUID: "TEST_GONE", Events: []data.DeathRecapEvent{{}, {}}}})
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	// files written within the grace period may still be being written
+	report, err := d.CheckStorage(storage, StorageCheckOptions{}, time.Now())
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(report.OrphanedFiles) != 0 || len(report.MissingIndexFiles) != 0 {
+		t.Errorf("Expected recently written files to be left alone.")
+	}
+	// report only
+	report, err = d.CheckStorage(storage, StorageCheckOptions{}, now)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(report.OrphanedFiles) != 1 || report.OrphanedFiles[0] != GetPositionFileKey("TEST_GONE") {
+		t.Errorf("Unexpected orphaned files %v.", report.OrphanedFiles)
+	}
+	if len(report.MissingLogFiles) != 1 || report.MissingLogFiles[0] != "TEST_C2" {
+		t.Errorf("Unexpected missing log files %v.", report.MissingLogFiles)
+	}
+	if len(report.MissingIndexFiles) != 1 || report.MissingIndexFiles[0] != GetLogIndexFileKey("TEST_C1") {
+		t.Errorf("Unexpected missing index files %v.", report.MissingIndexFiles)
+	}
+	if report.OrphanedRows["casts"] != 1 || report.OrphanedRows["death_recaps"] != 1 || report.OrphanedRows["death_recap_events"] != 2 {
+		t.Errorf("Unexpected orphaned rows %v.", report.OrphanedRows)
+	}
+	if len(report.Unaccounted) != 2 {
+		t.Errorf("Unexpected encounters without storage usage %v.", report.Unaccounted)
+	}
+	if _, err = storage.Stat(GetPositionFileKey("TEST_GONE")); err != nil {
+		t.Errorf("Expected report only check to keep files.")
+	}
+	// repair
+	_, err = d.CheckStorage(storage, StorageCheckOptions{Repair: true, DeleteMissing: true}, now)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	report, err = d.CheckStorage(storage, StorageCheckOptions{}, now)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if !report.IsClean() {
+		t.Errorf("Expected no problems after repair, got %+v.", report)
+	}
+	if _, err = d.FetchEncounter("TEST_C2"); err == nil {
+		t.Errorf("Expected encounter with missing log to be deleted.")
+	}
+	casts, err := d.FetchCastsForEncounter("TEST_C1")
+	if err != nil || len(casts) != 1 {
+		t.Errorf("Expected casts of encounter to be kept.")
+	}
+	// rebuilt index matches the one written on save
+	r, err = storage.Open(GetLogIndexFileKey("TEST_C1"), 0)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	rebuiltIndex, err := ReadLogLineIndex(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(rebuiltIndex) != len(index) {
+		t.Fatalf("Expected rebuilt index with %d entries, got %d.", len(index), len(rebuiltIndex))
+	}
+	for i := range index {
+		if !rebuiltIndex[i].Time.Equal(index[i].Time) || rebuiltIndex[i].Offset != index[i].Offset || rebuiltIndex[i].BlockOffset != index[i].BlockOffset {
+			t.Fatalf("Rebuilt index entry %d doesn't match, %+v != %+v.", i, rebuiltIndex[i], index[i])
+		}
+	}
+}
+
+func TestStorageQuota(t *testing.T) {
+	runDatabaseTest(t, testStorageQuota)
+}
+
+func testStorageQuota(t *testing.T, dialect string) {
+	savePath, err := ioutil.TempDir("", "fflp-quota")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.RemoveAll(savePath)
+	defer SetBlobStorage(GetBlobStorage())
+	SetBlobStorage(NewLocalBlobStorage(savePath))
+	d, closeDatabase := newTestDatabaseHandler(t, dialect, savePath)
+	defer closeDatabase()
+	user := data.NewUser()
+	err = d.StoreUser(&user)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	err = d.SetUserQuotaEncounters(user.ID, 2)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	// save encounters until quota is reached
+	saveEncounterTo := func(database *DatabaseHandler) error {
+		e := NewEncounterManager(database, user)
+		start := time.Now()
+		e.encounter.StartTime = start
+		e.encounter.EndTime = start.Add(time.Minute)
+		e.CastManager.SetCasts([]data.Cast{{StartTime: start}})
+		updateLogLine(&e.LogLineManager, data.LogLine{Time: start, LogLine: logLineCastStart})
+		_, err := e.LogLineManager.Dump()
+		if err != nil {
+			return err
+		}
+		return e.Save()
+	}
+	saveEncounter := func() error {
+		return saveEncounterTo(&d)
+	}
+	for i := 0; i < 2; i++ {
+		err = saveEncounter()
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+	}
+	usage, err := d.FetchUserStorageUsage(user.ID)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if usage.Encounters != 2 || usage.Rows < 4 || usage.Bytes == 0 {
+		t.Errorf("Unexpected storage usage %+v.", usage)
+	}
+	if !usage.IsOverQuota() {
+		t.Errorf("Expected user to be over quota.")
+	}
+	if err = saveEncounter(); err != ErrQuotaExceeded {
+		t.Errorf("Expected encounter over quota to not be saved, got %v.", err)
+	}
+	// lifting quota allows saving again
+	err = d.SetUserQuotaEncounters(user.ID, -1)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if err = saveEncounter(); err != nil {
+		t.Errorf("Expected encounter to be saved with unlimited quota, got %v.", err)
+	}
+	// encounter that doesn't fit in the bytes left is not saved even while under quota
+	usage, err = d.FetchUserStorageUsage(user.ID)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	err = d.SetUserQuotaBytes(user.ID, usage.Bytes+1)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if err = saveEncounter(); err != ErrQuotaExceeded {
+		t.Errorf("Expected encounter larger than bytes left to not be saved, got %v.", err)
+	}
+	// ending an encounter over quota flags it for the session
+	e := NewEncounterManager(&d, user)
+	start := time.Now()
+	e.encounter.Active = true
+	e.encounter.StartTime = start
+	e.encounter.EndTime = start.Add(time.Minute)
+	updateLogLine(&e.LogLineManager, data.LogLine{Time: start, LogLine: logLineCastStart})
+	if _, err = e.LogLineManager.Dump(); err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	e.End(EncounterSuccessEnd)
+	if !e.IsQuotaExceeded() {
+		t.Errorf("Expected encounter ended over quota to be flagged.")
+	}
+	e.Reset()
+	if e.IsQuotaExceeded() {
+		t.Errorf("Expected quota flag to be cleared for new encounter.")
+	}
+	err = d.SetUserQuotaBytes(user.ID, -1)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	// concurrent saves can't go over quota, on postgres each save gets its own handler
+	// like separate servers sharing the database, sqlite only supports one server
+	err = d.SetUserQuotaEncounters(user.ID, 5)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	saveErrors := make(chan error, 4)
+	for i := 0; i < cap(saveErrors); i++ {
+		database := &d
+		if dialect != "sqlite3" {
+			database = &DatabaseHandler{conn: d.conn, log: d.log, lock: &sync.Mutex{}}
+		}
+		go func() {
+			saveErrors <- saveEncounterTo(database)
+		}()
+	}
+	saved := 0
+	for i := 0; i < cap(saveErrors); i++ {
+		err = <-saveErrors
+		if err == nil {
+			saved++
+		} else if err != ErrQuotaExceeded {
+			t.Errorf("Error occurred...%s", err)
+		}
+	}
+	usage, err = d.FetchUserStorageUsage(user.ID)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if saved != 2 || usage.Encounters != 5 {
+		t.Errorf("Expected concurrent saves to stop at quota, %d saved and %d stored.", saved, usage.Encounters)
+	}
+	// nothing is stored when a transaction fails
+	err = d.Transaction(func(tx *DatabaseHandler) error {
+		err := tx.StoreEncounter(&data.Encounter{UID: "TEST_ROLLBACK", UserID: user.ID, CompareHash: "TEST"})
+		if err != nil {
+			return err
+		}
+		return ErrQuotaExceeded
+	})
+	if err != ErrQuotaExceeded {
+		t.Errorf("Expected transaction error to be returned, got %v.", err)
+	}
+	if _, err = d.FetchEncounter("TEST_ROLLBACK"); err == nil {
+		t.Errorf("Expected encounter stored in failed transaction to be rolled back.")
+	}
+}
+
+func TestLogExport(t *testing.T) {
+	logTime := time.Date(2019, 8, 4, 18, 2, 31, 874000000, time.UTC)
+	tests := []struct {
+		name     string
+		logLine  string
+		loc      *time.Location
+		expected string
+	}{
+		{"colon", logLineDefeat, nil, "[18:02:31.874] 19:Rhitahtyn Sas Arvina was defeated by Minda Silva."},
+		{"colon time zone", logLineDefeat, time.FixedZone("", -7*3600), "[11:02:31.874] 19:Rhitahtyn Sas Arvina was defeated by Minda Silva."},
+		{"pipe", logLinePipeDefeat, nil, "[18:02:31.874] 19:4000B744:Rhitahtyn sas Arvina:106CB0ED:Minda Silva"},
+		{"pipe effect", logLinePipeGainEffect, nil, "[18:02:31.874] 1A:4C5:Chain Stratagem:15.00:106CB0ED:Minda Silva:4000B744:Rhitahtyn sas Arvina:00:85041:140279"},
+	}
+	for _, test := range tests {
+		exported := ExportLogLine(data.LogLine{Time: logTime, LogLine: test.logLine}, test.loc)
+		if exported != test.expected {
+			t.Errorf("%s: expected '%s', got '%s'.", test.name, test.expected, exported)
+		}
+		// exported log lines must parse the same as the original
+		original, err := ParseLogLine(data.LogLine{Time: logTime, LogLine: test.logLine})
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+		reparsed, err := ParseLogLine(data.LogLine{Time: logTime, LogLine: exported})
+		if err != nil {
+			t.Fatalf("Error occurred...%s", err)
+		}
+		if reparsed.Type != original.Type || reparsed.TargetName != original.TargetName || reparsed.AttackerName != original.AttackerName {
+			t.Errorf("%s: exported log line does not parse the same as the original.", test.name)
+		}
+	}
+	// export time window of saved log
+	l := NewLogLineManager()
+	defer l.Reset()
+	start := time.Now().Add(time.Second)
+	for i := 0; i < 10; i++ {
+		updateLogLine(&l, data.LogLine{Time: start.Add(time.Duration(i) * time.Second), LogLine: logLineBroil})
+	}
+	l.SetEncounterUID("TEST_X1")
+	l.SetSavePath(os.TempDir())
+	_, err := l.Dump()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	err = l.Save()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.Remove(GetLogFilePath(os.TempDir(), "TEST_X1"))
+	defer os.Remove(GetLogIndexFilePath(os.TempDir(), "TEST_X1"))
+	var buf bytes.Buffer
+	err = ExportLog(&buf, NewLocalBlobStorage(os.TempDir()), "TEST_X1", LogLineRange{Start: start.Add(time.Second * 2), End: start.Add(time.Second * 5)}, time.UTC)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 exported log lines, got %d.", len(lines))
+	}
+	if lines[0] != ExportLogLine(data.LogLine{Time: start.Add(time.Second * 2), LogLine: logLineBroil}, time.UTC) {
+		t.Errorf("Unexpected first exported log line '%s'.", lines[0])
+	}
+	// log file without an index exports the same log lines
+	os.Remove(GetLogIndexFilePath(os.TempDir(), "TEST_X1"))
+	var bufNoIndex bytes.Buffer
+	err = ExportLog(&bufNoIndex, NewLocalBlobStorage(os.TempDir()), "TEST_X1", LogLineRange{Start: start.Add(time.Second * 2), End: start.Add(time.Second * 5)}, time.UTC)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if bufNoIndex.String() != buf.String() {
+		t.Errorf("Expected log file without index to export the same log lines.")
+	}
+}
+
+func TestLogLineLegacyFile(t *testing.T) {
+	// legacy log files contain log lines padded to a fixed size with no header
+	ll1 := data.LogLine{Time: time.Now(), LogLine: logLineBroil}
+	ll2 := data.LogLine{Time: time.Now().Add(time.Second), LogLine: logLineDefeat}
+	legacyBytes := make([]byte, 0)
+	for _, logLine := range []data.LogLine{ll1, ll2} {
+		logLineBytes := logLine.ToBytes()
+		for len(logLineBytes) < LogLineByteSize {
+			logLineBytes = append(logLineBytes, 0)
+		}
+		legacyBytes = append(legacyBytes, logLineBytes...)
+	}
+	lr, err := NewLogLineReader(bytes.NewReader(legacyBytes))
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if !lr.Legacy {
+		t.Errorf("Expected legacy log file to be detected.")
+	}
+	logLines, err := GetLogLinesFromReader(lr)
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if len(logLines) != 2 || logLines[0].LogLine != ll1.LogLine || logLines[1].LogLine != ll2.LogLine {
+		t.Errorf("Log lines in legacy log file don't match.")
+	}
+	// truncated legacy record
+	lr, _ = NewLogLineReader(bytes.NewReader(legacyBytes[:LogLineByteSize+10]))
+	_, err = GetLogLinesFromReader(lr)
+	if err == nil {
+		t.Errorf("Expected error reading truncated legacy log file.")
+	}
+}
+
+func TestParseEffect(t *testing.T) {
+	l, err := ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineGainEffect})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if l.AbilityID != 0x4C5 || l.AbilityName != "Chain Stratagem" {
+		t.Errorf("Unexpected effect '%s' (%d).", l.AbilityName, l.AbilityID)
+	}
+	if l.AttackerID != 0x106CB0ED || l.AttackerName != "Minda Silva" {
+		t.Errorf("Unexpected effect source '%s.'", l.AttackerName)
+	}
+	if l.TargetID != 0x4000B744 || l.TargetName != "Rhitahtyn sas Arvina" {
+		t.Errorf("Unexpected effect target '%s.'", l.TargetName)
+	}
+	if l.Duration != 15*time.Second {
+		t.Errorf("Expected effect duration of 15 seconds, got %s.", l.Duration)
+	}
+	if len(l.Flags) > 0 {
+		t.Errorf("Effect log line should not have any flags.")
+	}
+	l, err = ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineGainEffectText})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if l.AbilityName != "Galvanize" || l.AttackerName != "Minda Silva" || l.TargetName != "Minda Silva" || l.Duration != 30*time.Second {
+		t.Errorf("Unexpected values when parsing text effect log line.")
+	}
+}
+
+func TestEffectTimeline(t *testing.T) {
+	e := NewEffectManager()
+	startTime := time.Now()
+	llGain, _ := ParseLogLine(data.LogLine{Time: startTime, LogLine: logLineGainEffect})
+	e.ReadLogLine(&llGain)
+	if len(e.GetActiveEffects(startTime.Add(time.Second))) != 1 {
+		t.Errorf("Expected one active effect after gain effect.")
+	}
+	if len(e.GetActiveEffectsOnTarget(0x4000B744, startTime.Add(time.Second))) != 1 {
+		t.Errorf("Expected one active effect on target after gain effect.")
+	}
+	// effect should expire after its duration
+	if len(e.GetActiveEffects(startTime.Add(time.Second*16))) != 0 {
+		t.Errorf("Expected no active effects after effect duration.")
+	}
+	// reapply refreshes the existing effect
+	llGain.Time = startTime.Add(time.Second * 5)
+	e.ReadLogLine(&llGain)
+	if len(e.GetEffects()) != 1 {
+		t.Errorf("Expected reapplied effect to refresh existing effect.")
+	}
+	llLose, _ := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second * 8), LogLine: logLineLoseEffect})
+	e.ReadLogLine(&llLose)
+	if len(e.GetActiveEffects(startTime.Add(time.Second*9))) != 0 {
+		t.Errorf("Expected no active effects after lose effect.")
+	}
+	if len(e.GetActiveEffects(startTime.Add(time.Second*7))) != 1 {
+		t.Errorf("Expected effect to remain in timeline after lose effect.")
+	}
+}
+
+func TestDotTickAttribution(t *testing.T) {
+	e := NewEncounterManager(nil, data.User{})
+	startTime := time.Now()
+	llAtk, _ := ParseLogLine(data.LogLine{Time: startTime, LogLine: logLineBroil})
+	e.ReadLogLine(&llAtk)
+	e.CombatantManager.Update(data.Combatant{
+		Player:         data.Player{ID: 0x106CB0ED, ActName: "Minda Silva", Name: "Minda Silva"},
+		ActEncounterID: 1,
+		Job:            "Sch",
+		Time:           time.Now().Add(time.Second),
+	})
+	llGain, _ := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second), LogLine: logLineGainDot})
+	e.ReadLogLine(&llGain)
+	llTick, err := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second * 3), LogLine: logLineDotTick})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if llTick.AbilityID != 0x767 || llTick.Damage != 0x1F4A || !llTick.HasFlag(LogFlagDamage) {
+		t.Errorf("Unexpected values when parsing dot tick log line.")
+	}
+	if llTick.TargetCurrentHP != 85041 {
+		t.Errorf("Expected dot tick target hp to be 85041, got %d.", llTick.TargetCurrentHP)
+	}
+	e.ReadLogLine(&llTick)
+	if llTick.AttackerID != 0x106CB0ED {
+		t.Errorf("Expected dot tick to be attributed to effect source.")
+	}
+	combatants := e.CombatantManager.GetLastCombatants()
+	if len(combatants) != 1 || combatants[0].DotDamage != 0x1F4A {
+		t.Errorf("Expected combatant to have dot tick damage.")
+	}
+}
+
+func TestDotTickAmbiguousSource(t *testing.T) {
+	e := NewEffectManager()
+	startTime := time.Now()
+	llGain, _ := ParseLogLine(data.LogLine{Time: startTime, LogLine: logLineGainDot})
+	e.ReadLogLine(&llGain)
+	llTick, _ := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second * 3), LogLine: logLineDotTick})
+	effect := e.GetTickSource(&llTick)
+	if effect == nil || effect.SourceID != 0x106CB0ED {
+		t.Errorf("Expected dot tick with one effect source to be attributed.")
+	}
+	// same dot applied to the target by a second source
+	llGainOther, _ := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second), LogLine: logLineGainDot})
+	llGainOther.AttackerID = 0x106CB0EE
+	llGainOther.AttackerName = "Other Scholar"
+	e.ReadLogLine(&llGainOther)
+	if e.GetTickSource(&llTick) != nil {
+		t.Errorf("Expected dot tick with two effect sources to be unattributed.")
+	}
+	// once the other effect is gone the tick can be attributed again
+	llTick.Time = startTime.Add(time.Millisecond * 30500)
+	if effect := e.GetTickSource(&llTick); effect == nil || effect.SourceID != 0x106CB0EE {
+		t.Errorf("Expected dot tick to be attributed to remaining effect source.")
+	}
+}
+
+func TestParseDotTickText(t *testing.T) {
+	ll, err := ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineDotTickText})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if ll.Type != LogTypeDot || ll.TargetName != "Striking Dummy" || ll.Damage != 1234 || !ll.HasFlag(LogFlagDamage) {
+		t.Errorf("Unexpected values when parsing text dot tick log line.")
+	}
+	ll, err = ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineHotTickText})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if ll.TargetName != "Minda Silva" || ll.Damage != 520 || !ll.HasFlag(LogFlagHeal) {
+		t.Errorf("Unexpected values when parsing text hot tick log line.")
+	}
+}
+
+func TestCastTimeline(t *testing.T) {
+	c := NewCastManager()
+	startTime := time.Now()
+	llCast, err := ParseLogLine(data.LogLine{Time: startTime, LogLine: logLineCastStart})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if llCast.AbilityID != 0x1D3C || llCast.TargetName != "Minda Silva" || llCast.Duration != 2700*time.Millisecond {
+		t.Errorf("Unexpected values when parsing cast start log line.")
+	}
+	// cast completes when ability is used
+	c.ReadLogLine(&llCast)
+	llComplete, _ := ParseLogLine(data.LogLine{Time: startTime.Add(2700 * time.Millisecond), LogLine: logLineCastComplete})
+	c.ReadLogLine(&llComplete)
+	// cast is interrupted
+	llCast.Time = startTime.Add(time.Second * 5)
+	c.ReadLogLine(&llCast)
+	llInterrupt, _ := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second * 6), LogLine: logLineCastInterrupt})
+	if !llInterrupt.HasFlag(LogFlagInterrupted) {
+		t.Errorf("Expected interrupted flag on cast cancel log line.")
+	}
+	c.ReadLogLine(&llInterrupt)
+	casts := c.GetCasts()
+	if len(casts) != 2 {
+		t.Fatalf("Expected two casts in cast timeline.")
+	}
+	if casts[0].Status != data.CastStatusComplete || casts[1].Status != data.CastStatusInterrupted {
+		t.Errorf("Unexpected cast status in cast timeline.")
+	}
+	if len(c.Dump()) != 4 || len(c.Dump()) != 0 {
+		t.Errorf("Expected dump to return each cast update once.")
+	}
+}
+
+func TestDeathRecap(t *testing.T) {
+	d := NewDeathRecapManager()
+	startTime := time.Now()
+	// old event outside of recap window
+	llOld, _ := ParseLogLine(data.LogLine{Time: startTime.Add(-time.Minute), LogLine: logLineAttack})
+	d.ReadLogLine(&llOld)
+	llAttack, err := ParseLogLine(data.LogLine{Time: startTime, LogLine: logLineAttack})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if llAttack.TargetCurrentHP != 85041 || llAttack.TargetMaxHP != 85041 {
+		t.Errorf("Unexpected target hp values when parsing log line.")
+	}
+	d.ReadLogLine(&llAttack)
+	// enemy deaths should not produce a recap
+	llEnemyDefeat, _ := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second), LogLine: logLineDefeat})
+	d.ReadLogLine(&llEnemyDefeat)
+	llDefeat, _ := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second), LogLine: logLinePlayerDefeat})
+	d.ReadLogLine(&llDefeat)
+	recaps := d.GetDeathRecaps()
+	if len(recaps) != 1 {
+		t.Fatalf("Expected one death recap.")
+	}
+	if recaps[0].TargetID != 0x106CB0ED || len(recaps[0].Events) != 1 {
+		t.Errorf("Unexpected values in death recap.")
+	}
+	killingBlow := recaps[0].GetKillingBlow()
+	if killingBlow == nil || killingBlow.SourceName != "Rhitahtyn sas Arvina" || killingBlow.HPBefore != 85041 {
+		t.Errorf("Unexpected killing blow in death recap.")
+	}
+	if len(d.Dump()) != 1 || len(d.Dump()) != 0 {
+		t.Errorf("Expected dump to return each death recap once.")
+	}
+}
+
+func TestAbilityStats(t *testing.T) {
+	a := NewAbilityStatManager()
+	llBroil, err := ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineBroil})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	a.ReadLogLine(&llBroil)
+	a.ReadLogLine(&llBroil)
+	// enemy abilities are not tracked
+	llAttack, _ := ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineAttack})
+	a.ReadLogLine(&llAttack)
+	stats := a.GetAbilityStats()
+	if len(stats) != 1 {
+		t.Fatalf("Expected one ability stat.")
+	}
+	if stats[0].AbilityName != "Broil III" || stats[0].Hits != 2 || stats[0].Total != int32(llBroil.Damage*2) || stats[0].MaxHit != int32(llBroil.Damage) {
+		t.Errorf("Unexpected values in ability stat.")
+	}
+	if len(a.GetAbilityStatsForCombatant(0x106CB0ED)) != 1 || len(a.GetAbilityStatsForCombatant(0x4000B744)) != 0 {
+		t.Errorf("Unexpected ability stats for combatant.")
+	}
+	if len(a.Dump()) != 1 || len(a.Dump()) != 0 {
+		t.Errorf("Expected dump to return each updated ability stat once.")
+	}
+}
+
+func TestTimeSeries(t *testing.T) {
+	ts := NewTimeSeriesManager()
+	startTime := time.Now()
+	llBroil, err := ParseLogLine(data.LogLine{Time: startTime, LogLine: logLineBroil})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	ts.ReadLogLine(&llBroil)
+	llBroil.Time = startTime.Add(2500 * time.Millisecond)
+	ts.ReadLogLine(&llBroil)
+	series := ts.GetTimeSeries()
+	if len(series) != 1 || len(series[0].Damage) != 3 {
+		t.Fatalf("Expected one time series with three buckets.")
+	}
+	if series[0].Damage[0] != int32(llBroil.Damage) || series[0].Damage[1] != 0 || series[0].Damage[2] != int32(llBroil.Damage) {
+		t.Errorf("Unexpected values in time series buckets.")
+	}
+	// dump only contains buckets updated since last dump
+	ts.Dump()
+	llBroil.Time = startTime.Add(3500 * time.Millisecond)
+	ts.ReadLogLine(&llBroil)
+	dump := ts.Dump()
+	if len(dump) != 1 || dump[0].Offset != 3 || len(dump[0].Damage) != 1 {
+		t.Errorf("Unexpected time series dump.")
+	}
+	// pack/unpack for storage
+	series = ts.GetTimeSeries()
+	series[0].BeforeSave()
+	unpacked := data.TimeSeries{Buckets: series[0].Buckets}
+	if err := unpacked.AfterFind(); err != nil || len(unpacked.Damage) != 4 || unpacked.Damage[3] != int32(llBroil.Damage) {
+		t.Errorf("Unexpected values after unpacking time series buckets.")
+	}
+}
+
+func TestRaidBuffContribution(t *testing.T) {
+	e := NewEffectManager()
+	c := NewContributionManager()
+	startTime := time.Now()
+	// scholar applies chain stratagem, scholar's own damage is not credited
+	llChain, _ := ParseLogLine(data.LogLine{Time: startTime, LogLine: logLineGainEffect})
+	e.ReadLogLine(&llChain)
+	llBroil, _ := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second), LogLine: logLineBroil})
+	c.ReadLogLine(&llBroil, &e)
+	contributions := c.GetContributions()
+	if len(contributions) != 1 || contributions[0].DamageReceived != 0 || contributions[0].DamageGiven != 0 {
+		t.Fatalf("Expected no contribution from self applied raid buff.")
+	}
+	// another player's damage on the debuffed target is credited back to the scholar
+	llOther := llBroil
+	llOther.AttackerID = 0x106CB0EE
+	llOther.AttackerName = "Other Player"
+	c.ReadLogLine(&llOther, &e)
+	contributions = c.GetContributions()
+	if len(contributions) != 2 {
+		t.Fatalf("Expected two contributions.")
+	}
+	expected := int64(math.Round(float64(llOther.Damage) - float64(llOther.Damage)/1.05))
+	if contributions[1].DamageReceived != expected || contributions[0].DamageGiven != expected {
+		t.Errorf("Unexpected raid buff contribution values.")
+	}
+	if contributions[1].AdjustedDamage() != int64(llOther.Damage)-expected {
+		t.Errorf("Unexpected adjusted damage.")
+	}
+	// chain stratagem is a crit rate buff so its contribution is an estimate
+	if !contributions[0].Approximate || !contributions[1].Approximate {
+		t.Errorf("Expected crit rate buff contribution to be flagged approximate.")
+	}
+	// pet of a player with no damage of their own is merged in to a row named after the owner
+	llPet := llBroil
+	llPet.AttackerID = 0x40001234
+	llPet.AttackerName = "Eos"
+	llPet.AttackerOwnerID = 0x106CB0EF
+	llPet.AttackerOwnerName = "Pet Owner"
+	c.ReadLogLine(&llPet, &e)
+	merged := MergePetContributions(c.GetContributions())
+	if len(merged) != 3 || merged[2].CombatantID != 0x106CB0EF || merged[2].CombatantName != "Pet Owner" || merged[2].OwnerID != 0 {
+		t.Errorf("Expected pet contribution to be credited to its named owner.")
+	}
+}
+
+func TestMovementTrack(t *testing.T) {
+	p := NewPositionManager()
+	p.SetSavePath(os.TempDir())
+	p.ResetEncounter(data.Encounter{UID: "TEST_POSITION"})
+	startTime := time.Now()
+	llAttack, err := ParseLogLine(data.LogLine{Time: startTime, LogLine: logLineAttack})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if llAttack.AttackerPosition == nil || llAttack.AttackerPosition.X != -701.6327 || llAttack.TargetPosition == nil || llAttack.TargetPosition.Heading != -2.090146 {
+		t.Fatalf("Unexpected positions when parsing log line.")
+	}
+	p.ReadLogLine(&llAttack)
+	// sample skipped, not enough time has passed
+	llAttack.Time = startTime.Add(100 * time.Millisecond)
+	p.ReadLogLine(&llAttack)
+	llAttack.Time = startTime.Add(time.Second)
+	p.ReadLogLine(&llAttack)
+	tracks := p.GetTracks()
+	if len(tracks) != 2 || len(tracks[0].Samples) != 2 {
+		t.Fatalf("Expected two movement tracks with two samples each.")
+	}
+	// save and load
+	err = p.Save()
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.Remove(GetPositionFilePath(os.TempDir(), "TEST_POSITION"))
+	p2 := NewPositionManager()
+	p2.SetSavePath(os.TempDir())
+	err = p2.Load("TEST_POSITION")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	tracks = p2.GetTracks()
+	if len(tracks) != 2 || len(tracks[1].Samples) != 2 || tracks[1].Samples[1].Time.Sub(tracks[1].StartTime) != time.Second {
+		t.Errorf("Unexpected movement tracks after load.")
+	}
+}
+
+func TestPetOwner(t *testing.T) {
+	o := NewOwnerManager()
+	a := NewAbilityStatManager()
+	llAddPet, err := ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineAddPet})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if llAddPet.TargetID != 0x40016A8B || llAddPet.TargetName != "Eos" || llAddPet.AttackerID != 0x106CB0ED {
+		t.Errorf("Unexpected values when parsing add combatant log line.")
+	}
+	llAddPetText, _ := ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineAddPetText})
+	if llAddPetText.TargetID != 0x40016A8B || llAddPetText.TargetName != "Eos" || llAddPetText.AttackerID != 0x106CB0ED {
+		t.Errorf("Unexpected values when parsing add combatant text log line.")
+	}
+	llBroil, _ := ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineBroil})
+	o.ReadLogLine(&llBroil)
+	o.ReadLogLine(&llAddPet)
+	// pet uses broil
+	llPet := llBroil
+	llPet.AttackerID = 0x40016A8B
+	llPet.AttackerName = "Eos"
+	o.Attribute(&llPet)
+	if llPet.AttackerOwnerID != 0x106CB0ED || llPet.AttackerOwnerName != "Minda Silva" {
+		t.Fatalf("Expected pet to be attributed to owner.")
+	}
+	a.ReadLogLine(&llBroil)
+	a.ReadLogLine(&llPet)
+	// pets kept as separate rows
+	stats := a.GetAbilityStats()
+	if len(stats) != 2 || stats[1].OwnerID != 0x106CB0ED {
+		t.Fatalf("Expected pet ability stats as separate row.")
+	}
+	// merged in to owner
+	stats = MergePetAbilityStats(stats)
+	if len(stats) != 1 || stats[0].Hits != 2 || stats[0].CombatantName != "Minda Silva" {
+		t.Errorf("Expected pet ability stats to merge in to owner.")
+	}
+	if len(a.GetAbilityStatsForCombatant(0x106CB0ED)) != 2 {
+		t.Errorf("Expected combatant ability stats to include pet.")
+	}
+}
+
+func TestEnemyRoster(t *testing.T) {
+	o := NewOwnerManager()
+	e := NewEnemyManager()
+	startTime := time.Now()
+	llAttack, _ := ParseLogLine(data.LogLine{Time: startTime, LogLine: logLineAttack})
+	e.ReadLogLine(&llAttack, &o)
+	llBroil, _ := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second), LogLine: logLineBroil})
+	e.ReadLogLine(&llBroil, &o)
+	e.ReadLogLine(&llBroil, &o)
+	llDefeat, _ := ParseLogLine(data.LogLine{Time: startTime.Add(time.Second * 2), LogLine: logLineDefeat})
+	e.ReadLogLine(&llDefeat, &o)
+	enemies := e.GetEnemies()
+	if len(enemies) != 1 {
+		t.Fatalf("Expected one enemy.")
+	}
+	enemy := enemies[0]
+	if enemy.CombatantID != 0x4000B744 || enemy.MaxHP != 140279 || !enemy.FirstSeen.Equal(startTime) {
+		t.Errorf("Unexpected enemy values.")
+	}
+	if !enemy.DefeatedTime.Equal(startTime.Add(time.Second*2)) || enemy.DamageTaken != int64(llBroil.Damage*2) {
+		t.Errorf("Unexpected enemy defeat time or damage taken.")
+	}
+	if len(enemy.DamageSources) != 1 || enemy.DamageSources[0].SourceName != "Minda Silva" {
+		t.Errorf("Unexpected enemy damage sources.")
+	}
+}
+
+func TestParseDecimalHP(t *testing.T) {
+	// act writes hp values in decimal, unlike ids and damage which are hex
+	l, err := ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineBroil})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if l.TargetCurrentHP != 109947 || l.TargetMaxHP != 140279 || l.AttackerCurrentHP != 85041 || l.AttackerMaxHP != 85041 {
+		t.Errorf("Expected decimal hp values when parsing ability log line, got %d/%d %d/%d.", l.TargetCurrentHP, l.TargetMaxHP, l.AttackerCurrentHP, l.AttackerMaxHP)
+	}
+	l, err = ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLinePipeAbility})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if l.TargetCurrentHP != 109947 || l.AttackerMaxHP != 85041 {
+		t.Errorf("Expected decimal hp values when parsing pipe ability log line.")
+	}
+	for _, logLine := range []string{logLineAddPet, logLineRemovePetText} {
+		l, err = ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLine})
+		if err != nil {
+			t.Errorf("Error occurred...%s", err)
+		}
+		if l.TargetMaxHP != 63012 {
+			t.Errorf("Expected decimal max hp when parsing add/remove combatant log line, got %d.", l.TargetMaxHP)
+		}
+	}
+	l, err = ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineDotTick})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if l.TargetCurrentHP != 85041 || l.TargetMaxHP != 85041 {
+		t.Errorf("Expected decimal hp values when parsing dot tick log line.")
+	}
+}
+
+func TestParsePipeLogLine(t *testing.T) {
+	if _, ok := DetectLogLineParser(logLinePipeAbility, "").(PipeLogLineParser); !ok {
+		t.Errorf("Expected pipe log line parser to be detected.")
+	}
+	if _, ok := DetectLogLineParser(logLineBroil, "").(ColonLogLineParser); !ok {
+		t.Errorf("Expected colon log line parser to be detected.")
+	}
+	// ability, name contains a colon and time comes from the line itself
+	l, err := PipeLogLineParser{}.Parse(data.LogLine{LogLine: logLinePipeAbility})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	lColon, _ := ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLineBroil})
+	if l.Type != LogTypeSingleTarget || l.AbilityName != "Hissatsu:Guren" || l.AbilityID != lColon.AbilityID {
+		t.Errorf("Unexpected ability '%s' (%d).", l.AbilityName, l.AbilityID)
+	}
+	if l.AttackerID != lColon.AttackerID || l.TargetName != lColon.TargetName || l.Damage != lColon.Damage {
+		t.Errorf("Expected pipe log line to match colon log line, got '%s' hitting '%s' for %d.", l.AttackerName, l.TargetName, l.Damage)
+	}
+	if l.TargetCurrentHP != lColon.TargetCurrentHP || l.AttackerMaxHP != lColon.AttackerMaxHP || l.AttackerPosition == nil {
+		t.Errorf("Expected hp and position fields to be parsed.")
+	}
+	if len(l.Flags) != len(lColon.Flags) || !l.HasFlag(LogFlagDamage) {
+		t.Errorf("Expected pipe log line flags to match colon log line.")
+	}
+	if l.Time.UTC() != time.Date(2019, 8, 4, 18, 2, 17, 92000000, time.UTC) {
+		t.Errorf("Unexpected log line time %s.", l.Time)
+	}
+	// effect
+	l, err = ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLinePipeGainEffect})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if l.Type != LogTypeGainEffect || l.AbilityID != 0x4C5 || l.AttackerName != "Minda Silva" || l.TargetID != 0x4000B744 || l.Duration != 15*time.Second {
+		t.Errorf("Unexpected values when parsing pipe effect log line.")
+	}
+	if len(l.Flags) > 0 {
+		t.Errorf("Effect log line should not have any flags.")
+	}
+	// defeat
+	l, err = ParseLogLine(data.LogLine{Time: time.Now(), LogLine: logLinePipeDefeat})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if l.Type != LogTypeDefeat || l.TargetName != "Rhitahtyn sas Arvina" || l.AttackerName != "Minda Silva" {
+		t.Errorf("Unexpected values when parsing pipe defeat log line.")
+	}
+	// add combatant, max hp comes after the field that holds flags for other types
+	l, err = PipeLogLineParser{}.Parse(data.LogLine{Time: time.Now(), LogLine: logLinePipeAddCombatant})
+	if err != nil {
+		t.Errorf("Error occurred...%s", err)
+	}
+	if l.Type != LogTypeAddCombatant || l.TargetID != 0x4000B744 || l.TargetName != "Rhitahtyn sas Arvina" || l.TargetMaxHP != 85041 {
+		t.Errorf("Unexpected values when parsing pipe add combatant log line, max hp %d.", l.TargetMaxHP)
+	}
+	if len(l.Flags) > 0 {
+		t.Errorf("Add combatant log line should not have any flags.")
+	}
+}
+
+func TestParseLocalized(t *testing.T) {
+	samples := []struct {
+		language        string
+		defeat          string
+		zoneChange      string
+		zone            string
+		removeCombatant string
+	}{
+		{
+			LogLanguageJapanese,
+			"[11:02:31.874] 19:Rhitahtyn sas Arvinaは、Minda Silvaに倒された。",
+			"[11:02:42.562] 01:ラベンダーベッドにゾーンチェンジした。",
+			"ラベンダーベッド",
+			"[11:02:42.562] 04:4000B744:戦闘メンバーからRhitahtyn sas Arvinaを削除しました。 最大HP: 85041。",
+		},
+		{
+			LogLanguageGerman,
+			"[11:02:31.874] 19:Rhitahtyn sas Arvina wurde von Minda Silva besiegt.",
+			"[11:02:42.562] 01:Gebiet gewechselt zu Lavendelbeete.",
+			"Lavendelbeete",
+			"[11:02:42.562] 04:4000B744:Entferne Kampfteilnehmer Rhitahtyn sas Arvina.  Max. LP: 85041.",
+		},
+		{
+			LogLanguageFrench,
+			"[11:02:31.874] 19:Rhitahtyn sas Arvina a été vaincu par Minda Silva.",
+			"[11:02:42.562] 01:Changement de zone vers Lavandière.",
+			"Lavandière",
+			"[11:02:42.562] 04:4000B744:Retrait du combattant Rhitahtyn sas Arvina.  PV max : 85041.",
+		},
+	}
+	for _, sample := range samples {
+		// both with the session language known and with all languages tried
+		for _, language := range []string{sample.language, ""} {
+			p := DetectLogLineParser(sample.defeat, language)
+			l, err := p.Parse(data.LogLine{Time: time.Now(), LogLine: sample.defeat})
+			if err != nil {
+				t.Errorf("Error occurred...%s", err)
+			}
+			if l.TargetName != "Rhitahtyn sas Arvina" || l.AttackerName != "Minda Silva" {
+				t.Errorf("Unexpected values when parsing '%s' defeat log line, got '%s' defeated by '%s.'", sample.language, l.TargetName, l.AttackerName)
+			}
+			l, err = p.Parse(data.LogLine{Time: time.Now(), LogLine: sample.zoneChange})
+			if err != nil {
+				t.Errorf("Error occurred...%s", err)
+			}
+			if l.TargetName != sample.zone {
+				t.Errorf("Unexpected zone when parsing '%s' zone change log line, got '%s.'", sample.language, l.TargetName)
+			}
+			l, err = p.Parse(data.LogLine{Time: time.Now(), LogLine: sample.removeCombatant})
+			if err != nil {
+				t.Errorf("Error occurred...%s", err)
+			}
+			if l.TargetID != 0x4000B744 || l.TargetName != "Rhitahtyn sas Arvina" || l.TargetMaxHP != 85041 {
+				t.Errorf("Unexpected values when parsing '%s' remove combatant log line.", sample.language)
+			}
+		}
+	}
+	// english text should not be matched when session language is set
+	l, _ := DetectLogLineParser(logLineZone, LogLanguageGerman).Parse(data.LogLine{Time: time.Now(), LogLine: logLineZone})
+	if l.TargetName != "" {
+		t.Errorf("Expected english zone change to be ignored for german client.")
+	}
+	l, _ = DetectLogLineParser(logLineZone, "en-US").Parse(data.LogLine{Time: time.Now(), LogLine: logLineZone})
+	if l.TargetName != "The Lavender Beds" {
+		t.Errorf("Expected zone change to be parsed for english client.")
+	}
+}
+
+// benchmarkLogLines - log lines of each type used by benchmarks
+var benchmarkLogLines = []struct {
+	name    string
+	logLine string
+}{
+	{"Ability", logLineBroil},
+	{"AbilityPipe", logLinePipeAbility},
+	{"Effect", logLineGainEffect},
+	{"EffectText", logLineGainEffectText},
+	{"EffectPipe", logLinePipeGainEffect},
+	{"DotTick", logLineDotTick},
+	{"CastStart", logLineCastStart},
+	{"CastCancel", logLineCastInterrupt},
+	{"Defeat", logLineDefeat},
+	{"DefeatPipe", logLinePipeDefeat},
+	{"ZoneChange", logLineZone},
+	{"AddCombatant", logLineAddPet},
+	{"AddCombatantText", logLineAddPetText},
+	{"GameLog", logLineEnd},
+	{"Chat", logLineWow},
+}
+
+func BenchmarkParseLogLine(b *testing.B) {
+	for _, bl := range benchmarkLogLines {
+		logLine := data.LogLine{Time: time.Now(), LogLine: bl.logLine}
+		b.Run(bl.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(logLine.LogLine)))
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseLogLine(logLine); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEncounterReadLogLine(b *testing.B) {
+	e := NewEncounterManager(nil, data.User{})
+	startTime := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bl := benchmarkLogLines[i%len(benchmarkLogLines)]
+		logLine := data.LogLine{Time: startTime.Add(time.Duration(i) * time.Millisecond), LogLine: bl.logLine}
+		l, _ := ParseLogLine(logLine)
+		e.ReadLogLine(&l)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	// invalid attacker id
+	badAttackerID := "[11:02:17.092] 15:106CBXED" + logLineBroil[26:]
+	_, err := ParseLogLine(data.LogLine{Time: time.Now(), LogLine: badAttackerID})
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("Expected ParseError, got '%v.'", err)
+	}
+	if parseErr.Kind != ParseErrorInvalidField || parseErr.LogType != LogTypeSingleTarget || parseErr.Field != LogFieldAttackerID || parseErr.Value != "106CBXED" {
+		t.Errorf("Unexpected parse error '%s.'", parseErr)
+	}
+	// not enough fields
+	_, err = ParseLogLine(data.LogLine{Time: time.Now(), LogLine: "[11:02:17.092] 15:106CB0ED:Minda Silva:409D"})
+	parseErr, ok = err.(*ParseError)
+	if !ok || parseErr.Kind != ParseErrorMissingFields || parseErr.LogType != LogTypeSingleTarget || parseErr.Field != 4 {
+		t.Errorf("Expected missing fields parse error, got '%v.'", err)
+	}
+	// invalid type
+	_, err = ParseLogLine(data.LogLine{Time: time.Now(), LogLine: "[11:02:17.092] XX:106CB0ED:Minda Silva:409D"})
+	parseErr, ok = err.(*ParseError)
+	if !ok || parseErr.Kind != ParseErrorInvalidType || parseErr.LogType != -1 {
+		t.Errorf("Expected invalid type parse error, got '%v.'", err)
+	}
+	// counters and sampling
+	samplePath, err := ioutil.TempDir("", "fflp-parse-errors-")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	defer os.RemoveAll(samplePath)
+	p := NewParseStats(samplePath)
+	for _, logLineString := range []string{badAttackerID, badAttackerID, logLineBroil, "[11:02:17.092] 1B:106CB0ED:Minda Silva:0000:0000:0017:0000:0000:0000"} {
+		logLine := data.LogLine{Time: time.Now(), LogLine: logLineString}
+		l, err := ParseLogLine(logLine)
+		p.ReadParseResult(logLine, &l, err)
+	}
+	if p.GetErrorCounts()["15"] != 2 {
+		t.Errorf("Expected two parse errors for type 15, got %d.", p.GetErrorCounts()["15"])
+	}
+	if len(p.GetUnknownTypeCounts()) != 1 || p.GetUnknownTypeCounts()["1B"] != 1 {
+		t.Errorf("Expected one unknown log line of type 1B.")
+	}
+	sample, err := ioutil.ReadFile(samplePath + "/parse_errors_15.log")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	if strings.Count(string(sample), badAttackerID) != 2 {
+		t.Errorf("Expected failing log lines to be sampled.")
+	}
+	// samples written concurrently are appended whole
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logLine := data.LogLine{Time: time.Now(), LogLine: badAttackerID}
+			l, err := ParseLogLine(logLine)
+			p.ReadParseResult(logLine, &l, err)
+		}()
+	}
+	wg.Wait()
+	sample, err = ioutil.ReadFile(samplePath + "/parse_errors_15.log")
+	if err != nil {
+		t.Fatalf("Error occurred...%s", err)
+	}
+	sampleLines := strings.Split(strings.TrimSpace(string(sample)), "\n")
+	if p.GetErrorCounts()["15"] != 52 || len(sampleLines) != 52 {
+		t.Errorf("Expected 52 sampled log lines, got %d.", len(sampleLines))
+	}
+	for _, sampleLine := range sampleLines {
+		if !strings.HasSuffix(sampleLine, badAttackerID) {
+			t.Errorf("Expected sampled log line to be written whole, got '%s.'", sampleLine)
+		}
+	}
+}
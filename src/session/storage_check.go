@@ -0,0 +1,267 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"sort"
+	"time"
+
+	"../data"
+	"github.com/jinzhu/gorm"
+)
+
+// storageCheckGracePeriod - files and encounters newer than this are skipped
+// by storage check as they may still be in the middle of being saved
+const storageCheckGracePeriod = 10 * time.Minute
+
+// StorageCheckOptions - problems storage check should fix
+type StorageCheckOptions struct {
+	Repair        bool // rebuild missing log indexes, delete orphaned files and rows, update storage usage
+	DeleteMissing bool // delete encounters whose log file is missing
+}
+
+// StorageCheckReport - problems found by storage check
+type StorageCheckReport struct {
+	Encounters        int              `json:"encounters"`
+	Files             int              `json:"files"`
+	OrphanedFiles     []string         `json:"orphaned_files"`      // files without an encounter
+	MissingLogFiles   []string         `json:"missing_log_files"`   // encounters without a log file
+	MissingIndexFiles []string         `json:"missing_index_files"` // log files without an index
+	OrphanedRows      map[string]int64 `json:"orphaned_rows"`       // rows without an encounter by table
+	Unaccounted       []string         `json:"unaccounted"`         // encounters without storage usage
+	Repaired          bool             `json:"repaired"`
+	DeletedMissing    bool             `json:"deleted_missing"`
+}
+
+// IsClean - true if no problems were found
+func (r *StorageCheckReport) IsClean() bool {
+	orphanedRows := int64(0)
+	for _, count := range r.OrphanedRows {
+		orphanedRows += count
+	}
+	return len(r.OrphanedFiles) == 0 && len(r.MissingLogFiles) == 0 && len(r.MissingIndexFiles) == 0 && orphanedRows == 0 && len(r.Unaccounted) == 0
+}
+
+// orphanedRowQuery - query that selects rows in table that don't belong to an encounter
+type orphanedRowQuery struct {
+	table string
+	model interface{}
+	where string
+	args  []interface{}
+}
+
+// getOrphanedRowQueries - get queries for all tables with encounter data
+func (d *DatabaseHandler) getOrphanedRowQueries() []orphanedRowQuery {
+	encounterUIDs := d.conn.Table("encounters").Select("uid").QueryExpr()
+	output := []orphanedRowQuery{
+		{
+			table: "death_recap_events",
+			model: &data.DeathRecapEvent{},
+			where: "death_recap_id NOT IN (?)",
+			args:  []interface{}{d.conn.Table("death_recaps").Select("id").Where("encounter_uid IN (?)", encounterUIDs).QueryExpr()},
+		},
+		{
+			table: "enemy_damage_sources",
+			model: &data.EnemyDamageSource{},
+			where: "enemy_id NOT IN (?)",
+			args:  []interface{}{d.conn.Table("enemies").Select("id").Where("encounter_uid IN (?)", encounterUIDs).QueryExpr()},
+		},
+	}
+	for table, model := range map[string]interface{}{
+		"combatants":    &data.Combatant{},
+		"casts":         &data.Cast{},
+		"death_recaps":  &data.DeathRecap{},
+		"ability_stats": &data.AbilityStat{},
+		"time_series":   &data.TimeSeries{},
+		"contributions": &data.Contribution{},
+		"enemies":       &data.Enemy{},
+	} {
+		output = append(output, orphanedRowQuery{
+			table: table,
+			model: model,
+			where: "encounter_uid NOT IN (?)",
+			args:  []interface{}{encounterUIDs},
+		})
+	}
+	return output
+}
+
+// CheckStorage - check database and encounter files are consistent with each other,
+// problems are fixed based on given options
+func (d *DatabaseHandler) CheckStorage(storage BlobStorage, options StorageCheckOptions, now time.Time) (StorageCheckReport, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	report := StorageCheckReport{
+		OrphanedFiles:     make([]string, 0),
+		MissingLogFiles:   make([]string, 0),
+		MissingIndexFiles: make([]string, 0),
+		OrphanedRows:      make(map[string]int64),
+		Unaccounted:       make([]string, 0),
+		Repaired:          options.Repair,
+		DeletedMissing:    options.DeleteMissing,
+	}
+	settled := now.Add(-storageCheckGracePeriod)
+	// encounters
+	encounters := make([]data.Encounter, 0)
+	res := d.conn.Select("uid, end_time, storage_rows, storage_bytes").Find(&encounters)
+	if res.Error != nil {
+		return report, res.Error
+	}
+	report.Encounters = len(encounters)
+	encounterUIDs := make(map[string]bool)
+	for _, encounter := range encounters {
+		encounterUIDs[encounter.UID] = true
+	}
+	// files
+	blobs, err := storage.List("fflp_")
+	if err != nil {
+		return report, err
+	}
+	report.Files = len(blobs)
+	// log writing is only paused while files are changed, files modified
+	// within the grace period may still be being written and are left alone
+	keys := make(map[string]bool)
+	settledKeys := make(map[string]bool)
+	for _, blob := range blobs {
+		keys[blob.Key] = true
+		settledKeys[blob.Key] = blob.Time.Before(settled)
+	}
+	for _, blob := range blobs {
+		encounterUID := GetBlobEncounterUID(blob.Key)
+		if encounterUID == "" || encounterUIDs[encounterUID] || !blob.Time.Before(settled) {
+			continue
+		}
+		report.OrphanedFiles = append(report.OrphanedFiles, blob.Key)
+		if options.Repair {
+			err = withLogLock(func() error {
+				return storage.Delete(blob.Key)
+			})
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+	// log files
+	missingUIDs := make([]string, 0)
+	for _, encounter := range encounters {
+		if !keys[GetLogFileKey(encounter.UID)] {
+			if encounter.EndTime.Before(settled) {
+				report.MissingLogFiles = append(report.MissingLogFiles, encounter.UID)
+				missingUIDs = append(missingUIDs, encounter.UID)
+			}
+			continue
+		}
+		if keys[GetLogIndexFileKey(encounter.UID)] || !settledKeys[GetLogFileKey(encounter.UID)] {
+			continue
+		}
+		index, err := buildLogLineIndexFromStorage(storage, encounter.UID)
+		if err == ErrLegacyLogFile {
+			continue
+		} else if err != nil {
+			return report, err
+		}
+		report.MissingIndexFiles = append(report.MissingIndexFiles, GetLogIndexFileKey(encounter.UID))
+		if options.Repair {
+			err = withLogLock(func() error {
+				return writeLogLineIndexToStorage(storage, encounter.UID, index)
+			})
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+	if options.DeleteMissing {
+		err = withLogLock(func() error {
+			_, err := d.deleteEncounters(missingUIDs)
+			return err
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	// rows
+	for _, query := range d.getOrphanedRowQueries() {
+		var res *gorm.DB
+		if options.Repair {
+			res = d.conn.Where(query.where, query.args...).Delete(query.model)
+			report.OrphanedRows[query.table] = res.RowsAffected
+		} else {
+			count := int64(0)
+			res = d.conn.Model(query.model).Where(query.where, query.args...).Count(&count)
+			report.OrphanedRows[query.table] = count
+		}
+		if res.Error != nil {
+			return report, res.Error
+		}
+	}
+	// storage usage, encounters saved before usage was tracked have none and
+	// encounters backfilled by migration only have their rows counted
+	for _, encounter := range encounters {
+		hasLogFile := keys[GetLogFileKey(encounter.UID)]
+		if (encounter.StorageRows > 0 && (encounter.StorageBytes > 0 || !hasLogFile)) || !encounter.EndTime.Before(settled) || (options.DeleteMissing && !hasLogFile) {
+			continue
+		}
+		report.Unaccounted = append(report.Unaccounted, encounter.UID)
+		if options.Repair {
+			err = d.updateEncounterStorage(storage, encounter.UID)
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+	sort.Strings(report.OrphanedFiles)
+	return report, nil
+}
+
+// withLogLock - run function with log file writing paused
+func withLogLock(f func() error) error {
+	globalLogLock.Lock()
+	defer globalLogLock.Unlock()
+	return f()
+}
+
+// writeLogLineIndexToStorage - write rebuilt index of encounter's permanent log file,
+// does nothing if an index was saved since the check started
+func writeLogLineIndexToStorage(storage BlobStorage, encounterUID string, index []LogLineIndexEntry) error {
+	_, err := storage.Stat(GetLogIndexFileKey(encounterUID))
+	if err == nil {
+		return nil
+	} else if err != ErrBlobNotExist {
+		return err
+	}
+	w, err := storage.Create(GetLogIndexFileKey(encounterUID))
+	if err != nil {
+		return err
+	}
+	err = WriteLogLineIndex(w, index)
+	if err != nil {
+		w.Discard()
+		return err
+	}
+	return w.Close()
+}
+
+// buildLogLineIndexFromStorage - rebuild index of encounter's permanent log file
+func buildLogLineIndexFromStorage(storage BlobStorage, encounterUID string) ([]LogLineIndexEntry, error) {
+	r, err := storage.Open(GetLogFileKey(encounterUID), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return BuildLogLineIndex(r)
+}
@@ -0,0 +1,26 @@
+-- database created by the auto migrate of the server before versioned migrations were added, dumped with the sqlite3 .dump command
+PRAGMA foreign_keys=OFF;
+BEGIN TRANSACTION;
+CREATE TABLE IF NOT EXISTS "users" ("id" integer primary key autoincrement,"created" datetime,"accessed" datetime,"upload_key" varchar(32) NOT NULL UNIQUE,"web_key" varchar(32) NOT NULL UNIQUE,"ff_tools_uid" varchar(32) );
+INSERT INTO users VALUES(1,'2020-06-01 20:00:00+00:00','2020-06-01 20:00:00+00:00','baselineupload1','baselineweb1','fftools1');
+INSERT INTO users VALUES(2,'2020-06-01 20:00:00+00:00','2020-06-01 20:00:00+00:00','baselineupload2','baselineweb2','');
+CREATE TABLE IF NOT EXISTS "encounters" ("user_id" bigint,"uid" varchar(32) NOT NULL,"act_id" integer,"compare_hash" varchar(32) NOT NULL,"start_time" datetime,"end_time" datetime,"zone" varchar(256),"damage" integer,"active" bool,"end_wait" bool,"success_level" integer );
+INSERT INTO encounters VALUES(1,'BASELINE_E1',1,'hash1','2020-06-01 20:00:00+00:00','2020-06-01 20:05:00+00:00','The Navel (Extreme)',900000,0,0,1);
+INSERT INTO encounters VALUES(2,'BASELINE_E2',2,'hash2','2020-06-01 21:00:00+00:00','2020-06-01 21:02:00+00:00','The Navel (Extreme)',100000,0,0,2);
+CREATE TABLE IF NOT EXISTS "combatants" ("id" integer primary key autoincrement UNIQUE,"user_id" bigint,"player_id" integer,"encounter_uid" varchar(32),"act_encounter_id" integer,"time" datetime,"job" varchar(3),"damage" integer,"damage_taken" integer,"damage_healed" integer,"deaths" integer,"hits" integer,"heals" integer,"kills" integer );
+INSERT INTO combatants VALUES(1,1,275558636,'BASELINE_E1',1,'2020-06-01 20:05:00+00:00','SAM',500000,40000,0,0,120,0,0);
+INSERT INTO combatants VALUES(2,1,275558637,'BASELINE_E1',1,'2020-06-01 20:05:00+00:00','SCH',300000,0,200000,0,90,60,0);
+INSERT INTO combatants VALUES(3,1,275558638,'BASELINE_E1',1,'2020-06-01 20:05:00+00:00','WAR',100000,300000,0,1,80,0,0);
+INSERT INTO combatants VALUES(4,2,275558639,'BASELINE_E2',2,'2020-06-01 21:02:00+00:00','BLM',100000,0,0,0,40,0,0);
+CREATE TABLE IF NOT EXISTS "players" ("player_id" integer primary key autoincrement NOT NULL,"name" varchar(128),"act_name" varchar(128),"world" varchar(64) );
+INSERT INTO players VALUES(275558636,'Kenshin Hanzo','YOU','Midgardsormr');
+INSERT INTO players VALUES(275558637,'Minda Silva','Minda Silva','Midgardsormr');
+INSERT INTO players VALUES(275558638,'Other Player','Other Player','Adamantoise');
+INSERT INTO players VALUES(275558639,'Solo Caster','YOU','Balmung');
+INSERT INTO sqlite_sequence VALUES('users',2);
+INSERT INTO sqlite_sequence VALUES('combatants',4);
+INSERT INTO sqlite_sequence VALUES('players',275558639);
+CREATE INDEX idx_users_ff_tools_uid ON "users"(ff_tools_uid) ;
+CREATE INDEX idx_encounter_search ON "encounters"(user_id, start_time, end_time) ;
+CREATE UNIQUE INDEX uix_encounters_uid ON "encounters"("uid") ;
+COMMIT;
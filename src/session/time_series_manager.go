@@ -0,0 +1,155 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package session
+
+import (
+	"sync"
+	"time"
+
+	"../app"
+	"../data"
+)
+
+// timeSeriesResolution - size of each time series bucket in ms
+const timeSeriesResolution = 1000
+
+// TimeSeriesManager - builds per player damage and healing time series from the log stream
+type TimeSeriesManager struct {
+	series       []*data.TimeSeries
+	updated      map[int32]int
+	startTime    time.Time
+	lock         *sync.Mutex
+	log          app.Logging
+	encounterUID string
+}
+
+// NewTimeSeriesManager - create new time series manager
+func NewTimeSeriesManager() TimeSeriesManager {
+	t := TimeSeriesManager{
+		log:  app.Logging{ModuleName: "TIMESERIES"},
+		lock: &sync.Mutex{},
+	}
+	t.Reset()
+	return t
+}
+
+// Reset - reset time series manager
+func (t *TimeSeriesManager) Reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.series = make([]*data.TimeSeries, 0)
+	t.updated = make(map[int32]int)
+	t.startTime = time.Time{}
+	t.encounterUID = ""
+}
+
+// ResetEncounter - reset with new encounter data
+func (t *TimeSeriesManager) ResetEncounter(encounter data.Encounter) {
+	t.Reset()
+	t.encounterUID = encounter.UID
+}
+
+// getSeries - get time series for given combatant, create if it doesn't exist
+func (t *TimeSeriesManager) getSeries(combatantID int32, combatantName string, ownerID int32) *data.TimeSeries {
+	for index := range t.series {
+		if t.series[index].CombatantID == combatantID {
+			return t.series[index]
+		}
+	}
+	series := &data.TimeSeries{
+		EncounterUID:  t.encounterUID,
+		CombatantID:   combatantID,
+		CombatantName: combatantName,
+		OwnerID:       ownerID,
+		StartTime:     t.startTime,
+		Resolution:    timeSeriesResolution,
+		Damage:        make([]int32, 0),
+		Healing:       make([]int32, 0),
+	}
+	t.series = append(t.series, series)
+	return series
+}
+
+// ReadLogLine - parse log line and add damage/healing to time series
+func (t *TimeSeriesManager) ReadLogLine(l *ParsedLogLine) {
+	if l.Type != LogTypeSingleTarget && l.Type != LogTypeAoe && l.Type != LogTypeDot {
+		return
+	}
+	// only track players and their pets, dot ticks must have been attributed
+	combatantID, combatantName, ownerID, ok := getActor(l)
+	if !ok {
+		return
+	}
+	damage := int32(0)
+	healing := int32(0)
+	if l.HasFlag(LogFlagHeal) {
+		healing = int32(l.Damage)
+	} else if l.HasFlag(LogFlagDamage) {
+		damage = int32(l.Damage)
+	} else {
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	// first event marks start of all series in encounter
+	if t.startTime.IsZero() {
+		t.startTime = l.Time
+	}
+	series := t.getSeries(combatantID, combatantName, ownerID)
+	index := series.Add(l.Time, damage, healing)
+	if lastIndex, exists := t.updated[series.CombatantID]; !exists || index < lastIndex {
+		t.updated[series.CombatantID] = index
+	}
+}
+
+// GetTimeSeries - get all time series in encounter
+func (t *TimeSeriesManager) GetTimeSeries() []data.TimeSeries {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	output := make([]data.TimeSeries, 0)
+	for index := range t.series {
+		output = append(output, t.series[index].Slice(0))
+	}
+	return output
+}
+
+// SetTimeSeries - set time series, used when loading previous encounter
+func (t *TimeSeriesManager) SetTimeSeries(series []data.TimeSeries) {
+	t.Reset()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for index := range series {
+		t.encounterUID = series[index].EncounterUID
+		t.startTime = series[index].StartTime
+		t.series = append(t.series, &series[index])
+	}
+}
+
+// Dump - get buckets that have changed since last dump
+func (t *TimeSeriesManager) Dump() []data.TimeSeries {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	output := make([]data.TimeSeries, 0)
+	for index := range t.series {
+		if firstIndex, exists := t.updated[t.series[index].CombatantID]; exists {
+			output = append(output, t.series[index].Slice(firstIndex))
+		}
+	}
+	t.updated = make(map[int32]int)
+	return output
+}
@@ -21,6 +21,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -30,6 +31,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -81,6 +83,8 @@ type templateData struct {
 	PlayerStatJob           string
 	FFToolsURL              string
 	FFTriggersURL           string
+	CanPin                  bool
+	StorageUsage            data.StorageUsage
 }
 
 // websocketConnection - Websocket connection data associated with user data
@@ -89,6 +93,41 @@ type websocketConnection struct {
 	userData   data.User
 }
 
+// contributionJSON - Raid buff contribution with raw and adjusted damage figures
+type contributionJSON struct {
+	data.Contribution
+	AdjustedDamage          int64 `json:"adjusted_damage"`
+	CombatantDamage         int64 `json:"combatant_damage"`
+	AdjustedCombatantDamage int64 `json:"adjusted_combatant_damage"`
+}
+
+// logLineJSON - Log line with its offset in the encounter log
+type logLineJSON struct {
+	Offset  int       `json:"offset"`
+	Time    time.Time `json:"time"`
+	LogLine string    `json:"log_line"`
+}
+
+// logLineRangeJSON - Range of log lines from an encounter log
+type logLineRangeJSON struct {
+	EncounterUID string        `json:"encounter_uid"`
+	Offset       int           `json:"offset"`
+	Total        int           `json:"total"`
+	LogLines     []logLineJSON `json:"log_lines"`
+}
+
+// wsRequest - Request sent by web client over web socket
+type wsRequest struct {
+	Type   string  `json:"type"`
+	Offset int     `json:"offset"`
+	Limit  int     `json:"limit"`
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+}
+
+// wsRequestLogLines - Web socket request type for a range of log lines
+const wsRequestLogLines = "log_lines"
+
 // HTTPStartServer - Start HTTP server
 func HTTPStartServer(
 	port uint16,
@@ -168,109 +207,740 @@ func HTTPStartServer(
 		if len(urlPathParts) <= 1 {
 			return
 		}
-		// get user ID string
-		userID := urlPathParts[1]
-		// get encounter uid string
-		encounterUID := ""
-		if len(urlPathParts) >= 3 {
-			encounterUID = urlPathParts[2]
-		}
-		// fetch user data
-		userData, err := sessionManager.UserManager.LoadFromWebIDString(userID)
+		// get user ID string
+		userID := urlPathParts[1]
+		// get encounter uid string
+		encounterUID := ""
+		if len(urlPathParts) >= 3 {
+			encounterUID = urlPathParts[2]
+		}
+		// fetch user data
+		userData, err := sessionManager.UserManager.LoadFromWebIDString(userID)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+		appLog.Log(fmt.Sprintf("Start web socket connection for user '%d' from '%s.'", userData.ID, ws.Request().RemoteAddr))
+		// get act data from web ID
+		userSession := sessionManager.GetSessionWithUser(userData)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+		// relay previous encounter data if encounter id was provided
+		if encounterUID != "" && (userSession == nil || encounterUID != userSession.EncounterManager.GetEncounter().UID) {
+			appLog.Log(fmt.Sprintf("Load previous encounter '%s' for user '%d.'", encounterUID, userData.ID))
+			previousEncounter := sessionManager.GetEmptyUserSession(userData)
+			err := previousEncounter.EncounterManager.Load(encounterUID)
+			if err != nil {
+				appLog.Error(err)
+				return
+			}
+			sendInitData(ws, &previousEncounter)
+			userSession = &previousEncounter
+		} else {
+			// send init data
+			sendInitData(ws, userSession)
+		}
+		// add websocket connection to global list
+		websocketConnections = append(
+			websocketConnections,
+			websocketConnection{
+				connection: ws,
+				userData:   userData,
+			},
+		)
+		defer func() {
+			for index := range websocketConnections {
+				if websocketConnections[index].connection == ws {
+					appLog.Log(fmt.Sprintf("Close web socket connection for '%s.'", ws.Request().RemoteAddr))
+					websocketConnections = append(websocketConnections[:index], websocketConnections[index+1:]...)
+					break
+				}
+			}
+			ws.Close()
+		}()
+		// listen/wait for incomming messages
+		wsReader(ws, sessionManager, userSession)
+	}))
+	http.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		// inc page load count
+		pageLoads++
+		// create a new user
+		appLog.Log("Create new user.")
+		userData := sessionManager.UserManager.New()
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"An error occured while creating a new user ID.",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		// set web key to cookie
+		cookie := getWebKeyCookie(userData, r)
+		http.SetCookie(w, &cookie)
+		// perform redirect to home page
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+	// display stats
+	http.HandleFunc("/usage", func(w http.ResponseWriter, r *http.Request) {
+		// set resposne headers
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// build template data
+		td := getBaseTemplateData()
+		// collect stats
+		td.StatActConnections = sessionManager.SessionCount()
+		td.StatActiveWebUsers = len(websocketConnections)
+		td.StatPageLoads = pageLoads
+		// render stats template
+		htmlTemplates["usage_stats.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
+	})
+	// display json stats
+	http.HandleFunc("/_usage_json", func(w http.ResponseWriter, r *http.Request) {
+		// set resposne headers
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		jsonBytes, err := json.Marshal(usageStatCollector)
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"An error occured while displaying stats",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		w.Write(jsonBytes)
+	})
+	// display json raid buff contributions for an encounter
+	http.HandleFunc("/_contribution_json/", func(w http.ResponseWriter, r *http.Request) {
+		// set resposne headers
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		// split url path in to parts, expects web id and encounter uid
+		urlPathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(urlPathParts) < 3 || urlPathParts[1] == "" || urlPathParts[2] == "" {
+			displayError(
+				w,
+				"User and encounter must be provided.",
+				http.StatusNotFound,
+			)
+			return
+		}
+		encounterUID := urlPathParts[2]
+		// get user data
+		userData, err := sessionManager.UserManager.LoadFromWebIDString(urlPathParts[1])
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				fmt.Sprintf("Unable to find session for user '%d.'", userData.ID),
+				http.StatusNotFound,
+			)
+			return
+		}
+		// use current encounter if active, otherwise load from database
+		userSession := sessionManager.GetSessionWithUser(userData)
+		if userSession == nil || userSession.EncounterManager.GetEncounter().UID != encounterUID {
+			previousEncounter := sessionManager.GetEmptyUserSession(userData)
+			err := previousEncounter.EncounterManager.Load(encounterUID)
+			if err != nil || previousEncounter.EncounterManager.GetEncounter().UserID != userData.ID {
+				displayError(
+					w,
+					fmt.Sprintf("Unable to find encounter '%s.'", encounterUID),
+					http.StatusNotFound,
+				)
+				return
+			}
+			userSession = &previousEncounter
+		}
+		// combine with act combatant damage, pets are merged in to their owner unless requested
+		combatants := userSession.EncounterManager.CombatantManager.GetLastCombatants()
+		contributions := userSession.EncounterManager.ContributionManager.GetContributions()
+		if r.URL.Query().Get("pets") != "separate" {
+			contributions = session.MergePetContributions(contributions)
+		}
+		output := make([]contributionJSON, 0)
+		for index := range contributions {
+			item := contributionJSON{
+				Contribution:   contributions[index],
+				AdjustedDamage: contributions[index].AdjustedDamage(),
+			}
+			for _, combatant := range combatants {
+				if combatant.Player.ID == contributions[index].CombatantID {
+					item.CombatantDamage = int64(combatant.Damage)
+					item.AdjustedCombatantDamage = contributions[index].AdjustDamage(item.CombatantDamage)
+					break
+				}
+			}
+			output = append(output, item)
+		}
+		jsonBytes, err := json.Marshal(output)
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"An error occured while displaying raid buff contributions",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		w.Write(jsonBytes)
+	})
+	// display json range of log lines for an encounter, range is given
+	// as line offset/limit and/or start/end seconds from encounter start
+	http.HandleFunc("/_log_lines_json/", func(w http.ResponseWriter, r *http.Request) {
+		// set resposne headers
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		// split url path in to parts, expects web id and encounter uid
+		urlPathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(urlPathParts) < 3 || urlPathParts[1] == "" || urlPathParts[2] == "" {
+			displayError(
+				w,
+				"User and encounter must be provided.",
+				http.StatusNotFound,
+			)
+			return
+		}
+		encounterUID := urlPathParts[2]
+		// parse range
+		req, err := parseLogLineRangeRequest(r)
+		if err != nil {
+			displayError(
+				w,
+				err.Error(),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		// get user data
+		userData, err := sessionManager.UserManager.LoadFromWebIDString(urlPathParts[1])
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				fmt.Sprintf("Unable to find session for user '%d.'", userData.ID),
+				http.StatusNotFound,
+			)
+			return
+		}
+		// use current encounter if active, otherwise load from database
+		userSession := sessionManager.GetSessionWithUser(userData)
+		if userSession == nil || userSession.EncounterManager.GetEncounter().UID != encounterUID {
+			previousEncounter := sessionManager.GetEmptyUserSession(userData)
+			err := previousEncounter.EncounterManager.Load(encounterUID)
+			if err != nil || previousEncounter.EncounterManager.GetEncounter().UserID != userData.ID {
+				displayError(
+					w,
+					fmt.Sprintf("Unable to find encounter '%s.'", encounterUID),
+					http.StatusNotFound,
+				)
+				return
+			}
+			userSession = &previousEncounter
+		}
+		result, err := getLogLineRange(userSession, req)
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"An error occured while reading log lines",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		output := logLineRangeJSON{
+			EncounterUID: encounterUID,
+			Offset:       result.Offset,
+			Total:        result.Total,
+			LogLines:     make([]logLineJSON, len(result.LogLines)),
+		}
+		for index := range result.LogLines {
+			output.LogLines[index] = logLineJSON{
+				Offset:  result.Offset + index,
+				Time:    result.LogLines[index].Time,
+				LogLine: result.LogLines[index].LogLine,
+			}
+		}
+		jsonBytes, err := json.Marshal(output)
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"An error occured while displaying log lines",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		w.Write(jsonBytes)
+	})
+	// search stored log lines of a single encounter, or of all of a user's
+	// encounters when no encounter uid is given
+	http.HandleFunc("/_log_search_json/", func(w http.ResponseWriter, r *http.Request) {
+		// set resposne headers
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		// split url path in to parts, expects web id and optional encounter uid
+		urlPathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(urlPathParts) < 2 || urlPathParts[1] == "" {
+			displayError(
+				w,
+				"User must be provided.",
+				http.StatusNotFound,
+			)
+			return
+		}
+		encounterUID := ""
+		if len(urlPathParts) >= 3 {
+			encounterUID = urlPathParts[2]
+		}
+		// get user data
+		userData, err := sessionManager.UserManager.LoadFromWebIDString(urlPathParts[1])
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				fmt.Sprintf("Unable to find session for user '%d.'", userData.ID),
+				http.StatusNotFound,
+			)
+			return
+		}
+		// build query
+		query, startTime, endTime, err := parseLogSearchQuery(r)
+		if err != nil {
+			displayError(
+				w,
+				err.Error(),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		// get encounters to search
+		encounterUIDs := []string{encounterUID}
+		if encounterUID != "" {
+			encounter, err := sessionManager.Database.FetchEncounter(encounterUID)
+			if err != nil || encounter.UserID != userData.ID {
+				displayError(
+					w,
+					fmt.Sprintf("Unable to find encounter '%s.'", encounterUID),
+					http.StatusNotFound,
+				)
+				return
+			}
+		} else {
+			encounterUIDs, err = sessionManager.Database.FetchUserEncounterUIDs(userData.ID, startTime, endTime)
+			if err != nil {
+				appLog.Error(err)
+				displayError(
+					w,
+					"Unable to fetch past encounters.",
+					http.StatusInternalServerError,
+				)
+				return
+			}
+		}
+		appLog.Log(fmt.Sprintf("Search logs of %d encounter(s) for user '%d' from %s.", len(encounterUIDs), userData.ID, r.RemoteAddr))
+		result, err := session.SearchLogs(session.GetBlobStorage(), encounterUIDs, query)
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"An error occured while searching logs",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		jsonBytes, err := json.Marshal(result)
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"An error occured while displaying log search",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		w.Write(jsonBytes)
+	})
+	// download stored encounter log in act log file format, time window
+	// is given as start/end seconds from encounter start
+	http.HandleFunc("/_log_export/", func(w http.ResponseWriter, r *http.Request) {
+		// split url path in to parts, expects web id and encounter uid
+		urlPathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(urlPathParts) < 3 || urlPathParts[1] == "" || urlPathParts[2] == "" {
+			displayError(
+				w,
+				"User and encounter must be provided.",
+				http.StatusNotFound,
+			)
+			return
+		}
+		encounterUID := strings.TrimSuffix(urlPathParts[2], ".log")
+		req, err := parseLogLineRangeRequest(r)
+		if err != nil {
+			displayError(
+				w,
+				err.Error(),
+				http.StatusBadRequest,
+			)
+			return
+		}
+		// time zone offset in minutes, same as javascript's getTimezoneOffset
+		loc := time.UTC
+		if r.URL.Query().Get("tz") != "" {
+			tzOffset, err := strconv.Atoi(r.URL.Query().Get("tz"))
+			if err != nil {
+				displayError(
+					w,
+					"Error parsing time zone \""+r.URL.Query().Get("tz")+".\"",
+					http.StatusBadRequest,
+				)
+				return
+			}
+			loc = time.FixedZone("", -tzOffset*60)
+		}
+		// get user data
+		userData, err := sessionManager.UserManager.LoadFromWebIDString(urlPathParts[1])
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				fmt.Sprintf("Unable to find session for user '%d.'", userData.ID),
+				http.StatusNotFound,
+			)
+			return
+		}
+		encounter, err := sessionManager.Database.FetchEncounter(encounterUID)
+		if err != nil || encounter.UserID != userData.ID {
+			displayError(
+				w,
+				fmt.Sprintf("Unable to find encounter '%s.'", encounterUID),
+				http.StatusNotFound,
+			)
+			return
+		}
+		if _, err := session.GetBlobStorage().Stat(session.GetLogFileKey(encounterUID)); err != nil {
+			displayError(
+				w,
+				fmt.Sprintf("Log for encounter '%s' is no longer available.", encounterUID),
+				http.StatusNotFound,
+			)
+			return
+		}
+		appLog.Log(fmt.Sprintf("Export log of encounter '%s' for user '%d' to %s.", encounterUID, userData.ID, r.RemoteAddr))
+		// stream log as download
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"fflp_%s.log\"", encounterUID))
+		rng := logLineRangeFromRequest(req, encounter.StartTime)
+		err = session.ExportLog(w, session.GetBlobStorage(), encounterUID, rng, loc)
+		if err != nil {
+			appLog.Error(err)
+		}
+	})
+	// storage used by user's encounters and user's quota
+	http.HandleFunc("/_storage_json/", func(w http.ResponseWriter, r *http.Request) {
+		// set resposne headers
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		// split url path in to parts, expects web id
+		urlPathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(urlPathParts) < 2 || urlPathParts[1] == "" {
+			displayError(
+				w,
+				"User must be provided.",
+				http.StatusNotFound,
+			)
+			return
+		}
+		// get user data
+		userData, err := sessionManager.UserManager.LoadFromWebIDString(urlPathParts[1])
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				fmt.Sprintf("Unable to find session for user '%d.'", userData.ID),
+				http.StatusNotFound,
+			)
+			return
+		}
+		usage, err := sessionManager.Database.FetchUserStorageUsage(userData.ID)
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"Unable to fetch storage usage.",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		jsonBytes, err := json.Marshal(usage)
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"An error occured while displaying storage usage",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		w.Write(jsonBytes)
+	})
+	// pin or unpin encounter, only the encounter's owner can pin
+	http.HandleFunc("/_pin/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			displayError(
+				w,
+				"Method not allowed.",
+				http.StatusMethodNotAllowed,
+			)
+			return
+		}
+		// split url path in to parts, expects web id and encounter uid
+		urlPathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(urlPathParts) < 3 || urlPathParts[1] == "" || urlPathParts[2] == "" {
+			displayError(
+				w,
+				"User and encounter must be provided.",
+				http.StatusNotFound,
+			)
+			return
+		}
+		encounterUID := urlPathParts[2]
+		// get user data
+		userData, err := sessionManager.UserManager.LoadFromWebIDString(urlPathParts[1])
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				fmt.Sprintf("Unable to find session for user '%d.'", userData.ID),
+				http.StatusNotFound,
+			)
+			return
+		}
+		if !isUserRequest(r, sessionManager, userData) {
+			displayError(
+				w,
+				"Only the owner of an encounter can pin it.",
+				http.StatusForbidden,
+			)
+			return
+		}
+		encounter, err := sessionManager.Database.FetchEncounter(encounterUID)
+		if err != nil || encounter.UserID != userData.ID {
+			displayError(
+				w,
+				fmt.Sprintf("Unable to find encounter '%s.'", encounterUID),
+				http.StatusNotFound,
+			)
+			return
+		}
+		pinned := r.FormValue("pinned") == "1"
+		err = sessionManager.Database.SetEncounterPinned(encounterUID, pinned)
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"Unable to pin encounter.",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		appLog.Log(fmt.Sprintf("Set pinned=%t on encounter '%s' for user '%d.'", pinned, encounterUID, userData.ID))
+		// return to page pin was set from
+		redirectURL := r.Referer()
+		if redirectURL == "" {
+			redirectURL = "/history/" + urlPathParts[1]
+		}
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	})
+	// set encounter retention for user or retention group, requires admin key
+	http.HandleFunc("/_admin/retention", func(w http.ResponseWriter, r *http.Request) {
+		if !isAdminRequest(r) {
+			displayError(
+				w,
+				"Admin key is missing or invalid.",
+				http.StatusForbidden,
+			)
+			return
+		}
+		if r.Method != http.MethodPost {
+			displayError(
+				w,
+				"Method not allowed.",
+				http.StatusMethodNotAllowed,
+			)
+			return
+		}
+		r.ParseForm()
+		webIDString := r.PostForm.Get("user")
+		group := r.PostForm.Get("group")
+		_, hasGroup := r.PostForm["group"]
+		var err error
+		days := 0
+		_, hasDays := r.PostForm["days"]
+		if hasDays {
+			days, err = strconv.Atoi(r.PostForm.Get("days"))
+			if err != nil {
+				displayError(
+					w,
+					"Error parsing days \""+r.PostForm.Get("days")+".\"",
+					http.StatusBadRequest,
+				)
+				return
+			}
+		}
+		switch {
+		case webIDString != "" && (hasDays || hasGroup):
+			var userData data.User
+			userData, err = sessionManager.UserManager.LoadFromWebIDString(webIDString)
+			if err != nil {
+				displayError(
+					w,
+					fmt.Sprintf("Unable to find user '%s.'", webIDString),
+					http.StatusNotFound,
+				)
+				return
+			}
+			if hasDays {
+				err = sessionManager.Database.SetUserRetentionDays(userData.ID, days)
+			}
+			if err == nil && hasGroup {
+				err = sessionManager.Database.SetUserRetentionGroup(userData.ID, group)
+			}
+			appLog.Log(fmt.Sprintf("Set retention for user '%d.' (DAYS=%s GROUP=%s)", userData.ID, r.PostForm.Get("days"), group))
+		case group != "" && hasDays:
+			err = sessionManager.Database.StoreRetentionGroup(&data.RetentionGroup{Name: group, Days: days})
+			appLog.Log(fmt.Sprintf("Set retention for group '%s.' (DAYS=%d)", group, days))
+		default:
+			displayError(
+				w,
+				"Either user with days and/or group, or group with days must be provided.",
+				http.StatusBadRequest,
+			)
+			return
+		}
+		if err != nil {
+			appLog.Error(err)
+			displayError(
+				w,
+				"Unable to set retention.",
+				http.StatusInternalServerError,
+			)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	// set storage quota for user, requires admin key
+	http.HandleFunc("/_admin/quota", func(w http.ResponseWriter, r *http.Request) {
+		if !isAdminRequest(r) {
+			displayError(
+				w,
+				"Admin key is missing or invalid.",
+				http.StatusForbidden,
+			)
+			return
+		}
+		if r.Method != http.MethodPost {
+			displayError(
+				w,
+				"Method not allowed.",
+				http.StatusMethodNotAllowed,
+			)
+			return
+		}
+		r.ParseForm()
+		webIDString := r.PostForm.Get("user")
+		_, hasEncounters := r.PostForm["encounters"]
+		_, hasBytes := r.PostForm["bytes"]
+		if webIDString == "" || (!hasEncounters && !hasBytes) {
+			displayError(
+				w,
+				"User with encounters and/or bytes must be provided.",
+				http.StatusBadRequest,
+			)
+			return
+		}
+		encounters, encountersErr := strconv.ParseInt(r.PostForm.Get("encounters"), 10, 64)
+		if hasEncounters && encountersErr != nil {
+			displayError(
+				w,
+				"Error parsing encounters \""+r.PostForm.Get("encounters")+".\"",
+				http.StatusBadRequest,
+			)
+			return
+		}
+		bytes, bytesErr := strconv.ParseInt(r.PostForm.Get("bytes"), 10, 64)
+		if hasBytes && bytesErr != nil {
+			displayError(
+				w,
+				"Error parsing bytes \""+r.PostForm.Get("bytes")+".\"",
+				http.StatusBadRequest,
+			)
+			return
+		}
+		userData, err := sessionManager.UserManager.LoadFromWebIDString(webIDString)
 		if err != nil {
-			appLog.Error(err)
+			displayError(
+				w,
+				fmt.Sprintf("Unable to find user '%s.'", webIDString),
+				http.StatusNotFound,
+			)
 			return
 		}
-		appLog.Log(fmt.Sprintf("Start web socket connection for user '%d' from '%s.'", userData.ID, ws.Request().RemoteAddr))
-		// get act data from web ID
-		userSession := sessionManager.GetSessionWithUser(userData)
+		if hasEncounters {
+			err = sessionManager.Database.SetUserQuotaEncounters(userData.ID, encounters)
+		}
+		if err == nil && hasBytes {
+			err = sessionManager.Database.SetUserQuotaBytes(userData.ID, bytes)
+		}
 		if err != nil {
 			appLog.Error(err)
+			displayError(
+				w,
+				"Unable to set quota.",
+				http.StatusInternalServerError,
+			)
 			return
 		}
-		// relay previous encounter data if encounter id was provided
-		if encounterUID != "" && (userSession == nil || encounterUID != userSession.EncounterManager.GetEncounter().UID) {
-			appLog.Log(fmt.Sprintf("Load previous encounter '%s' for user '%d.'", encounterUID, userData.ID))
-			previousEncounter := sessionManager.GetEmptyUserSession(userData)
-			err := previousEncounter.EncounterManager.Load(encounterUID)
-			if err != nil {
-				appLog.Error(err)
-				return
-			}
-			sendInitData(ws, &previousEncounter)
-		} else {
-			// send init data
-			sendInitData(ws, userSession)
+		appLog.Log(fmt.Sprintf("Set quota for user '%d.' (ENCOUNTERS=%s BYTES=%s)", userData.ID, r.PostForm.Get("encounters"), r.PostForm.Get("bytes")))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	// check database and encounter files are consistent, requires admin key
+	// problems are only fixed on POST with repair and/or delete_missing set
+	http.HandleFunc("/_admin/check", func(w http.ResponseWriter, r *http.Request) {
+		if !isAdminRequest(r) {
+			displayError(
+				w,
+				"Admin key is missing or invalid.",
+				http.StatusForbidden,
+			)
+			return
 		}
-		// add websocket connection to global list
-		websocketConnections = append(
-			websocketConnections,
-			websocketConnection{
-				connection: ws,
-				userData:   userData,
-			},
-		)
-		defer func() {
-			for index := range websocketConnections {
-				if websocketConnections[index].connection == ws {
-					appLog.Log(fmt.Sprintf("Close web socket connection for '%s.'", ws.Request().RemoteAddr))
-					websocketConnections = append(websocketConnections[:index], websocketConnections[index+1:]...)
-					break
-				}
-			}
-			ws.Close()
-		}()
-		// listen/wait for incomming messages
-		wsReader(ws, sessionManager)
-	}))
-	http.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
-		// inc page load count
-		pageLoads++
-		// create a new user
-		appLog.Log("Create new user.")
-		userData := sessionManager.UserManager.New()
+		options := session.StorageCheckOptions{}
+		if r.Method == http.MethodPost {
+			options.Repair = r.FormValue("repair") == "1"
+			options.DeleteMissing = r.FormValue("delete_missing") == "1"
+		}
+		appLog.Log(fmt.Sprintf("Check storage for %s. (REPAIR=%t DELETE_MISSING=%t)", r.RemoteAddr, options.Repair, options.DeleteMissing))
+		report, err := sessionManager.Database.CheckStorage(session.GetBlobStorage(), options, time.Now())
 		if err != nil {
 			appLog.Error(err)
 			displayError(
 				w,
-				"An error occured while creating a new user ID.",
+				"Unable to check storage.",
 				http.StatusInternalServerError,
 			)
 			return
 		}
-		// set web key to cookie
-		cookie := getWebKeyCookie(userData, r)
-		http.SetCookie(w, &cookie)
-		// perform redirect to home page
-		http.Redirect(w, r, "/", http.StatusFound)
-	})
-	// display stats
-	http.HandleFunc("/usage", func(w http.ResponseWriter, r *http.Request) {
-		// set resposne headers
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		// build template data
-		td := getBaseTemplateData()
-		// collect stats
-		td.StatActConnections = sessionManager.SessionCount()
-		td.StatActiveWebUsers = len(websocketConnections)
-		td.StatPageLoads = pageLoads
-		// render stats template
-		htmlTemplates["usage_stats.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
-	})
-	// display json stats
-	http.HandleFunc("/_usage_json", func(w http.ResponseWriter, r *http.Request) {
-		// set resposne headers
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		jsonBytes, err := json.Marshal(usageStatCollector)
+		jsonBytes, err := json.Marshal(report)
 		if err != nil {
 			appLog.Error(err)
 			displayError(
 				w,
-				"An error occured while displaying stats",
+				"An error occured while displaying storage check",
 				http.StatusInternalServerError,
 			)
 			return
 		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.Write(jsonBytes)
 	})
 	// display past encounters
@@ -309,6 +979,11 @@ func HTTPStartServer(
 		}
 		addUserToTemplateData(&td, userData)
 		td.WebIDString = webUID
+		td.CanPin = isUserRequest(r, sessionManager, userData)
+		td.StorageUsage, err = sessionManager.Database.FetchUserStorageUsage(userData.ID)
+		if err != nil {
+			appLog.Error(err)
+		}
 		// get offset
 		offsetString := r.URL.Query().Get("offset")
 		offset := int(0)
@@ -648,7 +1323,8 @@ func compileGCSS() (map[string]string, error) {
 	return output, nil
 }
 
-func wsReader(ws *websocket.Conn, sessionManager *session.Manager) {
+func wsReader(ws *websocket.Conn, sessionManager *session.Manager, userSession *session.UserSession) {
+	appLog := app.Logging{ModuleName: "WEB/RECV"}
 	for {
 		if ws == nil || sessionManager == nil {
 			break
@@ -658,7 +1334,142 @@ func wsReader(ws *websocket.Conn, sessionManager *session.Manager) {
 		if err != nil {
 			break
 		}
+		// handle requests
+		req := wsRequest{}
+		if userSession == nil || json.Unmarshal(data, &req) != nil {
+			continue
+		}
+		switch req.Type {
+		case wsRequestLogLines:
+			{
+				err = sendLogLineRange(ws, userSession, req)
+				if err != nil {
+					appLog.Error(err)
+				}
+			}
+		}
+	}
+}
+
+// parseLogSearchQuery - Build log search query and encounter time range from request query string
+func parseLogSearchQuery(r *http.Request) (session.LogSearchQuery, *time.Time, *time.Time, error) {
+	values := r.URL.Query()
+	query := session.LogSearchQuery{
+		Types:   make([]int, 0),
+		Actor:   values.Get("actor"),
+		Target:  values.Get("target"),
+		Ability: values.Get("ability"),
+	}
+	// log types are given as comma separated hex values, same as in the log
+	if values.Get("type") != "" {
+		for _, typeString := range strings.Split(values.Get("type"), ",") {
+			logType, err := strconv.ParseInt(strings.TrimSpace(typeString), 16, 64)
+			if err != nil {
+				return query, nil, nil, fmt.Errorf("Invalid log line type \"%s.\"", typeString)
+			}
+			query.Types = append(query.Types, int(logType))
+		}
+	}
+	if values.Get("regex") != "" {
+		var err error
+		query.Regex, err = regexp.Compile(values.Get("regex"))
+		if err != nil {
+			return query, nil, nil, fmt.Errorf("Invalid regex \"%s.\"", values.Get("regex"))
+		}
+	}
+	for name, value := range map[string]*int{"offset": &query.Offset, "limit": &query.Limit} {
+		if values.Get(name) == "" {
+			continue
+		}
+		var err error
+		*value, err = strconv.Atoi(values.Get(name))
+		if err != nil {
+			return query, nil, nil, fmt.Errorf("Invalid %s.", name)
+		}
+	}
+	// encounter time range is given as RFC3339 times
+	times := make([]*time.Time, 2)
+	for index, name := range []string{"start", "end"} {
+		if values.Get(name) == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, values.Get(name))
+		if err != nil {
+			return query, nil, nil, fmt.Errorf("Error parsing %s time \"%s.\"", name, values.Get(name))
+		}
+		times[index] = &t
+	}
+	return query, times[0], times[1], nil
+}
+
+// parseLogLineRangeRequest - Parse log line range from request query string,
+// offset/limit are in lines and start/end are in seconds from encounter start
+func parseLogLineRangeRequest(r *http.Request) (wsRequest, error) {
+	req := wsRequest{Type: wsRequestLogLines}
+	for name, value := range map[string]*int{"offset": &req.Offset, "limit": &req.Limit} {
+		if r.URL.Query().Get(name) == "" {
+			continue
+		}
+		var err error
+		*value, err = strconv.Atoi(r.URL.Query().Get(name))
+		if err != nil {
+			return req, fmt.Errorf("Invalid %s.", name)
+		}
+	}
+	for name, value := range map[string]*float64{"start": &req.Start, "end": &req.End} {
+		if r.URL.Query().Get(name) == "" {
+			continue
+		}
+		var err error
+		*value, err = strconv.ParseFloat(r.URL.Query().Get(name), 64)
+		if err != nil {
+			return req, fmt.Errorf("Invalid %s.", name)
+		}
+	}
+	return req, nil
+}
+
+// logLineRangeFromRequest - Convert log line range requested by web client to log line range
+func logLineRangeFromRequest(req wsRequest, encounterStart time.Time) session.LogLineRange {
+	rng := session.LogLineRange{
+		Offset: req.Offset,
+		Limit:  req.Limit,
+	}
+	if req.Start > 0 {
+		rng.Start = encounterStart.Add(time.Duration(req.Start * float64(time.Second)))
+	}
+	if req.End > 0 {
+		rng.End = encounterStart.Add(time.Duration(req.End * float64(time.Second)))
+	}
+	return rng
+}
+
+// getLogLineRange - Read range of log lines requested by web client from encounter log
+func getLogLineRange(userSession *session.UserSession, req wsRequest) (session.LogLineRangeResult, error) {
+	rng := logLineRangeFromRequest(req, userSession.EncounterManager.GetEncounter().StartTime)
+	return userSession.EncounterManager.LogLineManager.GetLogLineRange(rng)
+}
+
+// sendLogLineRange - Send range of log lines requested by web client
+func sendLogLineRange(ws *websocket.Conn, userSession *session.UserSession, req wsRequest) error {
+	result, err := getLogLineRange(userSession, req)
+	if err != nil {
+		return err
 	}
+	if len(result.LogLines) == 0 {
+		return nil
+	}
+	encounterUID := userSession.EncounterManager.GetEncounter().UID
+	logLineBytes := make([]byte, 0)
+	for index := range result.LogLines {
+		result.LogLines[index].EncounterUID = encounterUID
+		logLineBytes = append(logLineBytes, result.LogLines[index].ToBytes()...)
+	}
+	logLineBytes, err = data.CompressBytes(logLineBytes)
+	if err != nil {
+		return err
+	}
+	return websocket.Message.Send(ws, logLineBytes)
 }
 
 func globalWsWriter(websocketConnections *[]websocketConnection, events *emitter.Emitter) {
@@ -732,6 +1543,31 @@ func displayError(w http.ResponseWriter, message string, statusCode int) {
 	htmlTemplates["error.tmpl"].ExecuteTemplate(w, "base.tmpl", td)
 }
 
+// isUserRequest - check if request was made by given user, either with web key cookie or fftools login
+func isUserRequest(r *http.Request, sessionManager *session.Manager, user data.User) bool {
+	cookie, err := r.Cookie(webKeyCookieName)
+	if err == nil {
+		cookieUser, err := sessionManager.UserManager.LoadFromWebKey(cookie.Value)
+		if err == nil && cookieUser.ID == user.ID {
+			return true
+		}
+	}
+	if user.FFToolsUID == "" {
+		return false
+	}
+	fftUser, err := sessionManager.UserManager.FFToolsUserManager.Fetch(r)
+	return err == nil && fftUser.UID == user.FFToolsUID
+}
+
+// isAdminRequest - check if request has admin key, admin requests are disabled when no admin key is set
+func isAdminRequest(r *http.Request) bool {
+	adminKey := app.GetAdminKey()
+	if adminKey == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Admin-Key")), []byte(adminKey)) == 1
+}
+
 func getWebKeyCookie(user data.User, r *http.Request) http.Cookie {
 	return http.Cookie{
 		Name:    webKeyCookieName,
@@ -760,6 +1596,19 @@ func sendInitData(ws *websocket.Conn, userSession *session.UserSession) {
 	if userSession == nil {
 		return
 	}
+	// add flag indicating if last encounter was over storage quota
+	if userSession.EncounterManager.IsQuotaExceeded() {
+		quotaFlag := data.Flag{
+			Name:  "quota_exceeded",
+			Value: true,
+		}
+		quotaCompress, err := data.CompressBytes(quotaFlag.ToBytes())
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+		websocket.Message.Send(ws, quotaCompress)
+	}
 	// prepare data
 	dataBytes := make([]byte, 0)
 	// send encounter
@@ -805,32 +1654,156 @@ func sendInitData(ws *websocket.Conn, userSession *session.UserSession) {
 			return
 		}
 	}
+	// add casts
+	dataBytes = make([]byte, 0)
+	casts := userSession.EncounterManager.CastManager.GetCasts()
+	for _, cast := range casts {
+		dataBytes = append(dataBytes, cast.ToBytes()...)
+	}
+	// compress + send
+	if len(dataBytes) > 0 {
+		dataBytes, err = data.CompressBytes(dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+		appLog.Log(fmt.Sprintf("Send %d bytes (casts) of data to '%s.'", len(dataBytes), ws.Request().RemoteAddr))
+		err = websocket.Message.Send(ws, dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+	}
+	// add death recaps
+	dataBytes = make([]byte, 0)
+	deathRecaps := userSession.EncounterManager.DeathRecapManager.GetDeathRecaps()
+	for _, deathRecap := range deathRecaps {
+		dataBytes = append(dataBytes, deathRecap.ToBytes()...)
+	}
+	// compress + send
+	if len(dataBytes) > 0 {
+		dataBytes, err = data.CompressBytes(dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+		appLog.Log(fmt.Sprintf("Send %d bytes (death recaps) of data to '%s.'", len(dataBytes), ws.Request().RemoteAddr))
+		err = websocket.Message.Send(ws, dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+	}
+	// add ability stats
+	dataBytes = make([]byte, 0)
+	abilityStats := userSession.EncounterManager.AbilityStatManager.GetAbilityStats()
+	for _, abilityStat := range abilityStats {
+		dataBytes = append(dataBytes, abilityStat.ToBytes()...)
+	}
+	// compress + send
+	if len(dataBytes) > 0 {
+		dataBytes, err = data.CompressBytes(dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+		appLog.Log(fmt.Sprintf("Send %d bytes (ability stats) of data to '%s.'", len(dataBytes), ws.Request().RemoteAddr))
+		err = websocket.Message.Send(ws, dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+	}
+	// add time series
+	dataBytes = make([]byte, 0)
+	timeSeries := userSession.EncounterManager.TimeSeriesManager.GetTimeSeries()
+	for _, series := range timeSeries {
+		dataBytes = append(dataBytes, series.ToBytes()...)
+	}
+	// compress + send
+	if len(dataBytes) > 0 {
+		dataBytes, err = data.CompressBytes(dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+		appLog.Log(fmt.Sprintf("Send %d bytes (time series) of data to '%s.'", len(dataBytes), ws.Request().RemoteAddr))
+		err = websocket.Message.Send(ws, dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+	}
+	// add enemies
+	dataBytes = make([]byte, 0)
+	enemies := userSession.EncounterManager.EnemyManager.GetEnemies()
+	for _, enemy := range enemies {
+		dataBytes = append(dataBytes, enemy.ToBytes()...)
+	}
+	// compress + send
+	if len(dataBytes) > 0 {
+		dataBytes, err = data.CompressBytes(dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+		appLog.Log(fmt.Sprintf("Send %d bytes (enemies) of data to '%s.'", len(dataBytes), ws.Request().RemoteAddr))
+		err = websocket.Message.Send(ws, dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+	}
+	// add movement tracks
+	dataBytes = make([]byte, 0)
+	movementTracks := userSession.EncounterManager.PositionManager.GetTracks()
+	for _, movementTrack := range movementTracks {
+		dataBytes = append(dataBytes, movementTrack.ToBytes()...)
+	}
+	// compress + send
+	if len(dataBytes) > 0 {
+		dataBytes, err = data.CompressBytes(dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+		appLog.Log(fmt.Sprintf("Send %d bytes (movement tracks) of data to '%s.'", len(dataBytes), ws.Request().RemoteAddr))
+		err = websocket.Message.Send(ws, dataBytes)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
+	}
 	// send log lines
 	// attempt to find permanent log line file
 	byteCount := 0
-	logFilePath := userSession.EncounterManager.LogLineManager.GetLogFilePath()
-	logFile, err := os.OpenFile(logFilePath, os.O_RDONLY, 0644)
-	if err != nil && !os.IsNotExist(err) {
+	logFile, err := userSession.EncounterManager.LogLineManager.OpenLogFile()
+	if err != nil && err != session.ErrBlobNotExist {
 		appLog.Error(err)
 		return
 	}
-	var gz *gzip.Reader
-	if logFile != nil && !os.IsNotExist(err) {
+	var logReader *session.LogLineReader
+	if err == nil {
 		defer logFile.Close()
-		gz, err = gzip.NewReader(logFile)
+		gz, err := gzip.NewReader(logFile)
 		if err != nil {
 			appLog.Error(err)
 			return
 		}
 		defer gz.Close()
+		logReader, err = session.NewLogLineReader(gz)
+		if err != nil {
+			appLog.Error(err)
+			return
+		}
 	}
 	// attempt to fetch log lines
 	var logLines []data.LogLine
 	offset := 0
 	for {
 		logLines = nil
-		if logFile != nil && gz != nil {
-			logLines, err = session.GetLogLinesFromReader(gz)
+		if logReader != nil {
+			logLines, err = session.GetLogLinesFromReader(logReader)
 			if err != nil {
 				appLog.Error(err)
 				return
@@ -41,6 +41,8 @@ class Application
         this.ready = false;
         // workers
         this.workers = [];
+        // web socket
+        this.socket = null;
         // user config
         this.userConfig = {};
         // current encounter
@@ -74,6 +76,7 @@ class Application
             new ViewOverview(this.combatantCollector, this.actionCollector),
             new ViewCombatantTable(this.combatantCollector, this.actionCollector),
             new ViewCombatantGraph(this.combatantCollector, this.actionCollector),
+            new ViewReplay(this.combatantCollector, this.actionCollector),
             new ViewCombatantStream(this.combatantCollector, this.actionCollector),
             new ViewTimeline(this.combatantCollector, this.actionCollector),
             new ViewLogs(this.combatantCollector, this.actionCollector),
@@ -231,6 +234,12 @@ class Application
                     case "act:combatant": 
                     case "act:logLine":
                     case "act:combatAction":
+                    case "act:cast":
+                    case "act:deathRecap":
+                    case "act:abilityStat":
+                    case "act:timeSeries":
+                    case "act:movement":
+                    case "act:enemy":
                     {
                         var event = new CustomEvent(
                             e.data.type,
@@ -247,6 +256,7 @@ class Application
         }
         // create socket
         var socket = new WebSocket(socketUrl);
+        this.socket = socket;
         socket.onopen = function(e) {
             t.connected = true;
             t.initUserConfig();
@@ -345,6 +355,37 @@ class Application
                 }
             }
         });
+        // cast started/ended
+        window.addEventListener("act:cast", function(e) {
+            for (var i in t.views) {
+                t.views[i].onCast(e.detail);
+            }
+        });
+        window.addEventListener("act:deathRecap", function(e) {
+            for (var i in t.views) {
+                t.views[i].onDeathRecap(e.detail);
+            }
+        });
+        window.addEventListener("act:abilityStat", function(e) {
+            for (var i in t.views) {
+                t.views[i].onAbilityStat(e.detail);
+            }
+        });
+        window.addEventListener("act:timeSeries", function(e) {
+            for (var i in t.views) {
+                t.views[i].onTimeSeries(e.detail);
+            }
+        });
+        window.addEventListener("act:movement", function(e) {
+            for (var i in t.views) {
+                t.views[i].onMovement(e.detail);
+            }
+        });
+        window.addEventListener("act:enemy", function(e) {
+            for (var i in t.views) {
+                t.views[i].onEnemy(e.detail);
+            }
+        });
         // action data has been downloaded
         window.addEventListener("app:action-data", function(e) {
             // forward action data to all views
@@ -409,6 +450,25 @@ class Application
         );
     }
 
+    /**
+     * Request a range of log lines from the server, lines are
+     * delivered as "act:logLine" events.
+     * @param {Object} range - offset/limit in lines and/or start/end in seconds from encounter start
+     */
+    requestLogLines(range)
+    {
+        if (!this.socket || this.socket.readyState !== 1) {
+            return;
+        }
+        this.socket.send(JSON.stringify({
+            "type"      : "log_lines",
+            "offset"    : range.offset || 0,
+            "limit"     : range.limit || 0,
+            "start"     : range.start || 0,
+            "end"       : range.end || 0
+        }));
+    }
+
     /**
      * Ping server until connection is made. Once connection is made
      * refresh the current page.
@@ -375,6 +375,14 @@ class Combatant
             {
                 return data.Kills;   
             }
+            case "dot_damage":
+            {
+                return data.DotDamage;
+            }
+            case "hot_healed":
+            {
+                return data.HotHealed;
+            }
         }
         return "";
     }
@@ -79,8 +79,17 @@ var LOG_LINE_FLAG_DEATH = "death";
  */
 function parseLogLine(message)
 {
+    // newer plugin versions send pipe delimited network log lines,
+    // '21|2021-01-01T00:00:00.0000000-00:00|...|hash', with decimal types
+    var isPipe = /^[0-9]{1,3}\|/.test(message);
     var fields = message.substr(15).split(":");
     var messageType = parseInt(fields[kFieldType], 16);
+    if (isPipe) {
+        fields = message.split("|");
+        messageType = parseInt(fields[kFieldType], 10);
+        // drop timestamp and hash so fields line up with the colon format
+        fields = [fields[0]].concat(fields.slice(2, fields.length - 1));
+    }
     var data = {
         "raw"                   : message,
         "type"                  : messageType,
@@ -91,6 +100,10 @@ function parseLogLine(message)
         {
             data["messageId"] = parseInt(fields[1], 16);
             data["message"] = fields.slice(2).join(":");
+            if (isPipe) {
+                // name field is empty for system messages
+                data["message"] = (fields[2] ? fields[2] + ":" : "") + fields.slice(3).join("|");
+            }
             break;
         }
 
@@ -119,6 +132,10 @@ function parseLogLine(message)
         }
         case MESSAGE_TYPE_DEATH:
         {
+            if (isPipe) {
+                data["sourceName"] = fields[2];
+                break;
+            }
             var offset = kTypeOffset0 + 3;
             var defeatedIdx = message.indexOf(' was defeated');
             if (defeatedIdx == -1) {
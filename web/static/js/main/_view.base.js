@@ -163,6 +163,61 @@ class ViewBase
         return;
     }
 
+    /**
+     * Called when a cast is started or ended.
+     * @param {object} castData 
+     */
+    onCast(castData)
+    {
+        return;
+    }
+
+    /**
+     * Called when a player dies and a death recap is built.
+     * @param {object} deathRecapData 
+     */
+    onDeathRecap(deathRecapData)
+    {
+        return;
+    }
+
+    /**
+     * Called when a player's per ability totals are updated.
+     * @param {object} abilityStatData 
+     */
+    onAbilityStat(abilityStatData)
+    {
+        return;
+    }
+
+    /**
+     * Called when new damage/healing time series buckets are recieved.
+     * Offset is the index of the first bucket included.
+     * @param {object} timeSeriesData 
+     */
+    onTimeSeries(timeSeriesData)
+    {
+        return;
+    }
+
+    /**
+     * Called when new position samples for a combatant are recieved.
+     * @param {object} movementData 
+     */
+    onMovement(movementData)
+    {
+        return;
+    }
+
+    /**
+     * Called when an enemy is first seen, damaged or defeated.
+     * @param {object} enemyData 
+     */
+    onEnemy(enemyData)
+    {
+        return;
+    }
+
     /**
      * Called when a new log line is parsed.
      * @param {object} logLineData 
@@ -36,6 +36,8 @@ class EncounterDisplay
         this.encounterLengthElement = document.getElementById(ENCOUNTER_LENGTH_ID);
         this.encounterNameElement = document.getElementById(ENCOUNTER_NAME_ID);
         this.encounterStatusElement = document.getElementById(ENCOUNTER_STATUS_ID);
+        this.status = "";
+        this.quotaExceeded = false;
     }
 
     init()
@@ -45,6 +47,7 @@ class EncounterDisplay
         // hook events
         var t = this;
         window.addEventListener("act:encounter", function(e) { t._updateEncounter(e); });
+        window.addEventListener("onFlag", function(e) { t._updateFlag(e); });
         this._tick();
     }
 
@@ -58,7 +61,7 @@ class EncounterDisplay
         this.encounterLengthElement.innerText = "00:00";
         this.encounterNameElement.innerText = "-";
         this.encounterStatusElement.classList.add("hide");
-        this.encounterStatusElement.innerText = "";
+        this.setStatus("");
         this.encounterTimeElement.innerText = "";
         this.encounterTimeElement.classList.add("hide");
     }
@@ -97,6 +100,32 @@ class EncounterDisplay
         this.encounterLengthElement.innerText = padMinutes + minutes + ":" + padSeconds + seconds;
     }
 
+    /**
+     * Set encounter status text, notes when encounter wasn't saved.
+     * @param {string} status
+     */
+    setStatus(status)
+    {
+        this.status = status;
+        this.encounterStatusElement.innerText = status;
+        if (status && this.quotaExceeded) {
+            this.encounterStatusElement.innerText += " (Not Saved, Storage Quota Reached)";
+        }
+    }
+
+    /**
+     * Update quota flag from onFlag event.
+     * @param {Event} event
+     */
+    _updateFlag(event)
+    {
+        if (event.detail.Name != "quota_exceeded") {
+            return;
+        }
+        this.quotaExceeded = event.detail.Value;
+        this.setStatus(this.status);
+    }
+
     /**
      * Update encounter data from act:encounter event.
      * @param {Event} event 
@@ -129,17 +158,17 @@ class EncounterDisplay
                 case 2:
                 case 3:
                 {
-                    this.encounterStatusElement.innerText = "Wipe";
+                    this.setStatus("Wipe");
                     break;
                 }
                 case 1:
                 {
-                    this.encounterStatusElement.innerText = "Clear";
+                    this.setStatus("Clear");
                     break;
                 }
                 default:
                 {
-                    this.encounterStatusElement.innerText = "Ended";
+                    this.setStatus("Ended");
                 }
             }            
             return;
@@ -148,7 +177,7 @@ class EncounterDisplay
         // make active encounter
         this.encounterLengthElement.classList.add("active");  
         // hide status
-        this.encounterStatusElement.innerText = "";
+        this.setStatus("");
         this.encounterStatusElement.classList.add("hide");
         // end wait
         if (!this.endWait && event.detail.EndWait) {
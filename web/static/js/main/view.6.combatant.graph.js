@@ -88,6 +88,7 @@ class ViewCombatantGraph extends ViewGridBase
         this.encounter = null;
         this.maxStatValue = 0;
         this.combatants = [];
+        this.timeSeries = {};
         this.mouseOverPositions = [];
         this.currentMouseOver = null;
         this.addElementSizes(GRAPH_ELEMENT_SIZES);
@@ -101,6 +102,46 @@ class ViewCombatantGraph extends ViewGridBase
         this.combatants = this.combatantCollector.getSortedCombatants("role");
     }
 
+    onTimeSeries(timeSeries)
+    {
+        // pet damage is plotted as part of the owner's damage
+        var combatantId = timeSeries.OwnerID ? timeSeries.OwnerID : timeSeries.CombatantID;
+        var series = this.timeSeries[combatantId];
+        if (!series) {
+            series = {
+                "StartTime"     : timeSeries.StartTime,
+                "Resolution"    : timeSeries.Resolution,
+                "Damage"        : {},
+                "TotalDamage"   : []
+            };
+            this.timeSeries[combatantId] = series;
+        }
+        // merge buckets
+        if (!(timeSeries.CombatantID in series.Damage)) {
+            series.Damage[timeSeries.CombatantID] = [];
+        }
+        var damage = series.Damage[timeSeries.CombatantID];
+        for (var i = 0; i < timeSeries.Damage.length; i++) {
+            damage[timeSeries.Offset + i] = timeSeries.Damage[i];
+        }
+        // rebuild running totals
+        var length = 0;
+        for (var id in series.Damage) {
+            length = Math.max(length, series.Damage[id].length);
+        }
+        var total = 0;
+        for (var i = 0; i < length; i++) {
+            for (var id in series.Damage) {
+                total += series.Damage[id][i] ? series.Damage[id][i] : 0;
+            }
+            series.TotalDamage[i] = total;
+        }
+        if (this.encounter) {
+            this.maxStatValue = this._getMaxValue(this.valueType);
+        }
+        this.needRedraw = true;
+    }
+
     onEncounterActive(encounter)
     {
         this.reset();
@@ -160,11 +201,11 @@ class ViewCombatantGraph extends ViewGridBase
                         if (combatant.data.ID <= 0) {
                             continue;
                         }
-                        var snapshot = combatant.getSnapshot(timeIndex);
-                        if (!snapshot) {
+                        var damage = this._getDamage(combatant, timeIndex);
+                        if (damage === null) {
                             continue;
                         }
-                        var dps = Math.floor(snapshot.Damage / i);
+                        var dps = Math.floor(damage / i);
                         if (dps > maxDps) {
                             maxDps = dps;
                         }
@@ -176,6 +217,34 @@ class ViewCombatantGraph extends ViewGridBase
         return 0;
     }
 
+    /**
+     * Get total damage done by combatant at given time, uses
+     * server time series when available otherwise falls back
+     * to combatant snapshots.
+     * @param {Combatant} combatant 
+     * @param {Date} time 
+     * @return {int|null}
+     */
+    _getDamage(combatant, time)
+    {
+        var series = this.timeSeries[combatant.data.ID];
+        if (series && series.TotalDamage.length > 0) {
+            var index = Math.floor((time.getTime() - series.StartTime.getTime()) / series.Resolution);
+            if (index < 0) {
+                return 0;
+            }
+            if (index >= series.TotalDamage.length) {
+                index = series.TotalDamage.length - 1;
+            }
+            return series.TotalDamage[index];
+        }
+        var snapshot = combatant.getSnapshot(time);
+        if (!snapshot) {
+            return null;
+        }
+        return snapshot.Damage;
+    }
+
     /**
      * Draw stat value keys on left side of grid.
      */
@@ -280,8 +349,8 @@ class ViewCombatantGraph extends ViewGridBase
                 }
                 // get combatant and snapshot for current time
                 var combatant = this.combatants[j];
-                var snapshot = combatant.getSnapshot(timeIndex);
-                if (!snapshot) {
+                var damage = this._getDamage(combatant, timeIndex);
+                if (damage === null) {
                     continue;
                 }
                 // get plot value
@@ -289,7 +358,7 @@ class ViewCombatantGraph extends ViewGridBase
                 switch (this.valueType) {
                     case "dps":
                     {
-                        value = snapshot.Damage / i;
+                        value = damage / i;
                         break;
                     }
                 }
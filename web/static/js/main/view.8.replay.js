@@ -0,0 +1,173 @@
+/*
+This file is part of FFLiveParse.
+
+FFLiveParse is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+FFLiveParse is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+var REPLAY_ELEMENT_SIZES = {
+    "marker_size" : {
+        [GRID_BREAKPOINT_FULL] : 10
+    },
+    "map_padding" : {
+        [GRID_BREAKPOINT_FULL] : 32
+    }
+};
+var REPLAY_PLAYER_COLOR = "#6f79e1";
+var REPLAY_ENEMY_COLOR = "#ea6e7b";
+var REPLAY_PLAYER_MAX_ID = 1000000000;
+
+class ViewReplay extends ViewGridBase
+{
+
+    getName()
+    {
+        return "replay";
+    }
+
+    getTitle()
+    {
+        return "Replay";
+    }
+
+    reset()
+    {
+        super.reset();
+        this.encounter = null;
+        this.tracks = {};
+        this.bounds = null;
+        this.addElementSizes(REPLAY_ELEMENT_SIZES);
+    }
+
+    onEncounterActive(encounter)
+    {
+        this.reset();
+        this.encounter = encounter;
+        this.max = this.encounter.getLength();
+        this.needRedraw = true;
+    }
+
+    onMovement(movement)
+    {
+        var track = this.tracks[movement.CombatantID];
+        if (!track) {
+            track = {
+                "ID"        : movement.CombatantID,
+                "Name"      : movement.CombatantName,
+                "Samples"   : []
+            };
+            this.tracks[movement.CombatantID] = track;
+        }
+        for (var i in movement.Samples) {
+            var sample = movement.Samples[i];
+            track.Samples.push(sample);
+            // grow map bounds
+            if (!this.bounds) {
+                this.bounds = [sample.X, sample.Y, sample.X, sample.Y];
+            }
+            this.bounds[0] = Math.min(this.bounds[0], sample.X);
+            this.bounds[1] = Math.min(this.bounds[1], sample.Y);
+            this.bounds[2] = Math.max(this.bounds[2], sample.X);
+            this.bounds[3] = Math.max(this.bounds[3], sample.Y);
+        }
+        this.needRedraw = true;
+    }
+
+    tick()
+    {
+        super.tick();
+        if (this.encounter) {
+            this.max = this.encounter.getLength();
+        }
+    }
+
+    redraw()
+    {
+        super.redraw();
+        this.drawMarkers();
+        this.drawTimeKeys(this.encounter);
+    }
+
+    /**
+     * Get last position sample of track at or before given time.
+     * @param {object} track 
+     * @param {Date} time 
+     * @return {object|null}
+     */
+    _getSample(track, time)
+    {
+        var output = null;
+        for (var i in track.Samples) {
+            if (track.Samples[i].Time > time) {
+                break;
+            }
+            output = track.Samples[i];
+        }
+        return output;
+    }
+
+    /**
+     * Draw combatant markers at their position at the current seek time.
+     */
+    drawMarkers()
+    {
+        if (!this.encounter || !this.bounds) {
+            return;
+        }
+        var drawTime = this.encounter.getEndTime();
+        if (this.seek) {
+            drawTime = new Date(this.seek + this.encounter.data.StartTime.getTime());
+        }
+        // scale map to fit view, keeping aspect ratio
+        var padding = this._ES("map_padding");
+        var mapTop = this._ES("key_height") + padding;
+        var mapWidth = this.getViewWidth() - (padding * 2);
+        var mapHeight = this.getViewHeight() - mapTop - padding;
+        var scale = Math.min(
+            mapWidth / Math.max(1, this.bounds[2] - this.bounds[0]),
+            mapHeight / Math.max(1, this.bounds[3] - this.bounds[1])
+        );
+        var markerSize = this._ES("marker_size");
+        this.canvasContext.font = "12px sans-serif";
+        this.canvasContext.textAlign = "center";
+        this.canvasContext.textBaseline = "bottom";
+        for (var id in this.tracks) {
+            var track = this.tracks[id];
+            var sample = this._getSample(track, drawTime);
+            if (!sample) {
+                continue;
+            }
+            var hPos = padding + ((sample.X - this.bounds[0]) * scale);
+            var vPos = mapTop + ((sample.Y - this.bounds[1]) * scale);
+            var color = track.ID > REPLAY_PLAYER_MAX_ID ? REPLAY_ENEMY_COLOR : REPLAY_PLAYER_COLOR;
+            // marker
+            this.canvasContext.fillStyle = color;
+            this.canvasContext.beginPath();
+            this.canvasContext.arc(hPos, vPos, markerSize / 2, 0, 2 * Math.PI);
+            this.canvasContext.fill();
+            // facing, heading is in radians with 0 facing south
+            this.canvasContext.strokeStyle = color;
+            this.canvasContext.beginPath();
+            this.canvasContext.moveTo(hPos, vPos);
+            this.canvasContext.lineTo(
+                hPos + (Math.sin(sample.Heading) * markerSize),
+                vPos + (Math.cos(sample.Heading) * markerSize)
+            );
+            this.canvasContext.stroke();
+            // name
+            this.canvasContext.fillStyle = "#fff";
+            this.canvasContext.fillText(track.Name, hPos, vPos - markerSize);
+        }
+    }
+
+}
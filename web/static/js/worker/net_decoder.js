@@ -18,6 +18,12 @@ along with FFLiveParse.  If not, see <https://www.gnu.org/licenses/>.
 var DATA_TYPE_ENCOUNTER = 2;
 var DATA_TYPE_COMBATANT = 3;
 var DATA_TYPE_LOG_LINE = 5;
+var DATA_TYPE_CAST = 6;
+var DATA_TYPE_DEATH_RECAP = 7;
+var DATA_TYPE_ABILITY_STAT = 8;
+var DATA_TYPE_TIME_SERIES = 9;
+var DATA_TYPE_MOVEMENT_TRACK = 10;
+var DATA_TYPE_ENEMY = 11;
 var DATA_TYPE_FLAG = 99;
 
 var SIZE_BYTE = 1;
@@ -44,6 +50,11 @@ function readUint16(data, pos)
     return new DataView(data.buffer, pos, SIZE_INT16).getUint16(0)
 }
 
+function readFloat32(data, pos)
+{
+    return new DataView(data.buffer, pos, SIZE_INT32).getFloat32(0)
+}
+
 function readByte(data, pos)
 {
     return data[pos];
@@ -123,6 +134,8 @@ function decodeCombatantBytes(data)
     output["Hits"]          = readInt32(data, pos); pos += SIZE_INT32;
     output["Heals"]         = readInt32(data, pos); pos += SIZE_INT32;
     output["Kills" ]        = readInt32(data, pos); pos += SIZE_INT32;
+    output["DotDamage"]     = readInt32(data, pos); pos += SIZE_INT32;
+    output["HotHealed"]     = readInt32(data, pos); pos += SIZE_INT32;
     output["Time"]          = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
     output["Time"]          = new Date(output["Time"]);
     if (!encounterUid || output["EncounterUID"] == encounterUid) {
@@ -157,6 +170,219 @@ function decodeLogLineBytes(data)
     return pos;
 }
 
+function decodeCastBytes(data)
+{
+    if (data[0] != DATA_TYPE_CAST) {
+        return 0;
+    }
+    var pos = 1;
+    var output = {
+        "Type" : DATA_TYPE_CAST
+    };
+    output["EncounterUID"]  = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["CombatantID"]   = readInt32(data, pos); pos += SIZE_INT32;
+    output["CombatantName"] = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["AbilityID"]     = readInt32(data, pos); pos += SIZE_INT32;
+    output["AbilityName"]   = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["TargetID"]      = readInt32(data, pos); pos += SIZE_INT32;
+    output["TargetName"]    = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["StartTime"]     = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["Duration"]      = readInt32(data, pos); pos += SIZE_INT32;
+    output["EndTime"]       = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["Status"]        = readByte(data, pos); pos += SIZE_BYTE;
+
+    output["StartTime"]     = new Date(output["StartTime"]);
+    output["EndTime"]       = new Date(output["EndTime"]);
+    if (!encounterUid || output["EncounterUID"] == encounterUid) {
+        postMessage({
+            "type"      : "act:cast",
+            "data"      : output
+        });
+    }
+    return pos;
+}
+
+function decodeDeathRecapBytes(data)
+{
+    if (data[0] != DATA_TYPE_DEATH_RECAP) {
+        return 0;
+    }
+    var pos = 1;
+    var output = {
+        "Type" : DATA_TYPE_DEATH_RECAP
+    };
+    output["EncounterUID"]  = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["TargetID"]      = readInt32(data, pos); pos += SIZE_INT32;
+    output["TargetName"]    = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["Time"]          = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["Time"]          = new Date(output["Time"]);
+    output["Events"]        = [];
+    var eventCount = readUint16(data, pos); pos += SIZE_INT16;
+    for (var i = 0; i < eventCount; i++) {
+        var event = {};
+        event["Time"]           = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+        event["Type"]           = readByte(data, pos); pos += SIZE_BYTE;
+        event["SourceID"]       = readInt32(data, pos); pos += SIZE_INT32;
+        event["SourceName"]     = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+        event["AbilityID"]      = readInt32(data, pos); pos += SIZE_INT32;
+        event["AbilityName"]    = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+        event["Amount"]         = readInt32(data, pos); pos += SIZE_INT32;
+        event["HPBefore"]       = readInt32(data, pos); pos += SIZE_INT32;
+        event["MaxHP"]          = readInt32(data, pos); pos += SIZE_INT32;
+        event["KillingBlow"]    = readByte(data, pos) != 0; pos += SIZE_BYTE;
+        event["Time"]           = new Date(event["Time"]);
+        output["Events"].push(event);
+    }
+    if (!encounterUid || output["EncounterUID"] == encounterUid) {
+        postMessage({
+            "type"      : "act:deathRecap",
+            "data"      : output
+        });
+    }
+    return pos;
+}
+
+function decodeAbilityStatBytes(data)
+{
+    if (data[0] != DATA_TYPE_ABILITY_STAT) {
+        return 0;
+    }
+    var pos = 1;
+    var output = {
+        "Type" : DATA_TYPE_ABILITY_STAT
+    };
+    output["EncounterUID"]  = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["CombatantID"]   = readInt32(data, pos); pos += SIZE_INT32;
+    output["CombatantName"] = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["OwnerID"]       = readInt32(data, pos); pos += SIZE_INT32;
+    output["AbilityID"]     = readInt32(data, pos); pos += SIZE_INT32;
+    output["AbilityName"]   = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["StatType"]      = readByte(data, pos); pos += SIZE_BYTE;
+    output["Total"]         = readInt32(data, pos); pos += SIZE_INT32;
+    output["Hits"]          = readInt32(data, pos); pos += SIZE_INT32;
+    output["Crits"]         = readInt32(data, pos); pos += SIZE_INT32;
+    output["DirectHits"]    = readInt32(data, pos); pos += SIZE_INT32;
+    output["MaxHit"]        = readInt32(data, pos); pos += SIZE_INT32;
+    output["Time"]          = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+
+    output["Time"]          = new Date(output["Time"]);
+    output["CritRate"]      = output["Hits"] > 0 ? (output["Crits"] / output["Hits"]) * 100 : 0;
+    output["DirectHitRate"] = output["Hits"] > 0 ? (output["DirectHits"] / output["Hits"]) * 100 : 0;
+    if (!encounterUid || output["EncounterUID"] == encounterUid) {
+        postMessage({
+            "type"      : "act:abilityStat",
+            "data"      : output
+        });
+    }
+    return pos;
+}
+
+function decodeTimeSeriesBytes(data)
+{
+    if (data[0] != DATA_TYPE_TIME_SERIES) {
+        return 0;
+    }
+    var pos = 1;
+    var output = {
+        "Type" : DATA_TYPE_TIME_SERIES
+    };
+    output["EncounterUID"]  = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["CombatantID"]   = readInt32(data, pos); pos += SIZE_INT32;
+    output["CombatantName"] = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["OwnerID"]       = readInt32(data, pos); pos += SIZE_INT32;
+    output["StartTime"]     = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["Resolution"]    = readInt32(data, pos); pos += SIZE_INT32;
+    output["Offset"]        = readInt32(data, pos); pos += SIZE_INT32;
+    output["Damage"]        = [];
+    output["Healing"]       = [];
+    var bucketCount = readUint16(data, pos); pos += SIZE_INT16;
+    for (var i = 0; i < bucketCount; i++) {
+        output["Damage"].push(readInt32(data, pos)); pos += SIZE_INT32;
+        output["Healing"].push(readInt32(data, pos)); pos += SIZE_INT32;
+    }
+
+    output["StartTime"]     = new Date(output["StartTime"]);
+    if (!encounterUid || output["EncounterUID"] == encounterUid) {
+        postMessage({
+            "type"      : "act:timeSeries",
+            "data"      : output
+        });
+    }
+    return pos;
+}
+
+function decodeMovementTrackBytes(data)
+{
+    if (data[0] != DATA_TYPE_MOVEMENT_TRACK) {
+        return 0;
+    }
+    var pos = 1;
+    var output = {
+        "Type" : DATA_TYPE_MOVEMENT_TRACK
+    };
+    output["EncounterUID"]  = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["CombatantID"]   = readInt32(data, pos); pos += SIZE_INT32;
+    output["CombatantName"] = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["StartTime"]     = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["StartTime"]     = new Date(output["StartTime"]);
+    output["Samples"]       = [];
+    var sampleCount = readUint16(data, pos); pos += SIZE_INT16;
+    for (var i = 0; i < sampleCount; i++) {
+        var sample = {};
+        sample["Time"]          = new Date(output["StartTime"].getTime() + readInt32(data, pos)); pos += SIZE_INT32;
+        sample["X"]             = readFloat32(data, pos); pos += SIZE_INT32;
+        sample["Y"]             = readFloat32(data, pos); pos += SIZE_INT32;
+        sample["Z"]             = readFloat32(data, pos); pos += SIZE_INT32;
+        sample["Heading"]       = readFloat32(data, pos); pos += SIZE_INT32;
+        output["Samples"].push(sample);
+    }
+    if (!encounterUid || output["EncounterUID"] == encounterUid) {
+        postMessage({
+            "type"      : "act:movement",
+            "data"      : output
+        });
+    }
+    return pos;
+}
+
+function decodeEnemyBytes(data)
+{
+    if (data[0] != DATA_TYPE_ENEMY) {
+        return 0;
+    }
+    var pos = 1;
+    var output = {
+        "Type" : DATA_TYPE_ENEMY
+    };
+    output["EncounterUID"]  = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["CombatantID"]   = readInt32(data, pos); pos += SIZE_INT32;
+    output["Name"]          = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["MaxHP"]         = readInt32(data, pos); pos += SIZE_INT32;
+    output["FirstSeen"]     = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["Defeated"]      = readByte(data, pos) != 0; pos += SIZE_BYTE;
+    output["DefeatedTime"]  = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+    output["DamageTaken"]   = readInt32(data, pos); pos += SIZE_INT32;
+    output["DamageSources"] = [];
+    var sourceCount = readUint16(data, pos); pos += SIZE_INT16;
+    for (var i = 0; i < sourceCount; i++) {
+        var source = {};
+        source["SourceID"]      = readInt32(data, pos); pos += SIZE_INT32;
+        source["SourceName"]    = readString(data, pos); pos += readUint16(data, pos) + SIZE_INT16;
+        source["Damage"]        = readInt32(data, pos); pos += SIZE_INT32;
+        output["DamageSources"].push(source);
+    }
+
+    output["FirstSeen"]     = new Date(output["FirstSeen"]);
+    output["DefeatedTime"]  = output["Defeated"] ? new Date(output["DefeatedTime"]) : null;
+    if (!encounterUid || output["EncounterUID"] == encounterUid) {
+        postMessage({
+            "type"      : "act:enemy",
+            "data"      : output
+        });
+    }
+    return pos;
+}
+
 function decodeFlagBytes(data)
 {
     if (data[0] != DATA_TYPE_FLAG) {
@@ -196,6 +422,36 @@ function parseNextMessage(buffer, pos, count)
             length = decodeLogLineBytes(data);
             break;
         }
+        case DATA_TYPE_CAST:
+        {
+            length = decodeCastBytes(data);
+            break;
+        }
+        case DATA_TYPE_DEATH_RECAP:
+        {
+            length = decodeDeathRecapBytes(data);
+            break;
+        }
+        case DATA_TYPE_ABILITY_STAT:
+        {
+            length = decodeAbilityStatBytes(data);
+            break;
+        }
+        case DATA_TYPE_TIME_SERIES:
+        {
+            length = decodeTimeSeriesBytes(data);
+            break;
+        }
+        case DATA_TYPE_MOVEMENT_TRACK:
+        {
+            length = decodeMovementTrackBytes(data);
+            break;
+        }
+        case DATA_TYPE_ENEMY:
+        {
+            length = decodeEnemyBytes(data);
+            break;
+        }
         case DATA_TYPE_FLAG:
         {
             length = decodeFlagBytes(data);